@@ -19,6 +19,7 @@ package etcdadpt
 
 import (
 	"context"
+	"io"
 
 	"go.etcd.io/etcd/api/v3/mvccpb"
 )
@@ -145,8 +146,12 @@ func Txn(ctx context.Context, opts []OpOptions) error {
 
 func TxnWithCmp(ctx context.Context, opts []OpOptions,
 	cmp []CmpOptions, fail []OpOptions) (resp *Response, err error) {
+	inst := Instance()
 	lenOpts := len(opts)
 	tmpLen := lenOpts
+	if lenOpts > MaxTxnNumberOneTime {
+		reporterOf(inst).ReportBackendTxnChunks((lenOpts + MaxTxnNumberOneTime - 1) / MaxTxnNumberOneTime)
+	}
 	var tmpOpts []OpOptions
 	for i := 0; tmpLen > 0; i++ {
 		tmpLen = lenOpts - (i+1)*MaxTxnNumberOneTime
@@ -155,7 +160,7 @@ func TxnWithCmp(ctx context.Context, opts []OpOptions,
 		} else {
 			tmpOpts = opts[i*MaxTxnNumberOneTime : lenOpts]
 		}
-		resp, err = Instance().TxnWithCmp(ctx, tmpOpts, cmp, fail)
+		resp, err = inst.TxnWithCmp(ctx, tmpOpts, cmp, fail)
 		if err != nil || !resp.Succeeded {
 			return
 		}
@@ -167,14 +172,80 @@ func ListCluster(ctx context.Context) (Clusters, error) {
 	return Instance().ListCluster(ctx)
 }
 
+// The functions below return ErrNotSupported if the instance does not implement
+// the optional interfaces LeaseInspector, Defragmenter, Snapshotter or MemberManager
+
+func LeaseTimeToLive(ctx context.Context, leaseID int64) (int64, error) {
+	return leaseTimeToLive(ctx, Instance(), leaseID)
+}
+
+func Defragment(ctx context.Context) error {
+	return defragment(ctx, Instance())
+}
+
+func Snapshot(ctx context.Context, w io.Writer) error {
+	return snapshot(ctx, Instance(), w)
+}
+
+func ListMember(ctx context.Context) ([]*Member, error) {
+	manager, err := memberManager(Instance())
+	if err != nil {
+		return nil, err
+	}
+	return manager.ListMember(ctx)
+}
+
+func AddMember(ctx context.Context, peerURLs []string, isLearner bool) (*Member, error) {
+	manager, err := memberManager(Instance())
+	if err != nil {
+		return nil, err
+	}
+	return manager.AddMember(ctx, peerURLs, isLearner)
+}
+
+func RemoveMember(ctx context.Context, id uint64) error {
+	manager, err := memberManager(Instance())
+	if err != nil {
+		return err
+	}
+	return manager.RemoveMember(ctx, id)
+}
+
+func PromoteMember(ctx context.Context, id uint64) error {
+	manager, err := memberManager(Instance())
+	if err != nil {
+		return err
+	}
+	return manager.PromoteMember(ctx, id)
+}
+
+func UpdateMember(ctx context.Context, id uint64, peerURLs []string) error {
+	manager, err := memberManager(Instance())
+	if err != nil {
+		return err
+	}
+	return manager.UpdateMember(ctx, id, peerURLs)
+}
+
 // Lock func will lock the key, and retry three times if it fails.
 // ttl unit is second.
 func Lock(key string, ttl int64) (*DLock, error) {
-	return newDLock(DefaultLock+"/"+key, ttl, true)
+	return LockContext(context.Background(), key, ttl)
 }
 
 // TryLock func will try to lock the key.
 // ttl unit is second.
 func TryLock(key string, ttl int64) (*DLock, error) {
-	return newDLock(DefaultLock+"/"+key, ttl, false)
+	return TryLockContext(context.Background(), key, ttl)
+}
+
+// LockContext is the same as Lock, the acquisition is canceled when ctx is done,
+// and it is traced as the child span of the one in ctx
+func LockContext(ctx context.Context, key string, ttl int64) (*DLock, error) {
+	return newDLock(ctx, DefaultLock+"/"+key, ttl, true)
+}
+
+// TryLockContext is the same as TryLock, the acquisition is traced as the child span of the one in ctx
+func TryLockContext(ctx context.Context, key string, ttl int64) (*DLock, error) {
+	return newDLock(ctx, DefaultLock+"/"+key, ttl, false)
 }
@@ -20,9 +20,12 @@ package etcdadpt_test
 import (
 	"context"
 	"errors"
+	"net"
+	"os"
 	"strconv"
 	"sync"
 	"testing"
+	"time"
 
 	_ "github.com/little-cui/etcdadpt/test"
 
@@ -30,6 +33,12 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+// isEmbedded returns true if the test instance is the embedded etcd, see test/init.go
+func isEmbedded() bool {
+	kind := os.Getenv("TEST_DB_KIND")
+	return kind == "embedded_etcd" || kind == "embeded_etcd"
+}
+
 func TestGet(t *testing.T) {
 	t.Run("get not exist key, should return nil", func(t *testing.T) {
 		kv, err := etcdadpt.Get(context.Background(), "/not-exist")
@@ -728,4 +737,206 @@ func TestStatus(t *testing.T) {
 	status, err := etcdadpt.Instance().Status(context.Background())
 	assert.NoError(t, err)
 	assert.NotZero(t, status.DBSize)
+	assert.NotZero(t, status.DBSizeInUse)
+	assert.NotZero(t, status.MemberID)
+	assert.NotZero(t, status.Leader)
+	assert.NotZero(t, status.RaftTerm)
+	assert.NotZero(t, status.RaftIndex)
+	assert.NotZero(t, status.RaftAppliedIndex)
+	assert.NotZero(t, status.Revision)
+	assert.NotEmpty(t, status.Version)
+	assert.Empty(t, status.Alarms)
+	assert.Empty(t, status.AlarmsError)
+
+	// the status is reported by one of the members, the leader or the local one
+	var reporter *etcdadpt.EndpointStatus
+	assert.NotEmpty(t, status.Endpoints)
+	for i, ep := range status.Endpoints {
+		if isEmbedded() {
+			// the embedded test instance exposes no client urls
+			assert.Empty(t, ep.Endpoint)
+		} else {
+			assert.NotEmpty(t, ep.Endpoint)
+		}
+		assert.Empty(t, ep.Error)
+		assert.Equal(t, status.Leader, ep.Leader)
+		if ep.MemberID == status.MemberID {
+			reporter = &status.Endpoints[i]
+		}
+	}
+	if assert.NotNil(t, reporter) {
+		assert.Equal(t, status.Revision, reporter.Revision)
+	}
+}
+
+// freeAddr returns a local address which is free to listen
+func freeAddr(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+func TestWatch(t *testing.T) {
+	// the remote client connects to the embedded server
+	clientURL := "http://" + freeAddr(t)
+	embeddedCfg := etcdadpt.Config{
+		Kind:             "embedded_etcd",
+		ClusterName:      "watch",
+		ClusterAddresses: "watch=" + clientURL,
+		ManagerAddress:   "http://" + freeAddr(t),
+		Embedded:         etcdadpt.EmbeddedConfig{DataDir: t.TempDir()},
+	}
+	embeddedCfg.Init()
+	embeddedInst, err := etcdadpt.NewInstance(embeddedCfg)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer embeddedInst.Close()
+
+	remoteCfg := etcdadpt.Config{
+		Kind:             "etcd",
+		ClusterAddresses: clientURL,
+	}
+	remoteCfg.Init()
+	remoteInst, err := etcdadpt.NewInstance(remoteCfg)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer remoteInst.Close()
+
+	t.Run("embedded", func(t *testing.T) {
+		testWatch(t, embeddedInst)
+	})
+	t.Run("remote", func(t *testing.T) {
+		testWatch(t, remoteInst)
+	})
+}
+
+func testWatch(t *testing.T, inst etcdadpt.Client) {
+	keys := []string{
+		"/test_watch/sv",
+		"/test_watch/svc",
+		"/test_watch/svc/1",
+		"/test_watch/svcA",
+		"/test_watch/svd",
+	}
+	var rev int64
+	for _, key := range keys {
+		resp, err := inst.Do(context.Background(), etcdadpt.PUT,
+			etcdadpt.WithStrKey(key), etcdadpt.WithStrValue(key))
+		assert.NoError(t, err)
+		if rev == 0 {
+			rev = resp.Revision
+		}
+	}
+	defer inst.Do(context.Background(), etcdadpt.DEL,
+		etcdadpt.WithStrKey("/test_watch/"), etcdadpt.WithPrefix())
+
+	watch := func(opts ...etcdadpt.OpOption) []string {
+		var watched []string
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		err := inst.Watch(ctx, append(opts, etcdadpt.WithRev(rev),
+			etcdadpt.WithWatchCallback(func(message string, evt *etcdadpt.Response) error {
+				for _, kv := range evt.Kvs {
+					watched = append(watched, string(kv.Key))
+				}
+				return nil
+			}))...)
+		assert.NoError(t, err)
+		return watched
+	}
+
+	t.Run("watch key should only return the key", func(t *testing.T) {
+		watched := watch(etcdadpt.WithStrKey("/test_watch/svc"))
+		assert.Equal(t, []string{"/test_watch/svc"}, watched)
+	})
+
+	t.Run("watch prefix should return all keys have the prefix", func(t *testing.T) {
+		watched := watch(etcdadpt.WithStrKey("/test_watch/svc"), etcdadpt.WithPrefix())
+		assert.Equal(t, []string{"/test_watch/svc", "/test_watch/svc/1", "/test_watch/svcA"}, watched)
+	})
+
+	t.Run("watch range [svc, svcA) should not return svcA", func(t *testing.T) {
+		watched := watch(etcdadpt.WithStrKey("/test_watch/svc"), etcdadpt.WithStrEndKey("/test_watch/svcA"))
+		assert.Equal(t, []string{"/test_watch/svc", "/test_watch/svc/1"}, watched)
+	})
+}
+
+func TestDefragment(t *testing.T) {
+	before, err := etcdadpt.Instance().Status(context.Background())
+	assert.NoError(t, err)
+	assert.NotZero(t, before.DBSizeInUse)
+
+	err = etcdadpt.Defragment(context.Background())
+	assert.NoError(t, err)
+
+	after, err := etcdadpt.Instance().Status(context.Background())
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, after.DBSize, before.DBSize)
+}
+
+func TestMember(t *testing.T) {
+	ctx := context.Background()
+	members, err := etcdadpt.ListMember(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(members))
+	self := members[0]
+	assert.NotZero(t, self.ID)
+	assert.NotEmpty(t, self.Name)
+	assert.NotEmpty(t, self.PeerURLs)
+	if isEmbedded() {
+		// the embedded test instance exposes no client urls
+		assert.Empty(t, self.ClientURLs)
+	} else {
+		assert.NotEmpty(t, self.ClientURLs)
+	}
+	assert.False(t, self.IsLearner)
+	assert.True(t, self.Healthy)
+
+	t.Run("add, promote and remove a learner should be ok", func(t *testing.T) {
+		learner, err := etcdadpt.AddMember(ctx, []string{"http://127.0.0.1:12390"}, true)
+		assert.NoError(t, err)
+		assert.NotZero(t, learner.ID)
+		assert.True(t, learner.IsLearner)
+		defer etcdadpt.RemoveMember(ctx, learner.ID)
+
+		members, err := etcdadpt.ListMember(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, len(members))
+		for _, m := range members {
+			if m.ID == learner.ID {
+				assert.Empty(t, m.Name)
+				assert.True(t, m.IsLearner)
+				assert.False(t, m.Healthy)
+			}
+		}
+
+		err = etcdadpt.UpdateMember(ctx, learner.ID, []string{"http://127.0.0.1:12391"})
+		assert.NoError(t, err)
+
+		// the learner is not started, so it is not in sync with the leader
+		err = etcdadpt.PromoteMember(ctx, learner.ID)
+		assert.Error(t, err)
+
+		err = etcdadpt.RemoveMember(ctx, learner.ID)
+		assert.NoError(t, err)
+	})
+
+	t.Run("update self with the same peer urls should be ok", func(t *testing.T) {
+		err := etcdadpt.UpdateMember(ctx, self.ID, self.PeerURLs)
+		assert.NoError(t, err)
+	})
+
+	t.Run("member not exist should return not found", func(t *testing.T) {
+		err := etcdadpt.RemoveMember(ctx, 1)
+		assert.Equal(t, etcdadpt.ErrMemberNotFound, err)
+		err = etcdadpt.PromoteMember(ctx, 1)
+		assert.Equal(t, etcdadpt.ErrMemberNotFound, err)
+		err = etcdadpt.UpdateMember(ctx, 1, []string{"http://127.0.0.1:12392"})
+		assert.Equal(t, etcdadpt.ErrMemberNotFound, err)
+	})
 }
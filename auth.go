@@ -0,0 +1,94 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package etcdadpt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+)
+
+const (
+	PermRead Permission = iota
+	PermWrite
+	PermReadWrite
+)
+
+// Permission is the same as the etcd permission type
+type Permission int
+
+func (p Permission) String() string {
+	switch p {
+	case PermRead:
+		return "READ"
+	case PermWrite:
+		return "WRITE"
+	case PermReadWrite:
+		return "READWRITE"
+	default:
+		return "PERMISSION" + fmt.Sprint(int(p))
+	}
+}
+
+// AuthManager is the optional interface of Client to manage the users and roles,
+// it is only implemented by the remote etcd client
+type AuthManager interface {
+	EnableAuth(ctx context.Context) error
+	DisableAuth(ctx context.Context) error
+	AddUser(ctx context.Context, name, password string) error
+	ChangePassword(ctx context.Context, name, password string) error
+	DeleteUser(ctx context.Context, name string) error
+	AddRole(ctx context.Context, name string) error
+	DeleteRole(ctx context.Context, name string) error
+	GrantRole(ctx context.Context, user, role string) error
+	// GrantPermission grants the role to access the keys with the prefix
+	GrantPermission(ctx context.Context, role, prefix string, perm Permission) error
+}
+
+// ProvisionPrefixUser creates the user and the role with the same name, which
+// is only permitted to access the keys with the prefix, the existing role will
+// be reused, and the password of the existing user will be changed
+func ProvisionPrefixUser(ctx context.Context, am AuthManager, user, password, prefix string, perm Permission) error {
+	err := am.AddRole(ctx, user)
+	if err != nil && !errors.Is(err, rpctypes.ErrRoleAlreadyExist) {
+		return err
+	}
+	if err = am.GrantPermission(ctx, user, prefix, perm); err != nil {
+		return err
+	}
+	err = am.AddUser(ctx, user, password)
+	if errors.Is(err, rpctypes.ErrUserAlreadyExist) {
+		err = am.ChangePassword(ctx, user, password)
+	}
+	if err != nil {
+		return err
+	}
+	return am.GrantRole(ctx, user, user)
+}
+
+// GetAuthManager returns the AuthManager of the instance,
+// return ErrNotSupported if the instance does not implement it
+func GetAuthManager() (AuthManager, error) {
+	am, ok := Unwrap(Instance()).(AuthManager)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	return am, nil
+}
@@ -0,0 +1,411 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package etcdadpt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/little-cui/etcdadpt/middleware/log"
+	"github.com/little-cui/etcdadpt/middleware/metrics"
+)
+
+const (
+	BreakerClosed BreakerState = iota
+	BreakerOpen
+	BreakerHalfOpen
+)
+
+const (
+	DefaultBreakerWindow      = 10 * time.Second
+	DefaultBreakerMinRequests = 20
+	DefaultBreakerErrorRate   = 0.5
+	DefaultBreakerOpenTimeout = 5 * time.Second
+)
+
+// ErrCircuitOpen the request is rejected for the circuit breaker is open,
+// use errors.Is to check it for the error is wrapped with the operation
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+type BreakerState int
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "CLOSED"
+	case BreakerOpen:
+		return "OPEN"
+	case BreakerHalfOpen:
+		return "HALF_OPEN"
+	default:
+		return "BREAKER" + strconv.Itoa(int(s))
+	}
+}
+
+// CircuitBreakerConfig is the options of circuit breaker,
+// the zero value field means using the default value
+type CircuitBreakerConfig struct {
+	// Window optional, the duration to count the requests, by default use DefaultBreakerWindow
+	Window time.Duration `json:"window,omitempty"`
+	// MinRequests optional, the min number of requests in a window to trip the breaker
+	MinRequests int `json:"minRequests,omitempty"`
+	// ErrorRate optional, trip the breaker if the ratio of failures reaches it
+	ErrorRate float64 `json:"errorRate,omitempty"`
+	// SlowThreshold optional, the request slower than it is counted as a slow one, 0 means disable
+	SlowThreshold time.Duration `json:"slowThreshold,omitempty"`
+	// SlowRate optional, trip the breaker if the ratio of slow requests reaches it, by default use ErrorRate
+	SlowRate float64 `json:"slowRate,omitempty"`
+	// OpenTimeout optional, the duration of open state before half-open
+	OpenTimeout time.Duration `json:"openTimeout,omitempty"`
+	// HalfOpenProbes optional, the number of probe requests in half-open state,
+	// the breaker is closed if all of them succeed, by default 1
+	HalfOpenProbes int `json:"halfOpenProbes,omitempty"`
+	// IsFailure optional, returns true if the error should be counted as a failure,
+	// by default the business errors like ErrLeaseNotFound are not counted
+	IsFailure func(err error) bool `json:"-"`
+}
+
+func (c *CircuitBreakerConfig) Init() {
+	if c.Window == 0 {
+		c.Window = DefaultBreakerWindow
+	}
+	if c.MinRequests == 0 {
+		c.MinRequests = DefaultBreakerMinRequests
+	}
+	if c.ErrorRate == 0 {
+		c.ErrorRate = DefaultBreakerErrorRate
+	}
+	if c.SlowRate == 0 {
+		c.SlowRate = c.ErrorRate
+	}
+	if c.OpenTimeout == 0 {
+		c.OpenTimeout = DefaultBreakerOpenTimeout
+	}
+	if c.HalfOpenProbes == 0 {
+		c.HalfOpenProbes = 1
+	}
+	if c.IsFailure == nil {
+		c.IsFailure = isBreakerFailure
+	}
+}
+
+func isBreakerFailure(err error) bool {
+	switch {
+	case err == nil,
+		errors.Is(err, context.Canceled),
+		errors.Is(err, ErrLeaseNotFound),
+		errors.Is(err, ErrMemberNotFound),
+		errors.Is(err, ErrPermissionDenied),
+		errors.Is(err, ErrNotSupported):
+		return false
+	default:
+		return true
+	}
+}
+
+// CircuitBreaker wraps the Client, it trips on the error rate or latency and then
+// fails fast with ErrCircuitOpen, half-opens with the probe requests after OpenTimeout
+type CircuitBreaker struct {
+	Client
+
+	cfg           CircuitBreakerConfig
+	errorFunc     func(err error)
+	connectedFunc func()
+	metrics       metrics.Reporter
+
+	mux        sync.Mutex
+	state      BreakerState
+	generation uint64
+	expiry     time.Time
+	total      int
+	failures   int
+	slows      int
+	probes     int
+	successes  int
+}
+
+// NewCircuitBreaker wraps the client with the breaker, ErrorFunc is called when
+// the breaker trips and ConnectedFunc is called when it is closed again
+func NewCircuitBreaker(client Client, cfg CircuitBreakerConfig, errorFunc func(err error), connectedFunc func()) *CircuitBreaker {
+	cfg.Init()
+	cb := &CircuitBreaker{
+		Client:        client,
+		cfg:           cfg,
+		errorFunc:     errorFunc,
+		connectedFunc: connectedFunc,
+	}
+	if chain, ok := client.(*ChainClient); ok {
+		cb.metrics.Instance = chain.metricsInstance
+	}
+	cb.setState(BreakerClosed, time.Now())
+	return cb
+}
+
+// Unwrap returns the wrapped client
+func (cb *CircuitBreaker) Unwrap() Client {
+	return cb.Client
+}
+
+// State returns the current state of the breaker
+func (cb *CircuitBreaker) State() BreakerState {
+	cb.mux.Lock()
+	defer cb.mux.Unlock()
+	state, _ := cb.currentState(time.Now())
+	return state
+}
+
+func (cb *CircuitBreaker) currentState(now time.Time) (BreakerState, uint64) {
+	switch cb.state {
+	case BreakerClosed:
+		if now.After(cb.expiry) {
+			cb.resetCounts(now)
+		}
+	case BreakerOpen:
+		if now.After(cb.expiry) {
+			cb.setState(BreakerHalfOpen, now)
+		}
+	}
+	return cb.state, cb.generation
+}
+
+func (cb *CircuitBreaker) resetCounts(now time.Time) {
+	cb.generation++
+	cb.total, cb.failures, cb.slows, cb.probes, cb.successes = 0, 0, 0, 0, 0
+	cb.expiry = time.Time{}
+	switch cb.state {
+	case BreakerClosed:
+		cb.expiry = now.Add(cb.cfg.Window)
+	case BreakerOpen:
+		cb.expiry = now.Add(cb.cfg.OpenTimeout)
+	}
+}
+
+func (cb *CircuitBreaker) setState(state BreakerState, now time.Time) {
+	prev := cb.state
+	cb.state = state
+	cb.resetCounts(now)
+	cb.metrics.ReportBackendCircuitState(int(state))
+	if prev == state {
+		return
+	}
+	log.GetLogger().Warn(fmt.Sprintf("circuit breaker state changed, %s -> %s", prev, state))
+	switch {
+	case state == BreakerOpen && cb.errorFunc != nil:
+		go cb.errorFunc(ErrCircuitOpen)
+	case state == BreakerClosed && cb.connectedFunc != nil:
+		go cb.connectedFunc()
+	}
+}
+
+// allow returns the generation of the request, or ErrCircuitOpen if rejected
+func (cb *CircuitBreaker) allow(operation string) (uint64, error) {
+	cb.mux.Lock()
+	defer cb.mux.Unlock()
+
+	state, generation := cb.currentState(time.Now())
+	switch {
+	case state == BreakerOpen,
+		state == BreakerHalfOpen && cb.probes >= cb.cfg.HalfOpenProbes:
+		return 0, cb.reject(operation)
+	case state == BreakerHalfOpen:
+		cb.probes++
+	}
+	return generation, nil
+}
+
+func (cb *CircuitBreaker) reject(operation string) error {
+	cb.metrics.ReportBackendCircuitRejected(operation)
+	return fmt.Errorf("%w, %s", ErrCircuitOpen, operation)
+}
+
+func (cb *CircuitBreaker) done(generation uint64, err error, elapsed time.Duration) {
+	cb.mux.Lock()
+	defer cb.mux.Unlock()
+
+	now := time.Now()
+	state, current := cb.currentState(now)
+	if generation != current {
+		// the result of the previous state
+		return
+	}
+
+	failed := cb.cfg.IsFailure(err)
+	slow := cb.cfg.SlowThreshold > 0 && elapsed >= cb.cfg.SlowThreshold
+	switch state {
+	case BreakerHalfOpen:
+		if failed || slow {
+			cb.setState(BreakerOpen, now)
+			return
+		}
+		cb.successes++
+		if cb.successes >= cb.cfg.HalfOpenProbes {
+			cb.setState(BreakerClosed, now)
+		}
+	case BreakerClosed:
+		cb.total++
+		if failed {
+			cb.failures++
+		}
+		if slow {
+			cb.slows++
+		}
+		if cb.total < cb.cfg.MinRequests {
+			return
+		}
+		total := float64(cb.total)
+		if float64(cb.failures)/total >= cb.cfg.ErrorRate ||
+			(cb.cfg.SlowThreshold > 0 && float64(cb.slows)/total >= cb.cfg.SlowRate) {
+			cb.setState(BreakerOpen, now)
+		}
+	}
+}
+
+func (cb *CircuitBreaker) call(operation string, f func() error) error {
+	generation, err := cb.allow(operation)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	err = f()
+	cb.done(generation, err, time.Since(start))
+	return err
+}
+
+func (cb *CircuitBreaker) Do(ctx context.Context, opts ...OpOption) (resp *Response, err error) {
+	op := OptionsToOp(opts...)
+	err = cb.call(op.Action.String(), func() error {
+		resp, err = cb.Client.Do(ctx, opts...)
+		return err
+	})
+	return
+}
+
+func (cb *CircuitBreaker) Txn(ctx context.Context, ops []OpOptions) (resp *Response, err error) {
+	err = cb.call("TXN", func() error {
+		resp, err = cb.Client.Txn(ctx, ops)
+		return err
+	})
+	return
+}
+
+func (cb *CircuitBreaker) TxnWithCmp(ctx context.Context, success []OpOptions, cmp []CmpOptions, fail []OpOptions) (resp *Response, err error) {
+	err = cb.call("TXN", func() error {
+		resp, err = cb.Client.TxnWithCmp(ctx, success, cmp, fail)
+		return err
+	})
+	return
+}
+
+func (cb *CircuitBreaker) LeaseGrant(ctx context.Context, TTL int64) (leaseID int64, err error) {
+	err = cb.call("LEASE_GRANT", func() error {
+		leaseID, err = cb.Client.LeaseGrant(ctx, TTL)
+		return err
+	})
+	return
+}
+
+func (cb *CircuitBreaker) LeaseRenew(ctx context.Context, leaseID int64) (TTL int64, err error) {
+	err = cb.call("LEASE_RENEW", func() error {
+		TTL, err = cb.Client.LeaseRenew(ctx, leaseID)
+		return err
+	})
+	return
+}
+
+func (cb *CircuitBreaker) LeaseRevoke(ctx context.Context, leaseID int64) error {
+	return cb.call("LEASE_REVOKE", func() error {
+		return cb.Client.LeaseRevoke(ctx, leaseID)
+	})
+}
+
+func (cb *CircuitBreaker) LeaseTimeToLive(ctx context.Context, leaseID int64) (TTL int64, err error) {
+	err = cb.call("LEASE_TTL", func() error {
+		TTL, err = leaseTimeToLive(ctx, cb.Client, leaseID)
+		return err
+	})
+	return
+}
+
+func (cb *CircuitBreaker) Compact(ctx context.Context, reserve int64) error {
+	return cb.call("COMPACT", func() error {
+		return cb.Client.Compact(ctx, reserve)
+	})
+}
+
+// Watch is rejected if the breaker is open, but the long-lived watching is not counted
+func (cb *CircuitBreaker) Watch(ctx context.Context, opts ...OpOption) error {
+	if cb.State() == BreakerOpen {
+		return cb.reject("WATCH")
+	}
+	return cb.Client.Watch(ctx, opts...)
+}
+
+// The maintenance operations below are passed through without being counted
+
+func (cb *CircuitBreaker) Defragment(ctx context.Context) error {
+	return defragment(ctx, cb.Client)
+}
+
+func (cb *CircuitBreaker) Snapshot(ctx context.Context, w io.Writer) error {
+	return snapshot(ctx, cb.Client, w)
+}
+
+func (cb *CircuitBreaker) ListMember(ctx context.Context) ([]*Member, error) {
+	manager, err := memberManager(cb.Client)
+	if err != nil {
+		return nil, err
+	}
+	return manager.ListMember(ctx)
+}
+
+func (cb *CircuitBreaker) AddMember(ctx context.Context, peerURLs []string, isLearner bool) (*Member, error) {
+	manager, err := memberManager(cb.Client)
+	if err != nil {
+		return nil, err
+	}
+	return manager.AddMember(ctx, peerURLs, isLearner)
+}
+
+func (cb *CircuitBreaker) RemoveMember(ctx context.Context, id uint64) error {
+	manager, err := memberManager(cb.Client)
+	if err != nil {
+		return err
+	}
+	return manager.RemoveMember(ctx, id)
+}
+
+func (cb *CircuitBreaker) PromoteMember(ctx context.Context, id uint64) error {
+	manager, err := memberManager(cb.Client)
+	if err != nil {
+		return err
+	}
+	return manager.PromoteMember(ctx, id)
+}
+
+func (cb *CircuitBreaker) UpdateMember(ctx context.Context, id uint64, peerURLs []string) error {
+	manager, err := memberManager(cb.Client)
+	if err != nil {
+		return err
+	}
+	return manager.UpdateMember(ctx, id, peerURLs)
+}
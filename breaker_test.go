@@ -0,0 +1,177 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package etcdadpt_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/little-cui/etcdadpt"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClient returns the err after delay
+type fakeClient struct {
+	etcdadpt.Client
+
+	mux   sync.Mutex
+	err   error
+	delay time.Duration
+	calls int
+	block chan struct{}
+	// last the OpOptions of the last Do
+	last etcdadpt.OpOptions
+}
+
+func (c *fakeClient) set(err error, delay time.Duration) {
+	c.mux.Lock()
+	c.err, c.delay, c.calls = err, delay, 0
+	c.mux.Unlock()
+}
+
+func (c *fakeClient) Calls() int {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.calls
+}
+
+func (c *fakeClient) Do(_ context.Context, opts ...etcdadpt.OpOption) (*etcdadpt.Response, error) {
+	c.mux.Lock()
+	c.calls++
+	c.last = etcdadpt.OptionsToOp(opts...)
+	err, delay, block := c.err, c.delay, c.block
+	c.mux.Unlock()
+	if block != nil {
+		<-block
+	}
+	time.Sleep(delay)
+	if err != nil {
+		return nil, err
+	}
+	return &etcdadpt.Response{Succeeded: true}, nil
+}
+
+func newTestBreaker(inner etcdadpt.Client) (*etcdadpt.CircuitBreaker, chan error, chan struct{}) {
+	errCh, connectedCh := make(chan error, 10), make(chan struct{}, 10)
+	cb := etcdadpt.NewCircuitBreaker(inner, etcdadpt.CircuitBreakerConfig{
+		MinRequests:   4,
+		ErrorRate:     0.5,
+		SlowThreshold: 20 * time.Millisecond,
+		OpenTimeout:   50 * time.Millisecond,
+	}, func(err error) { errCh <- err }, func() { connectedCh <- struct{}{} })
+	return cb, errCh, connectedCh
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	ctx := context.Background()
+	errUnavailable := errors.New("unavailable")
+
+	t.Run("trip on error rate, should fail fast and recover by probes", func(t *testing.T) {
+		inner := &fakeClient{}
+		cb, errCh, connectedCh := newTestBreaker(inner)
+
+		inner.set(errUnavailable, 0)
+		for i := 0; i < 4; i++ {
+			_, err := cb.Do(ctx, etcdadpt.GET)
+			assert.Equal(t, errUnavailable, err)
+		}
+		assert.Equal(t, etcdadpt.BreakerOpen, cb.State())
+		assert.ErrorIs(t, <-errCh, etcdadpt.ErrCircuitOpen)
+
+		_, err := cb.Do(ctx, etcdadpt.GET)
+		assert.ErrorIs(t, err, etcdadpt.ErrCircuitOpen)
+		assert.ErrorIs(t, cb.Watch(ctx, etcdadpt.GET), etcdadpt.ErrCircuitOpen)
+		assert.Equal(t, 4, inner.Calls())
+
+		// probe failed, open again
+		time.Sleep(60 * time.Millisecond)
+		assert.Equal(t, etcdadpt.BreakerHalfOpen, cb.State())
+		_, err = cb.Do(ctx, etcdadpt.GET)
+		assert.Equal(t, errUnavailable, err)
+		assert.Equal(t, etcdadpt.BreakerOpen, cb.State())
+
+		// probe succeeded, closed
+		inner.set(nil, 0)
+		time.Sleep(60 * time.Millisecond)
+		_, err = cb.Do(ctx, etcdadpt.GET)
+		assert.NoError(t, err)
+		assert.Equal(t, etcdadpt.BreakerClosed, cb.State())
+		select {
+		case <-connectedCh:
+		case <-time.After(time.Second):
+			t.Fatal("ConnectedFunc is not called")
+		}
+	})
+
+	t.Run("trip on latency, should be open", func(t *testing.T) {
+		inner := &fakeClient{}
+		cb, _, _ := newTestBreaker(inner)
+
+		inner.set(nil, 30*time.Millisecond)
+		for i := 0; i < 4; i++ {
+			_, err := cb.Do(ctx, etcdadpt.GET)
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, etcdadpt.BreakerOpen, cb.State())
+	})
+
+	t.Run("business errors, should not trip", func(t *testing.T) {
+		inner := &fakeClient{}
+		cb, _, _ := newTestBreaker(inner)
+
+		inner.set(etcdadpt.ErrLeaseNotFound, 0)
+		for i := 0; i < 4; i++ {
+			_, err := cb.Do(ctx, etcdadpt.GET)
+			assert.Equal(t, etcdadpt.ErrLeaseNotFound, err)
+		}
+		assert.Equal(t, etcdadpt.BreakerClosed, cb.State())
+	})
+
+	t.Run("half-open, should only allow the probes", func(t *testing.T) {
+		inner := &fakeClient{}
+		cb, _, _ := newTestBreaker(inner)
+
+		inner.set(errUnavailable, 0)
+		for i := 0; i < 4; i++ {
+			_, _ = cb.Do(ctx, etcdadpt.GET)
+		}
+		time.Sleep(60 * time.Millisecond)
+
+		inner.set(nil, 0)
+		block := make(chan struct{})
+		inner.mux.Lock()
+		inner.block = block
+		inner.mux.Unlock()
+		done := make(chan error)
+		go func() {
+			_, err := cb.Do(ctx, etcdadpt.GET)
+			done <- err
+		}()
+		assert.Eventually(t, func() bool { return inner.Calls() == 1 }, time.Second, time.Millisecond)
+
+		_, err := cb.Do(ctx, etcdadpt.GET)
+		assert.ErrorIs(t, err, etcdadpt.ErrCircuitOpen)
+
+		close(block)
+		assert.NoError(t, <-done)
+		assert.Equal(t, etcdadpt.BreakerClosed, cb.State())
+	})
+}
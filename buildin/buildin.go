@@ -19,6 +19,7 @@ package buildin
 
 import (
 	"context"
+	"io"
 
 	"github.com/little-cui/etcdadpt"
 )
@@ -62,15 +63,39 @@ func (ec *Client) LeaseRenew(ctx context.Context, leaseID int64) (TTL int64, err
 func (ec *Client) LeaseRevoke(ctx context.Context, leaseID int64) error {
 	return nil
 }
+func (ec *Client) LeaseTimeToLive(ctx context.Context, leaseID int64) (TTL int64, err error) {
+	return 0, nil
+}
 func (ec *Client) Watch(ctx context.Context, opts ...etcdadpt.OpOption) error {
 	return nil
 }
 func (ec *Client) Compact(ctx context.Context, reserve int64) error {
 	return nil
 }
+func (ec *Client) Defragment(ctx context.Context) error {
+	return nil
+}
+func (ec *Client) Snapshot(ctx context.Context, w io.Writer) error {
+	return nil
+}
 func (ec *Client) ListCluster(ctx context.Context) (etcdadpt.Clusters, error) {
 	return nil, nil
 }
+func (ec *Client) ListMember(ctx context.Context) ([]*etcdadpt.Member, error) {
+	return nil, nil
+}
+func (ec *Client) AddMember(ctx context.Context, peerURLs []string, isLearner bool) (*etcdadpt.Member, error) {
+	return &etcdadpt.Member{}, nil
+}
+func (ec *Client) RemoveMember(ctx context.Context, id uint64) error {
+	return nil
+}
+func (ec *Client) PromoteMember(ctx context.Context, id uint64) error {
+	return nil
+}
+func (ec *Client) UpdateMember(ctx context.Context, id uint64, peerURLs []string) error {
+	return nil
+}
 func (ec *Client) Status(ctx context.Context) (*etcdadpt.StatusResponse, error) {
 	return &etcdadpt.StatusResponse{}, nil
 }
@@ -21,12 +21,19 @@ package etcdadpt
 import (
 	"context"
 	"errors"
+	"io"
 
 	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
 )
 
 var (
-	ErrLeaseNotFound = errors.New(rpctypes.ErrLeaseNotFound.Error())
+	ErrLeaseNotFound  = errors.New(rpctypes.ErrLeaseNotFound.Error())
+	ErrMemberNotFound = errors.New(rpctypes.ErrMemberNotFound.Error())
+	// ErrPermissionDenied the user has no permission to access the key,
+	// use errors.Is to check it for the error is wrapped with the key
+	ErrPermissionDenied = errors.New(rpctypes.ErrPermissionDenied.Error())
+	ErrAuthFailed       = errors.New(rpctypes.ErrAuthFailed.Error())
+	ErrNotSupported     = errors.New("not supported")
 )
 
 // Client is an abstraction of kv database operator
@@ -49,7 +56,72 @@ type Client interface {
 	Compact(ctx context.Context, reserve int64) error
 	Close()
 
+	// ListCluster returns the configured clusters
 	ListCluster(ctx context.Context) (Clusters, error)
-
 	Status(ctx context.Context) (*StatusResponse, error)
 }
+
+// LeaseInspector is implemented by the clients querying the lease
+type LeaseInspector interface {
+	// LeaseTimeToLive returns the remaining TTL of the lease without renewing
+	LeaseTimeToLive(ctx context.Context, leaseID int64) (TTL int64, err error)
+}
+
+// Defragmenter is implemented by the clients releasing the free space of db
+type Defragmenter interface {
+	// Defragment releases the free space of db, the leader will be the last one
+	Defragment(ctx context.Context) error
+}
+
+// Snapshotter is implemented by the clients backing up db
+type Snapshotter interface {
+	// Snapshot writes a consistent backup of db to w, it can be used to
+	// restore the data dir by EmbeddedConfig.RestoreFile
+	Snapshot(ctx context.Context, w io.Writer) error
+}
+
+// MemberManager is implemented by the clients managing the members of the cluster
+type MemberManager interface {
+	// ListMember returns the live members of the cluster and their health
+	ListMember(ctx context.Context) ([]*Member, error)
+	// AddMember adds a new member with the peer urls, the member
+	// should be started with the existing cluster state later
+	AddMember(ctx context.Context, peerURLs []string, isLearner bool) (*Member, error)
+	RemoveMember(ctx context.Context, id uint64) error
+	// PromoteMember promotes the learner to a voting member,
+	// it fails if the learner is not in sync with the leader
+	PromoteMember(ctx context.Context, id uint64) error
+	UpdateMember(ctx context.Context, id uint64, peerURLs []string) error
+}
+
+func leaseTimeToLive(ctx context.Context, client Client, leaseID int64) (int64, error) {
+	inspector, ok := client.(LeaseInspector)
+	if !ok {
+		return 0, ErrNotSupported
+	}
+	return inspector.LeaseTimeToLive(ctx, leaseID)
+}
+
+func defragment(ctx context.Context, client Client) error {
+	defragmenter, ok := client.(Defragmenter)
+	if !ok {
+		return ErrNotSupported
+	}
+	return defragmenter.Defragment(ctx)
+}
+
+func snapshot(ctx context.Context, client Client, w io.Writer) error {
+	snapshotter, ok := client.(Snapshotter)
+	if !ok {
+		return ErrNotSupported
+	}
+	return snapshotter.Snapshot(ctx, w)
+}
+
+func memberManager(client Client) (MemberManager, error) {
+	manager, ok := client.(MemberManager)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	return manager, nil
+}
@@ -18,9 +18,18 @@
 package etcdadpt
 
 import (
+	"context"
+	"fmt"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/little-cui/etcdadpt/middleware/log"
 )
 
+// MemberHealthCheckTimeout the timeout to check one url of the member
+const MemberHealthCheckTimeout = 5 * time.Second
+
 // ParseClusters convert the cluster url string to Clusters type.
 // The clusterURLs format like 'sc-0=http(s)://host1:port1,http(s)://host2:port2,sc-1=http(s)://host3:port3',
 // managerURLs is optional, set the result value with the key clusterName
@@ -63,3 +72,28 @@ func GetClusterURL(clusterName, clusterURLs, managerURLs string) []string {
 	clusters := ParseClusters(clusterName, clusterURLs, managerURLs)
 	return clusters[clusterName]
 }
+
+// CheckMembersHealth checks the members concurrently, the member is set healthy
+// if any of the urls returned by urlsFunc passes the check in time
+func CheckMembersHealth(ctx context.Context, members []*Member, urlsFunc func(member *Member) []string,
+	check func(ctx context.Context, url string) error) {
+	var wg sync.WaitGroup
+	for _, member := range members {
+		wg.Add(1)
+		go func(member *Member) {
+			defer wg.Done()
+			for _, u := range urlsFunc(member) {
+				hCtx, cancel := context.WithTimeout(ctx, MemberHealthCheckTimeout)
+				err := check(hCtx, u)
+				cancel()
+				if err == nil {
+					member.Healthy = true
+					return
+				}
+				log.GetLogger().Warn(fmt.Sprintf("member %s(%x) url %s is unhealthy, error: %s",
+					member.Name, member.ID, u, err))
+			}
+		}(member)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,614 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+
+	"github.com/little-cui/etcdadpt"
+)
+
+const (
+	outputSimple = "simple"
+	outputJSON   = "json"
+)
+
+func getCommand(ctx context.Context, args []string) error {
+	fs := newFlagSet("get", "<key>")
+	prefix := fs.Bool("prefix", false, "get the keys with the prefix")
+	rev := fs.Int64("rev", 0, "the revision to get")
+	keysOnly := fs.Bool("keys-only", false, "get the keys only")
+	countOnly := fs.Bool("count-only", false, "get the count only")
+	output := fs.String("w", outputSimple, "the output format, can be 'simple' or 'json'")
+	key, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	opts := []etcdadpt.OpOption{etcdadpt.GET, etcdadpt.WithStrKey(key)}
+	if *prefix {
+		opts = append(opts, etcdadpt.WithPrefix())
+	}
+	if *rev > 0 {
+		opts = append(opts, etcdadpt.WithRev(*rev))
+	}
+	if *keysOnly {
+		opts = append(opts, etcdadpt.WithKeyOnly())
+	}
+	if *countOnly {
+		opts = append(opts, etcdadpt.WithCountOnly())
+	}
+	resp, err := etcdadpt.Instance().Do(ctx, opts...)
+	if err != nil {
+		return err
+	}
+	if *countOnly {
+		fmt.Println(resp.Count)
+		return nil
+	}
+	return printKvs(resp.Kvs, *output)
+}
+
+func putCommand(ctx context.Context, args []string) error {
+	fs := newFlagSet("put", "<key> <value>")
+	lease := fs.Int64("lease", 0, "the lease ID to attach")
+	kv, err := parseFlags(fs, args, 2)
+	if err != nil {
+		return err
+	}
+
+	var opts []etcdadpt.OpOption
+	if *lease > 0 {
+		opts = append(opts, etcdadpt.WithLease(*lease))
+	}
+	if err := etcdadpt.Put(ctx, kv[0], kv[1], opts...); err != nil {
+		return err
+	}
+	fmt.Println("OK")
+	return nil
+}
+
+func delCommand(ctx context.Context, args []string) error {
+	fs := newFlagSet("del", "<key>")
+	prefix := fs.Bool("prefix", false, "delete the keys with the prefix")
+	key, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	var opts []etcdadpt.OpOption
+	if *prefix {
+		opts = append(opts, etcdadpt.WithPrefix())
+	}
+	deleted, err := etcdadpt.Delete(ctx, key, opts...)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("deleted: %v\n", deleted)
+	return nil
+}
+
+func listCommand(ctx context.Context, args []string) error {
+	fs := newFlagSet("list", "<prefix>")
+	offset := fs.Int64("offset", 0, "the offset of the page")
+	limit := fs.Int64("limit", 0, "the size of the page, 0 means all the keys")
+	desc := fs.Bool("desc", false, "sort the keys in descend order")
+	keysOnly := fs.Bool("keys-only", false, "list the keys only")
+	output := fs.String("w", outputSimple, "the output format, can be 'simple' or 'json'")
+	prefix, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	var opts []etcdadpt.OpOption
+	if *limit > 0 {
+		opts = append(opts, etcdadpt.WithOffset(*offset), etcdadpt.WithLimit(*limit))
+	}
+	if *desc {
+		opts = append(opts, etcdadpt.WithDescendOrder())
+	}
+	if *keysOnly {
+		opts = append(opts, etcdadpt.WithKeyOnly())
+	}
+	kvs, count, err := etcdadpt.List(ctx, prefix, opts...)
+	if err != nil {
+		return err
+	}
+	if err := printKvs(kvs, *output); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "total: %d\n", count)
+	return nil
+}
+
+func watchCommand(ctx context.Context, args []string) error {
+	fs := newFlagSet("watch", "<key>")
+	prefix := fs.Bool("prefix", false, "watch the keys with the prefix")
+	rev := fs.Int64("rev", 0, "the revision to start watching")
+	output := fs.String("w", outputSimple, "the output format, can be 'simple' or 'json'")
+	key, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	opts := []etcdadpt.OpOption{etcdadpt.WithStrKey(key), etcdadpt.WithPrevKv()}
+	if *prefix {
+		opts = append(opts, etcdadpt.WithPrefix())
+	}
+	return resumeWatch(ctx, etcdadpt.Instance().Watch, *rev, time.Second, func(message string, evt *etcdadpt.Response) error {
+		fmt.Println(evt.Action)
+		return printKvs(evt.Kvs, *output)
+	}, opts...)
+}
+
+// watchFunc is the same as etcdadpt.Client.Watch
+type watchFunc func(ctx context.Context, opts ...etcdadpt.OpOption) error
+
+// resumeWatch watches again after the last seen event until ctx is done or cb failed,
+// it resumes from the current revision if the next revision has been compacted
+func resumeWatch(ctx context.Context, watch watchFunc, rev int64, interval time.Duration,
+	cb etcdadpt.WatchCallback, opts ...etcdadpt.OpOption) error {
+	nextRev := rev
+	var cbErr error
+	opts = append(opts[:len(opts):len(opts)], etcdadpt.WithWatchCallback(func(message string, evt *etcdadpt.Response) error {
+		if evt.Revision >= nextRev {
+			nextRev = evt.Revision + 1
+		}
+		cbErr = cb(message, evt)
+		return cbErr
+	}))
+	for {
+		watchOpts := opts
+		if nextRev > 0 {
+			watchOpts = append(opts[:len(opts):len(opts)], etcdadpt.WithRev(nextRev))
+		}
+		err := watch(ctx, watchOpts...)
+		switch {
+		case ctx.Err() != nil:
+			return nil
+		case cbErr != nil:
+			return cbErr
+		case errors.Is(err, rpctypes.ErrCompacted):
+			fmt.Fprintf(os.Stderr, "the revision %d has been compacted, watch from the current revision\n", nextRev)
+			nextRev = 0
+		case err != nil:
+			// the watch channel closed or the connection lost
+			fmt.Fprintf(os.Stderr, "watch failed: %s, watch again\n", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+func txnCommand(ctx context.Context, args []string) error {
+	fs := newFlagSet("txn", "")
+	file := fs.String("f", "-", "the JSON spec file, '-' means stdin")
+	if _, err := parseFlags(fs, args, 0); err != nil {
+		return err
+	}
+
+	r, closeFunc, err := openInput(*file)
+	if err != nil {
+		return err
+	}
+	defer closeFunc()
+	req, err := parseTxnSpec(r)
+	if err != nil {
+		return err
+	}
+
+	resp, err := etcdadpt.Instance().TxnWithCmp(ctx, req.Success, req.Compare, req.Failure)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("succeeded: %v\n", resp.Succeeded)
+	return printKvs(resp.Kvs, outputSimple)
+}
+
+func lockCommand(ctx context.Context, args []string) error {
+	fs := newFlagSet("lock", "<name>")
+	ttl := fs.Int64("ttl", etcdadpt.DefaultLockTTL, "the TTL of the lock in seconds, it is refreshed until unlocked")
+	try := fs.Bool("try", false, "return immediately if the name is locked")
+	name, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if *ttl < 1 {
+		return fmt.Errorf("%w, the ttl should be greater than 0", ErrInvalidArgs)
+	}
+
+	// interrupting cancels the acquisition or releases the lock
+	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	var lock *etcdadpt.DLock
+	if *try {
+		lock, err = etcdadpt.TryLockContext(ctx, name, *ttl)
+	} else {
+		lock, err = etcdadpt.LockContext(ctx, name, *ttl)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("locked %s/%s, id: %s\n", etcdadpt.DefaultLock, name, lock.ID())
+
+	ticker := time.NewTicker(time.Duration(*ttl) * time.Second / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return lock.Unlock()
+		case <-ticker.C:
+			if err := lock.Refresh(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func unlockCommand(ctx context.Context, args []string) error {
+	fs := newFlagSet("unlock", "<name>")
+	name, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	deleted, err := etcdadpt.Delete(ctx, etcdadpt.DefaultLock+"/"+name)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("unlocked: %v\n", deleted)
+	return nil
+}
+
+func leaseCommand(ctx context.Context, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("%w, usage: etcdadpt lease grant <ttl> | revoke <id> | ttl <id>", ErrInvalidArgs)
+	}
+	n, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w, %s", ErrInvalidArgs, err)
+	}
+
+	switch args[0] {
+	case "grant":
+		leaseID, err := etcdadpt.Instance().LeaseGrant(ctx, n)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("lease %d granted with TTL(%ds)\n", leaseID, n)
+	case "revoke":
+		if err := etcdadpt.Instance().LeaseRevoke(ctx, n); err != nil {
+			return err
+		}
+		fmt.Printf("lease %d revoked\n", n)
+	case "ttl":
+		ttl, err := etcdadpt.LeaseTimeToLive(ctx, n)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("lease %d remaining TTL(%ds)\n", n, ttl)
+	default:
+		return fmt.Errorf("%w, unknown lease command %q", ErrInvalidArgs, args[0])
+	}
+	return nil
+}
+
+func compactCommand(ctx context.Context, args []string) error {
+	fs := newFlagSet("compact", "<reserve>")
+	arg, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	reserve, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil || reserve < 0 {
+		return fmt.Errorf("%w, the reserve should be a non-negative integer", ErrInvalidArgs)
+	}
+	if err := etcdadpt.Instance().Compact(ctx, reserve); err != nil {
+		return err
+	}
+	fmt.Println("OK")
+	return nil
+}
+
+func statusCommand(ctx context.Context, args []string) error {
+	fs := newFlagSet("status", "")
+	output := fs.String("w", outputSimple, "the output format, can be 'simple' or 'json'")
+	if _, err := parseFlags(fs, args, 0); err != nil {
+		return err
+	}
+
+	status, err := etcdadpt.Instance().Status(ctx)
+	if err != nil {
+		return err
+	}
+	if *output == outputJSON {
+		return json.NewEncoder(os.Stdout).Encode(status)
+	}
+	fmt.Printf("member: %x\nleader: %x\nversion: %s\nraft term: %d\nraft index: %d\n"+
+		"raft applied index: %d\nrevision: %d\ndb size: %d\ndb size in use: %d\nfragmentation: %.2f\n",
+		status.MemberID, status.Leader, status.Version, status.RaftTerm, status.RaftIndex,
+		status.RaftAppliedIndex, status.Revision, status.DBSize, status.DBSizeInUse, status.Fragmentation())
+	for _, alarm := range status.Alarms {
+		fmt.Printf("alarm: %s on member %x\n", alarm.Type, alarm.MemberID)
+	}
+	if len(status.AlarmsError) > 0 {
+		fmt.Printf("alarms: error: %s\n", status.AlarmsError)
+	}
+	for _, ep := range status.Endpoints {
+		if len(ep.Error) > 0 {
+			fmt.Printf("endpoint %s: error: %s\n", ep.Endpoint, ep.Error)
+			continue
+		}
+		fmt.Printf("endpoint %s: member: %x, learner: %v, raft index: %d, revision: %d, db size: %d, errors: %v\n",
+			ep.Endpoint, ep.MemberID, ep.IsLearner, ep.RaftIndex, ep.Revision, ep.DBSize, ep.Errors)
+	}
+	return nil
+}
+
+func clusterCommand(ctx context.Context, args []string) error {
+	if len(args) != 1 || args[0] != "list" {
+		return fmt.Errorf("%w, usage: etcdadpt cluster list", ErrInvalidArgs)
+	}
+	clusters, err := etcdadpt.ListCluster(ctx)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(clusters))
+	for name := range clusters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("%s=%s\n", name, strings.Join(clusters[name], ","))
+	}
+	return nil
+}
+
+func memberCommand(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w, usage: etcdadpt member list | add | remove | promote | update", ErrInvalidArgs)
+	}
+	switch args[0] {
+	case "list":
+		return memberListCommand(ctx, args[1:])
+	case "add":
+		return memberAddCommand(ctx, args[1:])
+	}
+
+	fs := newFlagSet("member "+args[0], "<id>")
+	n := 1
+	if args[0] == "update" {
+		fs = newFlagSet("member update", "<id> <peer urls>")
+		n = 2
+	}
+	positional, err := parseFlags(fs, args[1:], n)
+	if err != nil {
+		return err
+	}
+	id, err := strconv.ParseUint(positional[0], 16, 64)
+	if err != nil {
+		return fmt.Errorf("%w, the member ID should be hex, %s", ErrInvalidArgs, err)
+	}
+	switch args[0] {
+	case "remove":
+		err = etcdadpt.RemoveMember(ctx, id)
+	case "promote":
+		err = etcdadpt.PromoteMember(ctx, id)
+	case "update":
+		err = etcdadpt.UpdateMember(ctx, id, strings.Split(positional[1], ","))
+	default:
+		return fmt.Errorf("%w, unknown member command %q", ErrInvalidArgs, args[0])
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Println("OK")
+	return nil
+}
+
+func memberListCommand(ctx context.Context, args []string) error {
+	fs := newFlagSet("member list", "")
+	output := fs.String("w", outputSimple, "the output format, can be 'simple' or 'json'")
+	if _, err := parseFlags(fs, args, 0); err != nil {
+		return err
+	}
+
+	members, err := etcdadpt.ListMember(ctx)
+	if err != nil {
+		return err
+	}
+	if *output == outputJSON {
+		return json.NewEncoder(os.Stdout).Encode(members)
+	}
+	for _, m := range members {
+		fmt.Printf("%x, name: %s, peer urls: %s, client urls: %s, learner: %v, healthy: %v\n",
+			m.ID, m.Name, strings.Join(m.PeerURLs, ","), strings.Join(m.ClientURLs, ","), m.IsLearner, m.Healthy)
+	}
+	return nil
+}
+
+func memberAddCommand(ctx context.Context, args []string) error {
+	fs := newFlagSet("member add", "<peer urls>")
+	learner := fs.Bool("learner", false, "add the member as a learner")
+	urls, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	member, err := etcdadpt.AddMember(ctx, strings.Split(urls, ","), *learner)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("member %x added, learner: %v\n", member.ID, member.IsLearner)
+	return nil
+}
+
+func exportCommand(ctx context.Context, args []string) error {
+	fs := newFlagSet("export", "<prefix>")
+	file := fs.String("o", "-", "the output file, '-' means stdout")
+	prefix, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	w := io.Writer(os.Stdout)
+	if *file != "-" {
+		f, err := os.Create(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	n, err := etcdadpt.Export(ctx, w, prefix)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "exported: %d\n", n)
+	return nil
+}
+
+func importCommand(ctx context.Context, args []string) error {
+	fs := newFlagSet("import", "")
+	file := fs.String("f", "-", "the JSON Lines file, '-' means stdin")
+	mode := fs.String("mode", "skip", "the mode if the key exists, can be 'skip', 'overwrite' or 'fail'")
+	batch := fs.Int("batch", etcdadpt.MaxTxnNumberOneTime, "the number of the records put in one txn")
+	if _, err := parseFlags(fs, args, 0); err != nil {
+		return err
+	}
+
+	var importMode etcdadpt.ImportMode
+	switch *mode {
+	case "skip":
+		importMode = etcdadpt.ImportSkipExisting
+	case "overwrite":
+		importMode = etcdadpt.ImportOverwrite
+	case "fail":
+		importMode = etcdadpt.ImportFailOnConflict
+	default:
+		return fmt.Errorf("%w, unknown import mode %q", ErrInvalidArgs, *mode)
+	}
+
+	r, closeFunc, err := openInput(*file)
+	if err != nil {
+		return err
+	}
+	defer closeFunc()
+	result, err := etcdadpt.Import(ctx, r, importMode, etcdadpt.WithImportBatchSize(*batch))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("imported: %d, skipped: %d\n", result.Imported, result.Skipped)
+	return nil
+}
+
+// parseArgs parses the flags and returns the only one argument
+func parseArgs(fs *flag.FlagSet, args []string) (string, error) {
+	positional, err := parseFlags(fs, args, 1)
+	if err != nil {
+		return "", err
+	}
+	return positional[0], nil
+}
+
+// parseFlags parses the flags which can be interspersed with
+// the arguments, and the number of arguments should be n
+func parseFlags(fs *flag.FlagSet, args []string, n int) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		rest := fs.Args()
+		if i := len(args) - len(rest); i > 0 && args[i-1] == "--" {
+			// the arguments after the terminator are not flags
+			positional = append(positional, rest...)
+			break
+		}
+		args = rest
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+	if len(positional) != n {
+		fs.Usage()
+		return nil, fmt.Errorf("%w, required %d argument(s)", ErrInvalidArgs, n)
+	}
+	return positional, nil
+}
+
+func openInput(file string) (io.Reader, func(), error) {
+	if file == "-" {
+		return os.Stdin, func() {}, nil
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, func() { f.Close() }, nil
+}
+
+func printKvs(kvs []*mvccpb.KeyValue, output string) error {
+	switch output {
+	case outputSimple:
+		for _, kv := range kvs {
+			fmt.Printf("%s\n%s\n", kv.Key, kv.Value)
+		}
+	case outputJSON:
+		encoder := json.NewEncoder(os.Stdout)
+		for _, kv := range kvs {
+			err := encoder.Encode(&etcdadpt.ExportRecord{
+				Key:            string(kv.Key),
+				Value:          kv.Value,
+				Lease:          kv.Lease,
+				CreateRevision: kv.CreateRevision,
+				ModRevision:    kv.ModRevision,
+				Version:        kv.Version,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("%w, unknown output format %q", ErrInvalidArgs, output)
+	}
+	return nil
+}
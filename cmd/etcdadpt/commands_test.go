@@ -0,0 +1,71 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/little-cui/etcdadpt"
+	"github.com/stretchr/testify/assert"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+)
+
+func TestResumeWatch(t *testing.T) {
+	t.Run("watch closed or compacted should be resumed", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var revs []int64
+		watch := func(ctx context.Context, opts ...etcdadpt.OpOption) error {
+			op := etcdadpt.OpGet(opts...)
+			revs = append(revs, op.Revision)
+			switch len(revs) {
+			case 1:
+				assert.NoError(t, op.WatchCallback("", &etcdadpt.Response{Revision: 5}))
+				return errors.New("channel is closed")
+			case 2:
+				return rpctypes.ErrCompacted
+			default:
+				cancel()
+				return nil
+			}
+		}
+		var events int
+		err := resumeWatch(ctx, watch, 3, time.Millisecond, func(message string, evt *etcdadpt.Response) error {
+			events++
+			return nil
+		}, etcdadpt.WithStrKey("/test_watch"))
+		assert.NoError(t, err)
+		assert.Equal(t, 1, events)
+		// resume after the last seen event, then from the current revision if compacted
+		assert.Equal(t, []int64{3, 6, 0}, revs)
+	})
+
+	t.Run("callback failed should return the error", func(t *testing.T) {
+		cbErr := errors.New("print failed")
+		watch := func(ctx context.Context, opts ...etcdadpt.OpOption) error {
+			return etcdadpt.OpGet(opts...).WatchCallback("", &etcdadpt.Response{Revision: 1})
+		}
+		err := resumeWatch(context.Background(), watch, 0, time.Millisecond, func(message string, evt *etcdadpt.Response) error {
+			return cbErr
+		}, etcdadpt.WithStrKey("/test_watch"))
+		assert.Equal(t, cbErr, err)
+	})
+}
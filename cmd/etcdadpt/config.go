@@ -0,0 +1,196 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"go.etcd.io/etcd/client/pkg/v3/transport"
+
+	"github.com/little-cui/etcdadpt"
+)
+
+const (
+	kindEtcd     = "etcd"
+	kindEmbedded = "embedded_etcd"
+)
+
+type globalFlags struct {
+	configFile  string
+	kind        string
+	endpoints   string
+	name        string
+	certFile    string
+	keyFile     string
+	caFile      string
+	user        string
+	password    string
+	dialTimeout time.Duration
+	timeout     time.Duration
+	debug       bool
+}
+
+func newGlobalFlags(fs *flag.FlagSet) *globalFlags {
+	f := &globalFlags{}
+	fs.StringVar(&f.configFile, "config", "", "the JSON file of etcdadpt.Config, the flags take precedence over it")
+	fs.StringVar(&f.kind, "kind", "", "the kind of deployment, can be 'etcd' or 'embedded_etcd'(default 'etcd')")
+	fs.StringVar(&f.endpoints, "endpoints", "", "the cluster addresses, like '127.0.0.1:2379' or 'sc-0=http://host1:port1,sc-1=http://host2:port2'")
+	fs.StringVar(&f.name, "name", "", "the cluster name to select from the endpoints")
+	fs.StringVar(&f.certFile, "cert", "", "the client certificate file")
+	fs.StringVar(&f.keyFile, "key", "", "the client key file")
+	fs.StringVar(&f.caFile, "cacert", "", "the CA file to verify the server certificates")
+	fs.StringVar(&f.user, "user", "", "the user name, or 'user:password'")
+	fs.StringVar(&f.password, "password", "", "the password of the user")
+	fs.DurationVar(&f.dialTimeout, "dial-timeout", 0, "the timeout to dial the endpoints")
+	fs.DurationVar(&f.timeout, "timeout", etcdadpt.DefaultRequestTimeout, "the timeout of the command, except watch and lock")
+	fs.BoolVar(&f.debug, "debug", false, "print the adapter logs")
+	return f
+}
+
+// Config returns the adapter config, the CLI connects to the client endpoints as
+// a remote client, for embedded etcd they are the endpoints of all the members.
+// If the embedded member exposes no client endpoints, the CLI runs as the member
+// by its config, so the member process should be stopped first
+func (f *globalFlags) Config() (etcdadpt.Config, error) {
+	cfg := etcdadpt.Config{}
+	if len(f.configFile) > 0 {
+		data, err := os.ReadFile(f.configFile)
+		if err != nil {
+			return cfg, err
+		}
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return cfg, fmt.Errorf("parse %s failed, %w", f.configFile, err)
+		}
+	}
+	setIfNotEmpty(&cfg.ClusterAddresses, f.endpoints)
+	setIfNotEmpty(&cfg.ClusterName, f.name)
+	setIfNotEmpty(&cfg.CertFile, f.certFile)
+	setIfNotEmpty(&cfg.KeyFile, f.keyFile)
+	setIfNotEmpty(&cfg.CAFile, f.caFile)
+	if pair := strings.SplitN(f.user, ":", 2); len(pair) == 2 && len(f.password) == 0 {
+		cfg.Username, cfg.Password = pair[0], pair[1]
+	} else {
+		setIfNotEmpty(&cfg.Username, f.user)
+		setIfNotEmpty(&cfg.Password, f.password)
+	}
+	if f.dialTimeout > 0 {
+		cfg.DialTimeout = f.dialTimeout
+	}
+	cfg.Logger = &logger{debug: f.debug}
+
+	kind := f.kind
+	if len(kind) == 0 {
+		kind = kindEtcd
+	}
+	switch kind {
+	case kindEtcd:
+		if len(f.name) == 0 && len(cfg.ClusterName) == 0 {
+			name, err := singleCluster(cfg.ClusterAddresses)
+			if err != nil {
+				return cfg, err
+			}
+			cfg.ClusterName = name
+		}
+	case kindEmbedded:
+		if len(cfg.ClusterAddresses) == 0 && len(cfg.ManagerAddress) > 0 {
+			return memberConfig(cfg), nil
+		}
+		if len(f.name) == 0 {
+			cfg.ClusterAddresses = memberEndpoints(cfg.ClusterAddresses)
+		}
+	default:
+		return cfg, fmt.Errorf("%w, unknown kind %q", ErrInvalidArgs, kind)
+	}
+	if len(cfg.ClusterAddresses) == 0 {
+		return cfg, fmt.Errorf("%w, required endpoints", ErrInvalidArgs)
+	}
+	cfg.Kind = kindEtcd
+	cfg.Init()
+
+	if len(cfg.CertFile) > 0 || len(cfg.CAFile) > 0 ||
+		strings.Contains(cfg.ClusterAddresses, "https://") {
+		tlsInfo := transport.TLSInfo{
+			CertFile:      cfg.CertFile,
+			KeyFile:       cfg.KeyFile,
+			TrustedCAFile: cfg.CAFile,
+		}
+		tlsConfig, err := tlsInfo.ClientConfig()
+		if err != nil {
+			return cfg, err
+		}
+		cfg.SslEnabled = true
+		cfg.TLSConfig = tlsConfig
+	}
+	return cfg, nil
+}
+
+// memberConfig returns the config to run as the embedded member,
+// the certificates are used as the server ones
+func memberConfig(cfg etcdadpt.Config) etcdadpt.Config {
+	cfg.Kind = kindEmbedded
+	cfg.Init()
+	if len(cfg.CertFile) > 0 || strings.Contains(cfg.ManagerAddress, "https://") {
+		cfg.SslEnabled = true
+	}
+	return cfg
+}
+
+// singleCluster returns the name if only one cluster in the addresses
+func singleCluster(clusterAddrs string) (string, error) {
+	if !strings.Contains(clusterAddrs, "=") {
+		return "", nil
+	}
+	clusters := etcdadpt.ParseClusters("", clusterAddrs, "")
+	if len(clusters) > 1 {
+		return "", fmt.Errorf("%w, required the cluster name in %s", ErrInvalidArgs, clusterAddrs)
+	}
+	for name := range clusters {
+		return name, nil
+	}
+	return "", nil
+}
+
+// memberEndpoints flattens the client endpoints of all the members
+func memberEndpoints(clusterAddrs string) string {
+	if !strings.Contains(clusterAddrs, "=") {
+		return clusterAddrs
+	}
+	members := etcdadpt.ParseClusters("", clusterAddrs, "")
+	names := make([]string, 0, len(members))
+	for name := range members {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var urls []string
+	for _, name := range names {
+		urls = append(urls, members[name]...)
+	}
+	return strings.Join(urls, ",")
+}
+
+func setIfNotEmpty(dst *string, s string) {
+	if len(s) > 0 {
+		*dst = s
+	}
+}
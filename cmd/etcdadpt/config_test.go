@@ -0,0 +1,149 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestFlags(t *testing.T, args ...string) *globalFlags {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	f := newGlobalFlags(fs)
+	assert.NoError(t, fs.Parse(args))
+	return f
+}
+
+func TestGlobalFlags_Config(t *testing.T) {
+	t.Run("remote etcd should use the endpoints", func(t *testing.T) {
+		cfg, err := newTestFlags(t, "-endpoints", "127.0.0.1:2379").Config()
+		assert.NoError(t, err)
+		assert.Equal(t, kindEtcd, cfg.Kind)
+		assert.Equal(t, "127.0.0.1:2379", cfg.ClusterAddresses)
+		assert.False(t, cfg.SslEnabled)
+	})
+
+	t.Run("remote etcd with only one named cluster should select it", func(t *testing.T) {
+		cfg, err := newTestFlags(t, "-endpoints", "sc-0=http://127.0.0.1:2379").Config()
+		assert.NoError(t, err)
+		assert.Equal(t, "sc-0", cfg.ClusterName)
+	})
+
+	t.Run("remote etcd with multiple clusters should require the name", func(t *testing.T) {
+		_, err := newTestFlags(t, "-endpoints", "sc-0=http://127.0.0.1:2379,sc-1=http://127.0.0.1:3379").Config()
+		assert.True(t, errors.Is(err, ErrInvalidArgs))
+
+		cfg, err := newTestFlags(t, "-name", "sc-1",
+			"-endpoints", "sc-0=http://127.0.0.1:2379,sc-1=http://127.0.0.1:3379").Config()
+		assert.NoError(t, err)
+		assert.Equal(t, "sc-1", cfg.ClusterName)
+	})
+
+	t.Run("embedded etcd should connect to all the members", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "config.json")
+		err := os.WriteFile(file, []byte(`{"manageName":"sc-1",
+			"manageClusters":"sc-1=http://127.0.0.2:2379,sc-0=http://127.0.0.1:2379"}`), 0600)
+		assert.NoError(t, err)
+
+		cfg, err := newTestFlags(t, "-kind", kindEmbedded, "-config", file).Config()
+		assert.NoError(t, err)
+		assert.Equal(t, kindEtcd, cfg.Kind)
+		assert.Equal(t, "http://127.0.0.1:2379,http://127.0.0.2:2379", cfg.ClusterAddresses)
+	})
+
+	t.Run("embedded etcd without client endpoints should run as the member", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "config.json")
+		err := os.WriteFile(file, []byte(`{"manageName":"sc-1",
+			"manageAddress":"sc-1=https://127.0.0.2:2380,sc-0=https://127.0.0.1:2380"}`), 0600)
+		assert.NoError(t, err)
+
+		cfg, err := newTestFlags(t, "-kind", kindEmbedded, "-config", file).Config()
+		assert.NoError(t, err)
+		assert.Equal(t, kindEmbedded, cfg.Kind)
+		assert.Equal(t, "sc-1", cfg.ClusterName)
+		assert.Empty(t, cfg.ClusterAddresses)
+		assert.True(t, cfg.SslEnabled)
+		assert.Nil(t, cfg.TLSConfig)
+	})
+
+	t.Run("https endpoints should enable TLS", func(t *testing.T) {
+		cfg, err := newTestFlags(t, "-endpoints", "https://127.0.0.1:2379").Config()
+		assert.NoError(t, err)
+		assert.True(t, cfg.SslEnabled)
+		assert.NotNil(t, cfg.TLSConfig)
+	})
+
+	t.Run("user with password should be split", func(t *testing.T) {
+		cfg, err := newTestFlags(t, "-endpoints", "127.0.0.1:2379", "-user", "app:a:b").Config()
+		assert.NoError(t, err)
+		assert.Equal(t, "app", cfg.Username)
+		assert.Equal(t, "a:b", cfg.Password)
+
+		cfg, err = newTestFlags(t, "-endpoints", "127.0.0.1:2379", "-user", "app", "-password", "p").Config()
+		assert.NoError(t, err)
+		assert.Equal(t, "app", cfg.Username)
+		assert.Equal(t, "p", cfg.Password)
+	})
+
+	t.Run("invalid kind or no endpoints should return error", func(t *testing.T) {
+		_, err := newTestFlags(t, "-kind", "x", "-endpoints", "127.0.0.1:2379").Config()
+		assert.True(t, errors.Is(err, ErrInvalidArgs))
+
+		_, err = newTestFlags(t).Config()
+		assert.True(t, errors.Is(err, ErrInvalidArgs))
+	})
+}
+
+func TestParseFlags(t *testing.T) {
+	newFS := func() (*flag.FlagSet, *bool) {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(new(nopWriter))
+		return fs, fs.Bool("prefix", false, "")
+	}
+
+	t.Run("flags interspersed with arguments should be parsed", func(t *testing.T) {
+		fs, prefix := newFS()
+		args, err := parseFlags(fs, []string{"/a", "-prefix", "b"}, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"/a", "b"}, args)
+		assert.True(t, *prefix)
+	})
+
+	t.Run("arguments after the terminator should not be flags", func(t *testing.T) {
+		fs, prefix := newFS()
+		args, err := parseFlags(fs, []string{"--", "/a", "-prefix"}, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"/a", "-prefix"}, args)
+		assert.False(t, *prefix)
+	})
+
+	t.Run("unexpected number of arguments should return error", func(t *testing.T) {
+		fs, _ := newFS()
+		_, err := parseFlags(fs, []string{"/a", "b"}, 1)
+		assert.True(t, errors.Is(err, ErrInvalidArgs))
+	})
+}
+
+type nopWriter struct{}
+
+func (nopWriter) Write(p []byte) (int, error) { return len(p), nil }
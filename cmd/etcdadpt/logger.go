@@ -0,0 +1,69 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/go-chassis/openlog"
+)
+
+// logger writes the adapter logs to stderr, so that the stdout only
+// contains the command output, the logs under error level are
+// printed in debug mode only
+type logger struct {
+	debug bool
+	// out is os.Stderr if nil
+	out io.Writer
+}
+
+func (l *logger) Debug(msg string, opts ...openlog.Option) { l.print("DEBUG", msg, false, opts) }
+func (l *logger) Info(msg string, opts ...openlog.Option)  { l.print("INFO", msg, false, opts) }
+func (l *logger) Warn(msg string, opts ...openlog.Option)  { l.print("WARN", msg, false, opts) }
+func (l *logger) Error(msg string, opts ...openlog.Option) { l.print("ERROR", msg, true, opts) }
+func (l *logger) Fatal(msg string, opts ...openlog.Option) { l.print("FATAL", msg, true, opts) }
+
+// print writes the tags sorted by key and the error after the message
+func (l *logger) print(level, message string, force bool, opts []openlog.Option) {
+	if !force && !l.debug {
+		return
+	}
+	out := l.out
+	if out == nil {
+		out = os.Stderr
+	}
+	var b strings.Builder
+	b.WriteString(level + ": " + message)
+	o := openlog.ToOptions(opts...)
+	keys := make([]string, 0, len(o.Tags))
+	for k := range o.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&b, " %s=%v", k, o.Tags[k])
+	}
+	if o.Err != nil {
+		fmt.Fprintf(&b, " error=%v", o.Err)
+	}
+	fmt.Fprintln(out, b.String())
+}
@@ -0,0 +1,48 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/go-chassis/openlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogger_Print(t *testing.T) {
+	t.Run("log with tags and error, should print them after the message", func(t *testing.T) {
+		var out bytes.Buffer
+		l := &logger{out: &out}
+		l.Error("lock failed", openlog.WithTags(openlog.Tags{"key": "/a", "id": 1}),
+			openlog.WithErr(errors.New("timeout")))
+		assert.Equal(t, "ERROR: lock failed id=1 key=/a error=timeout\n", out.String())
+	})
+
+	t.Run("log under error level without debug, should print nothing", func(t *testing.T) {
+		var out bytes.Buffer
+		l := &logger{out: &out}
+		l.Warn("retry the operation", openlog.WithTags(openlog.Tags{"key": "/a"}))
+		assert.Empty(t, out.String())
+
+		l.debug = true
+		l.Warn("retry the operation", openlog.WithTags(openlog.Tags{"key": "/a"}))
+		assert.Equal(t, "WARN: retry the operation key=/a\n", out.String())
+	})
+}
@@ -0,0 +1,172 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Command etcdadpt is the command-line tool to operate the kv database
+// through the etcd adapter, it also works with the members of embedded etcd
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	_ "github.com/little-cui/etcdadpt/embedded"
+	_ "github.com/little-cui/etcdadpt/remote"
+
+	"github.com/little-cui/etcdadpt"
+	"github.com/little-cui/etcdadpt/middleware/log"
+)
+
+const usage = `Usage: etcdadpt [global flags] <command> [flags] [args]
+
+Commands:
+  get <key>                  get the key, or the keys with the prefix
+  put <key> <value>          put the key
+  del <key>                  delete the key, or the keys with the prefix
+  list <prefix>              list the keys with the prefix by paging
+  watch <key>                watch the key, or the keys with the prefix
+  txn                        execute a transaction from the JSON spec
+  lock <name>                lock the name until interrupted
+  unlock <name>              release the lock of the name forcibly
+  lease grant <ttl>          grant a lease
+  lease revoke <id>          revoke the lease
+  lease ttl <id>             get the remaining TTL of the lease
+  compact <reserve>          compact the history and reserve the latest revisions
+  status                     print the db status
+  cluster list               list the clusters
+  member list                list the members and their health
+  member add <peer urls>     add a member, the urls are separated by comma
+  member remove <id>         remove the member by the hex ID
+  member promote <id>        promote the learner by the hex ID
+  member update <id> <urls>  update the peer urls of the member
+  export <prefix>            export the keys with the prefix in JSON Lines
+  import                     import the keys from the JSON Lines
+
+Run 'etcdadpt <command> -h' for the command flags.
+
+Global flags:
+`
+
+var ErrInvalidArgs = errors.New("invalid arguments")
+
+type command func(ctx context.Context, args []string) error
+
+var commands = map[string]command{
+	"get":     getCommand,
+	"put":     putCommand,
+	"del":     delCommand,
+	"list":    listCommand,
+	"watch":   watchCommand,
+	"txn":     txnCommand,
+	"lock":    lockCommand,
+	"unlock":  unlockCommand,
+	"lease":   leaseCommand,
+	"compact": compactCommand,
+	"status":  statusCommand,
+	"cluster": clusterCommand,
+	"member":  memberCommand,
+	"export":  exportCommand,
+	"import":  importCommand,
+}
+
+func main() {
+	flags := newGlobalFlags(flag.CommandLine)
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", args[0])
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := flags.Config()
+	if err != nil {
+		exit(err)
+	}
+	log.SetLogger(cfg.Logger)
+	client, err := newInstance(cfg)
+	if err != nil {
+		exit(err)
+	}
+	defer client.Close()
+	etcdadpt.SetInstance(client)
+
+	ctx := context.Background()
+	if flags.timeout > 0 && args[0] != "watch" && args[0] != "lock" {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, flags.timeout)
+		defer cancel()
+	}
+	if err := cmd(ctx, args[1:]); err != nil {
+		client.Close()
+		exit(err)
+	}
+}
+
+// newInstance creates the client, the embedded member blocks to open the data dir
+// if it is still used by the member process, so it is limited by the dial timeout
+func newInstance(cfg etcdadpt.Config) (etcdadpt.Client, error) {
+	if cfg.Kind != kindEmbedded {
+		return etcdadpt.NewInstance(cfg)
+	}
+	type result struct {
+		client etcdadpt.Client
+		err    error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		client, err := etcdadpt.NewInstance(cfg)
+		ch <- result{client: client, err: err}
+	}()
+	select {
+	case r := <-ch:
+		return r.client, r.err
+	case <-time.After(cfg.DialTimeout):
+		return nil, fmt.Errorf("start the member %s timed out, "+
+			"stop the member process first and make sure the quorum is alive", cfg.ClusterName)
+	}
+}
+
+func exit(err error) {
+	fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+	if errors.Is(err, ErrInvalidArgs) || errors.Is(err, flag.ErrHelp) {
+		os.Exit(2)
+	}
+	os.Exit(1)
+}
+
+func newFlagSet(name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: etcdadpt %s [flags] %s\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
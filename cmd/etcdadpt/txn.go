@@ -0,0 +1,168 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/little-cui/etcdadpt"
+)
+
+// txnSpec is the JSON spec of a transaction, for example
+//
+//	{
+//	  "compare": [{"key": "/a", "target": "version", "result": "=", "value": 0}],
+//	  "success": [{"action": "put", "key": "/a", "value": "1"}],
+//	  "failure": [{"action": "get", "key": "/a"}]
+//	}
+type txnSpec struct {
+	Compare []cmpSpec `json:"compare"`
+	Success []opSpec  `json:"success"`
+	Failure []opSpec  `json:"failure"`
+}
+
+// cmpSpec target can be 'version', 'create', 'mod' or 'value',
+// result can be '=', '!=', '>' or '<'
+type cmpSpec struct {
+	Key    string          `json:"key"`
+	Target string          `json:"target"`
+	Result string          `json:"result"`
+	Value  json.RawMessage `json:"value"`
+}
+
+// opSpec action can be 'get', 'put' or 'delete'
+type opSpec struct {
+	Action string `json:"action"`
+	Key    string `json:"key"`
+	EndKey string `json:"endKey,omitempty"`
+	Value  string `json:"value,omitempty"`
+	Prefix bool   `json:"prefix,omitempty"`
+	Lease  int64  `json:"lease,omitempty"`
+}
+
+type txnRequest struct {
+	Compare []etcdadpt.CmpOptions
+	Success []etcdadpt.OpOptions
+	Failure []etcdadpt.OpOptions
+}
+
+func parseTxnSpec(r io.Reader) (*txnRequest, error) {
+	var spec txnSpec
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&spec); err != nil {
+		return nil, fmt.Errorf("%w, parse txn spec failed, %s", ErrInvalidArgs, err)
+	}
+	if len(spec.Success) == 0 && len(spec.Failure) == 0 {
+		return nil, fmt.Errorf("%w, required success or failure operations", ErrInvalidArgs)
+	}
+
+	req := &txnRequest{}
+	for _, c := range spec.Compare {
+		cmp, err := c.toCmp()
+		if err != nil {
+			return nil, err
+		}
+		req.Compare = append(req.Compare, cmp)
+	}
+	var err error
+	if req.Success, err = toOps(spec.Success); err != nil {
+		return nil, err
+	}
+	if req.Failure, err = toOps(spec.Failure); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+func (c cmpSpec) toCmp() (etcdadpt.CmpOptions, error) {
+	cmp := etcdadpt.CmpOptions{Key: []byte(c.Key)}
+	if len(c.Key) == 0 {
+		return cmp, fmt.Errorf("%w, required compare key", ErrInvalidArgs)
+	}
+	switch c.Result {
+	case "=":
+		cmp.Result = etcdadpt.CmpEqual
+	case "!=":
+		cmp.Result = etcdadpt.CmpNotEqual
+	case ">":
+		cmp.Result = etcdadpt.CmpGreater
+	case "<":
+		cmp.Result = etcdadpt.CmpLess
+	default:
+		return cmp, fmt.Errorf("%w, unknown compare result %q", ErrInvalidArgs, c.Result)
+	}
+
+	switch c.Target {
+	case "value":
+		cmp.Type = etcdadpt.CmpValue
+		var v string
+		if err := json.Unmarshal(c.Value, &v); err != nil {
+			return cmp, fmt.Errorf("%w, the value of %s should be a string", ErrInvalidArgs, c.Key)
+		}
+		cmp.Value = v
+		return cmp, nil
+	case "version":
+		cmp.Type = etcdadpt.CmpVersion
+	case "create":
+		cmp.Type = etcdadpt.CmpCreate
+	case "mod":
+		cmp.Type = etcdadpt.CmpMod
+	default:
+		return cmp, fmt.Errorf("%w, unknown compare target %q", ErrInvalidArgs, c.Target)
+	}
+	var v int64
+	if err := json.Unmarshal(c.Value, &v); err != nil {
+		return cmp, fmt.Errorf("%w, the %s of %s should be an integer", ErrInvalidArgs, c.Target, c.Key)
+	}
+	cmp.Value = v
+	return cmp, nil
+}
+
+func toOps(specs []opSpec) ([]etcdadpt.OpOptions, error) {
+	ops := make([]etcdadpt.OpOptions, 0, len(specs))
+	for _, s := range specs {
+		if len(s.Key) == 0 {
+			return nil, fmt.Errorf("%w, required operation key", ErrInvalidArgs)
+		}
+		opts := []etcdadpt.OpOption{etcdadpt.WithStrKey(s.Key)}
+		if len(s.EndKey) > 0 {
+			opts = append(opts, etcdadpt.WithStrEndKey(s.EndKey))
+		}
+		if s.Prefix {
+			opts = append(opts, etcdadpt.WithPrefix())
+		}
+		switch s.Action {
+		case "get":
+			ops = append(ops, etcdadpt.OpGet(opts...))
+		case "put":
+			opts = append(opts, etcdadpt.WithStrValue(s.Value))
+			if s.Lease > 0 {
+				opts = append(opts, etcdadpt.WithLease(s.Lease))
+			}
+			ops = append(ops, etcdadpt.OpPut(opts...))
+		case "delete":
+			ops = append(ops, etcdadpt.OpDel(opts...))
+		default:
+			return nil, fmt.Errorf("%w, unknown operation action %q", ErrInvalidArgs, s.Action)
+		}
+	}
+	return ops, nil
+}
@@ -0,0 +1,72 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/little-cui/etcdadpt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTxnSpec(t *testing.T) {
+	t.Run("valid spec should be converted", func(t *testing.T) {
+		req, err := parseTxnSpec(strings.NewReader(`{
+			"compare": [
+				{"key": "/a", "target": "version", "result": "=", "value": 0},
+				{"key": "/b", "target": "value", "result": "!=", "value": "x"}
+			],
+			"success": [{"action": "put", "key": "/a", "value": "1", "lease": 2}],
+			"failure": [{"action": "get", "key": "/a", "prefix": true}, {"action": "delete", "key": "/b"}]
+		}`))
+		assert.NoError(t, err)
+
+		assert.Equal(t, []etcdadpt.CmpOptions{
+			{Key: []byte("/a"), Type: etcdadpt.CmpVersion, Result: etcdadpt.CmpEqual, Value: int64(0)},
+			{Key: []byte("/b"), Type: etcdadpt.CmpValue, Result: etcdadpt.CmpNotEqual, Value: "x"},
+		}, req.Compare)
+		assert.Equal(t, 1, len(req.Success))
+		assert.Equal(t, etcdadpt.ActionPut, req.Success[0].Action)
+		assert.Equal(t, []byte("1"), req.Success[0].Value)
+		assert.Equal(t, int64(2), req.Success[0].Lease)
+		assert.Equal(t, 2, len(req.Failure))
+		assert.Equal(t, etcdadpt.ActionGet, req.Failure[0].Action)
+		assert.True(t, req.Failure[0].Prefix)
+		assert.Equal(t, etcdadpt.ActionDelete, req.Failure[1].Action)
+	})
+
+	t.Run("invalid spec should return error", func(t *testing.T) {
+		specs := []string{
+			`not json`,
+			`{"unknown": []}`,
+			`{"compare": [{"key": "/a", "target": "version", "result": "="}]}`,
+			`{"compare": [{"key": "/a", "target": "x", "result": "=", "value": 0}], "success": [{"action": "get", "key": "/a"}]}`,
+			`{"compare": [{"key": "/a", "target": "version", "result": "~", "value": 0}], "success": [{"action": "get", "key": "/a"}]}`,
+			`{"compare": [{"key": "/a", "target": "mod", "result": ">", "value": "1"}], "success": [{"action": "get", "key": "/a"}]}`,
+			`{"compare": [{"key": "/a", "target": "value", "result": "=", "value": 1}], "success": [{"action": "get", "key": "/a"}]}`,
+			`{"success": [{"action": "range", "key": "/a"}]}`,
+			`{"success": [{"action": "get"}]}`,
+		}
+		for _, spec := range specs {
+			_, err := parseTxnSpec(strings.NewReader(spec))
+			assert.True(t, errors.Is(err, ErrInvalidArgs), spec)
+		}
+	})
+}
@@ -18,7 +18,9 @@
 package etcdadpt
 
 import (
+	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"time"
 
 	"github.com/go-chassis/openlog"
@@ -43,23 +45,118 @@ type Config struct {
 	Logger     openlog.Logger `json:"-"`
 	SslEnabled bool           `json:"-"`
 	TLSConfig  *tls.Config    `json:"-"`
+	// TLSConfigFunc optional, provides the TLS config of remote client,
+	// takes precedence over the PEM files and TLSConfig
+	TLSConfigFunc func() (*tls.Config, error) `json:"-"`
+	// CertFile, KeyFile and CAFile optional, the PEM encoded files used by
+	// embedded etcd to serve TLS, or by remote client to connect etcd,
+	// take precedence over TLSConfig
+	CertFile string `json:"-"`
+	KeyFile  string `json:"-"`
+	CAFile   string `json:"-"`
+	// CACertificates optional, the CA certificates to verify the client certificates when
+	// embedded etcd serves TLS by TLSConfig, they are written as the trusted CA file
+	// for etcd only loads it from file and TLSConfig.ClientCAs can not be exported
+	CACertificates []*x509.Certificate `json:"-"`
+	// CertReloadInterval optional, the interval to check the changes of the PEM files
+	// or the certificates from TLSConfigFunc, then the remote client reconnects
+	// with the new TLS config, 0 means never
+	CertReloadInterval time.Duration `json:"-"`
+	// ClientCertAuth optional, embedded etcd requires and verifies the client certificates
+	ClientCertAuth bool `json:"-"`
+	// Username and Password optional, the credentials to connect the auth enabled etcd
+	Username string `json:"-"`
+	Password string `json:"-"`
+	// TokenRefreshInterval optional, the interval to refresh the auth token in background,
+	// 0 means the token is only refreshed when it is invalid
+	TokenRefreshInterval time.Duration `json:"-"`
 	// ErrorFunc called when connection error occurs
 	ErrorFunc func(err error) `json:"-"`
 	// ConnectedFunc called when connected
 	ConnectedFunc func() `json:"-"`
-	// ManagerAddress optional, the list of cluster manager endpoints
+	// ManagerAddress optional, the list of cluster manager endpoints,
+	// the format like 'sc-0=http://host1:port1,sc-1=http://host2:port2' when
+	// Kind = 'embedded_etcd' means the peer endpoints of all the initial members
 	ManagerAddress string `json:"manageAddress,omitempty"`
-	// ClusterName required when Kind = 'embedded_etcd'
+	// ClusterName required when Kind = 'embedded_etcd', the member name
 	ClusterName string `json:"manageName,omitempty"`
 	// ClusterAddresses required, the list of cluster client endpoints
 	ClusterAddresses string        `json:"manageClusters,omitempty"` // the raw string of cluster configuration
 	DialTimeout      time.Duration `json:"connectTimeout"`
 	RequestTimeOut   time.Duration `json:"registryTimeout"`
+	// Resolver optional, resolves the client endpoints of remote etcd,
+	// takes precedence over DiscoverySRV, EndpointsFile and ClusterAddresses
+	Resolver Resolver `json:"-"`
+	// DiscoverySRV optional, the domain to query the DNS SRV records '_etcd-client._tcp'
+	// (and '_etcd-client-ssl._tcp' if SslEnabled) for the client endpoints of remote etcd
+	DiscoverySRV string `json:"discoverySrv,omitempty"`
+	// DiscoverySRVName optional, the suffix of the SRV service name, like '_etcd-client-{name}._tcp'
+	DiscoverySRVName string `json:"discoverySrvName,omitempty"`
+	// EndpointsFile optional, the file lists the client endpoints of remote etcd in
+	// the same format as ClusterAddresses, separated by comma or line, re-read on change
+	EndpointsFile string `json:"endpointsFile,omitempty"`
+	// RetryPolicy optional, the remote client retries the requests failed with the transient errors
+	RetryPolicy RetryPolicy `json:"retryPolicy"`
+	// Interceptors optional, intercept the operations of client after the default ones,
+	// like metrics, logging and tracing
+	Interceptors []Interceptor `json:"-"`
+	// MetricsInstance optional, the "instance" label of the metrics reported by the client,
+	// default metrics.Options.InstanceName, set it to distinguish the multiple clients
+	MetricsInstance string `json:"metricsInstance,omitempty"`
+	// CircuitBreaker optional, wraps the client with a circuit breaker to fail fast when
+	// the backend is overloaded, ErrorFunc and ConnectedFunc are called when it trips and recovers
+	CircuitBreaker *CircuitBreakerConfig `json:"circuitBreaker,omitempty"`
 	// AutoSyncInterval optional, then duration of auto sync the cluster members and check them health
 	AutoSyncInterval time.Duration `json:"autoSyncInterval"`
 	// CompactInterval optional, set DefaultCompactInterval if value equal to 0
 	CompactInterval   time.Duration `json:"-"`
 	CompactIndexDelta int64         `json:"-"`
+	// DefragmentThreshold optional, defragment after compacted if the fragmentation
+	// ratio of db exceeds the threshold(0, 1), 0 means never
+	DefragmentThreshold float64 `json:"-"`
+	// Embedded optional, the server options when Kind = 'embedded_etcd'
+	Embedded EmbeddedConfig `json:"embedded"`
+}
+
+// Resolver resolves the client endpoints of remote etcd, the format is 'host:port',
+// it is called again to update the endpoints when the members are auto synced
+type Resolver interface {
+	Resolve(ctx context.Context) ([]string, error)
+}
+
+// EmbeddedConfig is the options of embedded etcd server,
+// the zero value field means using the etcd default value
+type EmbeddedConfig struct {
+	// DataDir optional, the data directory, by default use 'data' relative to the working directory
+	DataDir string `json:"dataDir,omitempty"`
+	// WALDir optional, the dedicated wal directory, by default under the DataDir
+	WALDir string `json:"walDir,omitempty"`
+	// QuotaBackendBytes optional, the backend size quota, by default use 8GB
+	QuotaBackendBytes int64 `json:"quotaBackendBytes,omitempty"`
+	// SnapshotCount optional, the number of committed transactions to trigger a snapshot to disk
+	SnapshotCount uint64 `json:"snapshotCount,omitempty"`
+	// HeartbeatInterval optional, the time of a heartbeat interval
+	HeartbeatInterval time.Duration `json:"heartbeatInterval,omitempty"`
+	// ElectionTimeout optional, the time for an election to timeout
+	ElectionTimeout time.Duration `json:"electionTimeout,omitempty"`
+	// MaxRequestBytes optional, the maximum client request size in bytes the server will accept
+	MaxRequestBytes uint `json:"maxRequestBytes,omitempty"`
+	// MaxTxnOps optional, the maximum number of operations permitted in a transaction
+	MaxTxnOps uint `json:"maxTxnOps,omitempty"`
+	// Join optional, add this member to the existing cluster through the
+	// client endpoints of the other members in ClusterAddresses
+	Join bool `json:"join,omitempty"`
+	// Learner optional, join the existing cluster as a learner, implies Join
+	Learner bool `json:"learner,omitempty"`
+	// RestoreFile optional, the snapshot file to restore the data dir before the
+	// member initialized, the integrity hash of the snapshot will be verified
+	RestoreFile string `json:"restoreFile,omitempty"`
+	// LogLevel optional, the minimum level of the server logs written to Config.Logger,
+	// debug, info, warn or error, by default info
+	LogLevel string `json:"logLevel,omitempty"`
+	// MuteLogs optional, the message prefixes or the logger names, like 'raft',
+	// of the noisy server logs to drop
+	MuteLogs []string `json:"muteLogs,omitempty"`
 }
 
 func (c *Config) Init() {
@@ -0,0 +1,176 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package embedded
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	"go.etcd.io/etcd/client/pkg/v3/transport"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/server/v3/embed"
+	"go.etcd.io/etcd/server/v3/wal"
+
+	"github.com/go-chassis/foundation/backoff"
+	"github.com/little-cui/etcdadpt"
+	"github.com/little-cui/etcdadpt/middleware/log"
+)
+
+var ErrNoMemberToJoin = errors.New("no other member client endpoints in ClusterAddresses to join")
+
+// memberURLs returns the urls of the member from the 'sc-0=url1,sc-1=url2' like string,
+// return all the urls if the member name is not found
+func memberURLs(name, addrs string) string {
+	if urls := etcdadpt.GetClusterURL(name, addrs, ""); len(urls) > 0 {
+		return strings.Join(urls, ",")
+	}
+	return addrs
+}
+
+// toInitialCluster converts the member peer urls to the initial cluster
+// format like 'sc-0=url1,sc-1=url2'
+func toInitialCluster(members etcdadpt.Clusters) string {
+	names := make([]string, 0, len(members))
+	for name := range members {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var pairs []string
+	for _, name := range names {
+		for _, u := range members[name] {
+			pairs = append(pairs, name+"="+u)
+		}
+	}
+	return strings.Join(pairs, ",")
+}
+
+// joinCluster adds this member to the existing cluster and sets the initial
+// cluster to the current members, skip when the member has been initialized
+func joinCluster(cfg etcdadpt.Config, serverCfg *embed.Config, clusterAddrs string) error {
+	serverCfg.ClusterState = embed.ClusterStateFlagExisting
+
+	walDir := serverCfg.WalDir
+	if len(walDir) == 0 {
+		walDir = filepath.Join(serverCfg.Dir, "member", "wal")
+	}
+	if wal.Exist(walDir) {
+		log.GetLogger().Info(fmt.Sprintf("member %s has been initialized, skip joining", serverCfg.Name))
+		return nil
+	}
+
+	var endpoints []string
+	for name, urls := range etcdadpt.ParseClusters(serverCfg.Name, clusterAddrs, "") {
+		if name != serverCfg.Name {
+			endpoints = append(endpoints, urls...)
+		}
+	}
+	if len(endpoints) == 0 {
+		return ErrNoMemberToJoin
+	}
+
+	tlsCfg, err := clientTLSConfig(cfg, serverCfg.ClientTLSInfo)
+	if err != nil {
+		return err
+	}
+	return addMember(cfg, serverCfg, endpoints, tlsCfg)
+}
+
+// clientTLSConfig returns the TLS config to connect the other members with the server
+// certificates, the members are verified by the trusted CA file, or the CAs of cfg.TLSConfig
+func clientTLSConfig(cfg etcdadpt.Config, info transport.TLSInfo) (*tls.Config, error) {
+	if !cfg.SslEnabled {
+		return nil, nil
+	}
+	tlsCfg, err := info.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	if tlsCfg.RootCAs == nil && cfg.TLSConfig != nil {
+		tlsCfg.RootCAs = cfg.TLSConfig.RootCAs
+		if tlsCfg.RootCAs == nil {
+			tlsCfg.RootCAs = cfg.TLSConfig.ClientCAs
+		}
+	}
+	return tlsCfg, nil
+}
+
+func addMember(cfg etcdadpt.Config, serverCfg *embed.Config, endpoints []string, tlsCfg *tls.Config) error {
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   endpoints,
+		DialTimeout: cfg.DialTimeout,
+		TLS:         tlsCfg,
+	})
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	peerURLs := make([]string, 0, len(serverCfg.APUrls))
+	for _, u := range serverCfg.APUrls {
+		peerURLs = append(peerURLs, u.String())
+	}
+
+	ctx, cancel := context.WithTimeout(client.Ctx(), cfg.RequestTimeOut)
+	defer cancel()
+	var resp *clientv3.MemberAddResponse
+	for i := 0; ; i++ {
+		if cfg.Embedded.Learner {
+			resp, err = client.MemberAddAsLearner(ctx, peerURLs)
+		} else {
+			resp, err = client.MemberAdd(ctx, peerURLs)
+		}
+		if err == nil {
+			break
+		}
+		if err.Error() != rpctypes.ErrUnhealthy.Error() {
+			return err
+		}
+		// the cluster rejects to add member until all members connected for a while
+		d := backoff.GetBackoff().Delay(i)
+		log.GetLogger().Warn(fmt.Sprintf("retry to add member %s after %s, error: %s", serverCfg.Name, d, err))
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(d):
+		}
+	}
+
+	members := make(etcdadpt.Clusters, len(resp.Members))
+	for _, m := range resp.Members {
+		name := m.Name
+		if m.ID == resp.Member.ID {
+			name = serverCfg.Name
+		}
+		if len(name) == 0 {
+			// the member added but not started
+			continue
+		}
+		members[name] = m.PeerURLs
+	}
+	serverCfg.InitialCluster = toInitialCluster(members)
+	log.GetLogger().Warn(fmt.Sprintf("member %s(%x) joined the cluster %s, learner: %v",
+		serverCfg.Name, resp.Member.ID, serverCfg.InitialCluster, cfg.Embedded.Learner))
+	return nil
+}
@@ -0,0 +1,222 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package embedded_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/little-cui/etcdadpt"
+	"github.com/little-cui/etcdadpt/embedded"
+	"github.com/stretchr/testify/assert"
+)
+
+// freeURL returns a local url which is free to listen
+func freeURL(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer l.Close()
+	return "http://" + l.Addr().String()
+}
+
+// testCluster is the urls of the cluster members
+type testCluster struct {
+	clientURLs, peerURLs []string
+}
+
+func newTestCluster(t *testing.T, members int) *testCluster {
+	c := &testCluster{}
+	c.grow(t, members)
+	return c
+}
+
+// grow allocates the urls of the new members
+func (c *testCluster) grow(t *testing.T, members int) {
+	for i := len(c.clientURLs); i < members; i++ {
+		c.clientURLs = append(c.clientURLs, fmt.Sprintf("n%d=%s", i, freeURL(t)))
+		c.peerURLs = append(c.peerURLs, fmt.Sprintf("n%d=%s", i, freeURL(t)))
+	}
+}
+
+func (c *testCluster) config(t *testing.T, name string, members int) etcdadpt.Config {
+	return etcdadpt.Config{
+		ClusterName:      name,
+		ClusterAddresses: strings.Join(c.clientURLs[:members], ","),
+		ManagerAddress:   strings.Join(c.peerURLs[:members], ","),
+		// wait for the quorum of members
+		DialTimeout: 30 * time.Second,
+		Embedded:    etcdadpt.EmbeddedConfig{DataDir: t.TempDir()},
+	}
+}
+
+func TestNewEmbeddedEtcd_Cluster(t *testing.T) {
+	cluster := newTestCluster(t, 3)
+	insts := make([]etcdadpt.Client, 3)
+	var wg sync.WaitGroup
+	for i := range insts {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			insts[i] = newTestEmbeddedEtcd(t, cluster.config(t, fmt.Sprintf("n%d", i), len(insts)))
+		}(i)
+	}
+	wg.Wait()
+	for _, inst := range insts {
+		if inst != nil {
+			defer inst.Close()
+		}
+	}
+	if t.Failed() {
+		return
+	}
+
+	t.Run("bootstrap 3 members cluster should pass", func(t *testing.T) {
+		// the member attributes are published to the others after it started
+		assert.Eventually(t, func() bool {
+			members, err := insts[0].(etcdadpt.MemberManager).ListMember(context.Background())
+			if err != nil || len(members) != 3 {
+				return false
+			}
+			for _, m := range members {
+				if !m.Healthy || len(m.ClientURLs) != 1 {
+					return false
+				}
+			}
+			return true
+		}, 5*time.Second, 100*time.Millisecond)
+
+		_, err := insts[0].Do(context.Background(), etcdadpt.PUT,
+			etcdadpt.WithStrKey("/test_cluster"), etcdadpt.WithStrValue("n0"))
+		assert.NoError(t, err)
+		for _, inst := range insts[1:] {
+			resp, err := inst.Do(context.Background(), etcdadpt.GET, etcdadpt.WithStrKey("/test_cluster"))
+			assert.NoError(t, err)
+			assert.Equal(t, int64(1), resp.Count)
+		}
+	})
+
+	t.Run("join the cluster as a learner should pass", func(t *testing.T) {
+		cluster.grow(t, 4)
+		cfg := cluster.config(t, "n3", 4)
+		cfg.Embedded.Learner = true
+		inst := newTestEmbeddedEtcd(t, cfg)
+		defer inst.Close()
+
+		var learner *etcdadpt.Member
+		assert.Eventually(t, func() bool {
+			members, err := insts[0].(etcdadpt.MemberManager).ListMember(context.Background())
+			if err != nil || len(members) != 4 {
+				return false
+			}
+			for _, m := range members {
+				if m.IsLearner && m.Name == "n3" && m.Healthy {
+					learner = m
+					return true
+				}
+			}
+			return false
+		}, 5*time.Second, 100*time.Millisecond)
+		if learner == nil {
+			return
+		}
+
+		// the learner can be promoted after it is in sync with the leader
+		assert.Eventually(t, func() bool {
+			return insts[1].(etcdadpt.MemberManager).PromoteMember(context.Background(), learner.ID) == nil
+		}, 10*time.Second, 500*time.Millisecond)
+		assert.Eventually(t, func() bool {
+			members, err := insts[0].(etcdadpt.MemberManager).ListMember(context.Background())
+			if err != nil {
+				return false
+			}
+			for _, m := range members {
+				if m.IsLearner {
+					return false
+				}
+			}
+			return true
+		}, 5*time.Second, 100*time.Millisecond)
+
+		err := insts[0].(etcdadpt.MemberManager).RemoveMember(context.Background(), learner.ID)
+		assert.NoError(t, err)
+	})
+
+	t.Run("join without other members should fail", func(t *testing.T) {
+		cfg := etcdadpt.Config{
+			ClusterName:      "n9",
+			ClusterAddresses: "n9=" + freeURL(t),
+			ManagerAddress:   freeURL(t),
+			Embedded:         etcdadpt.EmbeddedConfig{DataDir: t.TempDir(), Join: true},
+		}
+		cfg.Init()
+		inst := embedded.NewEmbeddedEtcd(cfg)
+		defer inst.Close()
+		err := <-inst.Err()
+		assert.Equal(t, embedded.ErrNoMemberToJoin, err)
+	})
+}
+
+func TestEtcdEmbed_ListMember(t *testing.T) {
+	peerURLs := "m0=" + freeURL(t) + ",m1=" + freeURL(t)
+	insts := make([]etcdadpt.Client, 2)
+	var wg sync.WaitGroup
+	for i := range insts {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// no client urls are exposed
+			insts[i] = newTestEmbeddedEtcd(t, etcdadpt.Config{
+				ClusterName:    fmt.Sprintf("m%d", i),
+				ManagerAddress: peerURLs,
+				DialTimeout:    30 * time.Second,
+				Embedded:       etcdadpt.EmbeddedConfig{DataDir: t.TempDir()},
+			})
+		}(i)
+	}
+	wg.Wait()
+	for _, inst := range insts {
+		if inst != nil {
+			defer inst.Close()
+		}
+	}
+	if t.Failed() {
+		return
+	}
+
+	t.Run("members without client urls should be healthy", func(t *testing.T) {
+		assert.Eventually(t, func() bool {
+			members, err := insts[0].(etcdadpt.MemberManager).ListMember(context.Background())
+			if err != nil || len(members) != 2 {
+				return false
+			}
+			for _, m := range members {
+				if !m.Healthy || len(m.ClientURLs) != 0 {
+					return false
+				}
+			}
+			return true
+		}, 5*time.Second, 100*time.Millisecond)
+	})
+}
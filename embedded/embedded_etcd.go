@@ -22,6 +22,7 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"os"
 	"strconv"
 	"strings"
 	"time"
@@ -29,13 +30,14 @@ import (
 	"go.etcd.io/etcd/api/v3/etcdserverpb"
 	"go.etcd.io/etcd/api/v3/mvccpb"
 	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	"go.etcd.io/etcd/api/v3/version"
+	"go.etcd.io/etcd/raft/v3"
 	"go.etcd.io/etcd/server/v3/embed"
 	"go.etcd.io/etcd/server/v3/etcdserver"
 	"go.etcd.io/etcd/server/v3/etcdserver/api/v3compactor"
 	"go.etcd.io/etcd/server/v3/lease"
 
 	"github.com/go-chassis/foundation/gopool"
-	"github.com/go-chassis/foundation/stringutil"
 	"github.com/little-cui/etcdadpt"
 	"github.com/little-cui/etcdadpt/middleware/log"
 )
@@ -53,6 +55,8 @@ type EtcdEmbed struct {
 	err       chan error
 	ready     chan struct{}
 	goroutine *gopool.Pool
+	// tlsDir the temporary dir of the TLS files written from Config.TLSConfig
+	tlsDir string
 }
 
 func (s *EtcdEmbed) Err() <-chan error {
@@ -63,14 +67,55 @@ func (s *EtcdEmbed) Ready() <-chan struct{} {
 	return s.ready
 }
 
+// Endpoint returns the first advertised client endpoint
+func (s *EtcdEmbed) Endpoint() string {
+	if s.Embed == nil {
+		return ""
+	}
+	if urls := s.Embed.Config().ACUrls; len(urls) > 0 {
+		return urls[0].String()
+	}
+	return ""
+}
+
+// Health returns the client endpoints and the backend quota,
+// the client is not live if the server stopped
+func (s *EtcdEmbed) Health() etcdadpt.Health {
+	var health etcdadpt.Health
+	if s.Embed == nil {
+		return health
+	}
+	cfg := s.Embed.Config()
+	for _, u := range cfg.ACUrls {
+		health.Endpoints = append(health.Endpoints, u.String())
+	}
+	health.QuotaBackendBytes = cfg.QuotaBackendBytes
+	select {
+	case <-s.Embed.Server.StopNotify():
+	default:
+		health.Live = true
+	}
+	return health
+}
+
 func (s *EtcdEmbed) Close() {
 	if s.Embed != nil {
 		s.Embed.Close()
 	}
 	s.goroutine.Close(true)
+	s.removeTLSDir()
 	log.GetLogger().Debug("embedded etcd client stopped")
 }
 
+func (s *EtcdEmbed) removeTLSDir() {
+	if len(s.tlsDir) == 0 {
+		return
+	}
+	if err := os.RemoveAll(s.tlsDir); err != nil {
+		log.GetLogger().Error(fmt.Sprintf("remove TLS dir %s failed, error: %s", s.tlsDir, err))
+	}
+}
+
 func (s *EtcdEmbed) getPrefixEndKey(prefix []byte) []byte {
 	l := len(prefix)
 	endBytes := make([]byte, l+1)
@@ -255,9 +300,40 @@ func (s *EtcdEmbed) Compact(ctx context.Context, reserve int64) error {
 	}
 	log.GetLogger().Info(fmt.Sprintf("compacted locally, revision is %d(current: %d, reserve %d)", revToCompact, curRev, reserve))
 
-	// TODO defragment
-	log.GetLogger().Info("defraged locally")
+	return s.defragmentIfNeeded(ctx)
+}
 
+func (s *EtcdEmbed) defragmentIfNeeded(ctx context.Context) error {
+	threshold := s.Cfg.DefragmentThreshold
+	if threshold <= 0 {
+		return nil
+	}
+	status, err := s.Status(ctx)
+	if err != nil {
+		return err
+	}
+	if ratio := status.Fragmentation(); ratio < threshold {
+		log.GetLogger().Info(fmt.Sprintf("fragmentation is %.2f, <%.2f, no need to defragment", ratio, threshold))
+		return nil
+	}
+	return s.Defragment(ctx)
+}
+
+func (s *EtcdEmbed) Defragment(ctx context.Context) error {
+	before, err := s.Status(ctx)
+	if err != nil {
+		return err
+	}
+	err = s.Embed.Server.Backend().Defrag()
+	if err != nil {
+		log.GetLogger().Error(fmt.Sprintf("defragment locally failed, error: %s", err))
+		return err
+	}
+	after, err := s.Status(ctx)
+	if err != nil {
+		return err
+	}
+	log.GetLogger().Info(fmt.Sprintf("defragmented locally, db size is %d(before: %d)", after.DBSize, before.DBSize))
 	return nil
 }
 
@@ -281,6 +357,7 @@ func (s *EtcdEmbed) Do(ctx context.Context, opts ...etcdadpt.OpOption) (*etcdadp
 			break
 		}
 		if op.LargeRequestPaging() && op.Offset >= 0 && op.Limit > 0 {
+			s.reporter().ReportBackendPagingPage()
 			pagingResult(op, etcdResp)
 		}
 		resp = &etcdadpt.Response{
@@ -299,6 +376,9 @@ func (s *EtcdEmbed) Do(ctx context.Context, opts ...etcdadpt.OpOption) (*etcdadp
 			Revision:  etcdResp.Header.Revision,
 			Succeeded: true,
 		}
+		if etcdResp.PrevKv != nil {
+			resp.PrevKvs = []*mvccpb.KeyValue{etcdResp.PrevKv}
+		}
 	case etcdadpt.ActionDelete:
 		var etcdResp *etcdserverpb.DeleteRangeResponse
 		etcdResp, err = s.Embed.Server.DeleteRange(otCtx, s.toDeleteRequest(op))
@@ -308,6 +388,7 @@ func (s *EtcdEmbed) Do(ctx context.Context, opts ...etcdadpt.OpOption) (*etcdadp
 		resp = &etcdadpt.Response{
 			Revision:  etcdResp.Header.Revision,
 			Succeeded: etcdResp.Deleted > 0,
+			PrevKvs:   etcdResp.PrevKvs,
 		}
 	}
 	if err != nil {
@@ -337,6 +418,7 @@ func (s *EtcdEmbed) Txn(ctx context.Context, opts []etcdadpt.OpOptions) (*etcdad
 	return &etcdadpt.Response{
 		Succeeded: resp.Succeeded,
 		Revision:  resp.Revision,
+		PrevKvs:   resp.PrevKvs,
 	}, nil
 }
 
@@ -366,12 +448,22 @@ func (s *EtcdEmbed) TxnWithCmp(ctx context.Context, success []etcdadpt.OpOptions
 		return nil, err
 	}
 
-	var rangeResponse etcdserverpb.RangeResponse
+	var (
+		rangeResponse etcdserverpb.RangeResponse
+		prevKvs       []*mvccpb.KeyValue
+	)
 	for _, itf := range resp.Responses {
-		if rr, ok := itf.Response.(*etcdserverpb.ResponseOp_ResponseRange); ok {
+		switch r := itf.Response.(type) {
+		case *etcdserverpb.ResponseOp_ResponseRange:
 			// plz request the same type range kv in txn success/fail options
-			rangeResponse.Kvs = append(rangeResponse.Kvs, rr.ResponseRange.Kvs...)
-			rangeResponse.Count += rr.ResponseRange.Count
+			rangeResponse.Kvs = append(rangeResponse.Kvs, r.ResponseRange.Kvs...)
+			rangeResponse.Count += r.ResponseRange.Count
+		case *etcdserverpb.ResponseOp_ResponsePut:
+			if r.ResponsePut.PrevKv != nil {
+				prevKvs = append(prevKvs, r.ResponsePut.PrevKv)
+			}
+		case *etcdserverpb.ResponseOp_ResponseDeleteRange:
+			prevKvs = append(prevKvs, r.ResponseDeleteRange.PrevKvs...)
 		}
 	}
 
@@ -380,6 +472,7 @@ func (s *EtcdEmbed) TxnWithCmp(ctx context.Context, success []etcdadpt.OpOptions
 		Revision:  resp.Header.Revision,
 		Kvs:       rangeResponse.Kvs,
 		Count:     rangeResponse.Count,
+		PrevKvs:   prevKvs,
 	}, nil
 }
 
@@ -423,6 +516,24 @@ func (s *EtcdEmbed) LeaseRevoke(ctx context.Context, leaseID int64) error {
 	return nil
 }
 
+func (s *EtcdEmbed) LeaseTimeToLive(ctx context.Context, leaseID int64) (int64, error) {
+	otCtx, cancel := s.WithTimeout(ctx)
+	defer cancel()
+	etcdResp, err := s.Embed.Server.LeaseTimeToLive(otCtx, &etcdserverpb.LeaseTimeToLiveRequest{
+		ID: leaseID,
+	})
+	if err != nil {
+		if err == lease.ErrLeaseNotFound {
+			return 0, etcdadpt.ErrLeaseNotFound
+		}
+		return 0, err
+	}
+	if etcdResp.TTL < 0 {
+		return 0, etcdadpt.ErrLeaseNotFound
+	}
+	return etcdResp.TTL, nil
+}
+
 func (s *EtcdEmbed) Watch(ctx context.Context, opts ...etcdadpt.OpOption) (err error) {
 	op := etcdadpt.OpGet(opts...)
 
@@ -431,15 +542,12 @@ func (s *EtcdEmbed) Watch(ctx context.Context, opts ...etcdadpt.OpOption) (err e
 		ws := watchable.NewWatchStream()
 		defer ws.Close()
 
-		key := stringutil.Bytes2str(op.Key)
-		var keyBytes []byte
+		// the same range as toGetRequest, keep consistent with clientv3.WithPrefix/WithRange
+		endBytes := op.EndKey
 		if op.Prefix {
-			if key[len(key)-1] != '/' {
-				key += "/"
-			}
-			keyBytes = s.getPrefixEndKey(stringutil.Str2bytes(key))
+			endBytes = s.getPrefixEndKey(op.Key)
 		}
-		watchID, err := ws.Watch(0, op.Key, keyBytes, op.Revision)
+		watchID, err := ws.Watch(0, op.Key, endBytes, op.Revision)
 		if err != nil {
 			log.GetLogger().Error(err.Error())
 			return err
@@ -459,7 +567,13 @@ func (s *EtcdEmbed) Watch(ctx context.Context, opts ...etcdadpt.OpOption) (err e
 					err = errors.New("channel is closed")
 					return err
 				}
+				if resp.CompactRevision > 0 {
+					// the same as clientv3.WatchResponse.Err()
+					err = rpctypes.ErrCompacted
+					return err
+				}
 
+				s.reporter().ReportBackendWatchEvents(len(resp.Events))
 				err = dispatch(resp.Events, op.WatchCallback)
 				if err != nil {
 					return err
@@ -476,14 +590,58 @@ func (s *EtcdEmbed) ListCluster(ctx context.Context) (etcdadpt.Clusters, error)
 	return clusters, nil
 }
 
+// Status returns the status of the local member, the same as the
+// maintenance status of the etcd server
 func (s *EtcdEmbed) Status(ctx context.Context) (*etcdadpt.StatusResponse, error) {
-	return &etcdadpt.StatusResponse{DBSize: s.Embed.Server.Backend().Size()}, nil
+	server := s.Embed.Server
+	be := server.Backend()
+	status := etcdadpt.EndpointStatus{
+		MemberID:         uint64(server.ID()),
+		Leader:           server.Lead(),
+		RaftTerm:         server.Term(),
+		RaftIndex:        server.CommittedIndex(),
+		RaftAppliedIndex: server.AppliedIndex(),
+		Revision:         server.KV().Rev(),
+		DBSize:           be.Size(),
+		DBSizeInUse:      be.SizeInUse(),
+		Version:          version.Version,
+		IsLearner:        server.IsLearner(),
+	}
+	status.Endpoint = s.Endpoint()
+	if status.Leader == raft.None {
+		status.Errors = append(status.Errors, etcdserver.ErrNoLeader.Error())
+	}
+
+	members := server.Alarms()
+	alarms := make([]etcdadpt.Alarm, 0, len(members))
+	for _, alarm := range members {
+		status.Errors = append(status.Errors, alarm.String())
+		alarms = append(alarms, etcdadpt.Alarm{
+			MemberID: alarm.MemberID,
+			Type:     alarm.Alarm.String(),
+		})
+	}
+	return &etcdadpt.StatusResponse{
+		MemberID:         status.MemberID,
+		Leader:           status.Leader,
+		RaftTerm:         status.RaftTerm,
+		RaftIndex:        status.RaftIndex,
+		RaftAppliedIndex: status.RaftAppliedIndex,
+		Revision:         status.Revision,
+		DBSize:           status.DBSize,
+		DBSizeInUse:      status.DBSizeInUse,
+		Version:          status.Version,
+		Alarms:           alarms,
+		Endpoints:        []etcdadpt.EndpointStatus{status},
+	}, nil
 }
 
 func (s *EtcdEmbed) readyNotify() {
 	timeout := s.Cfg.DialTimeout
 	select {
 	case <-s.Embed.Server.ReadyNotify():
+		s.goroutine.Do(s.ReportMetricsLoop)
+		s.goroutine.Do(s.LeaderChangedLoop)
 		close(s.ready)
 		s.goroutine.Do(func(ctx context.Context) {
 			select {
@@ -498,6 +656,7 @@ func (s *EtcdEmbed) readyNotify() {
 		log.GetLogger().Error(fmt.Sprintf("read notify failed, error: %s", err))
 
 		s.Embed.Server.Stop()
+		s.removeTLSDir()
 
 		s.err <- err
 	}
@@ -586,27 +745,55 @@ func NewEmbeddedEtcd(cfg etcdadpt.Config) etcdadpt.Client {
 	}
 	inst.goroutine = gopool.New(gopool.Configure().WithRecoverFunc(inst.logRecover))
 
-	if cfg.SslEnabled {
-		log.GetLogger().Info("config no use for embedded etcd")
-	}
+	clusterAddrs := cfg.ClusterAddresses
 
 	serverCfg := embed.NewConfig()
 	serverCfg.EnableV2 = false
 	serverCfg.EnablePprof = false
-	serverCfg.QuotaBackendBytes = etcdserver.MaxQuotaBytes
-	// TODO log
-	// serverCfg.ZapLoggerBuilder = embed.NewZapLoggerBuilder(log.GetLogger())
-	// TODO 不支持使用TLS通信
-	// 存储目录，相对于工作目录
-	serverCfg.Dir = DefaultDataDir
-	// 集群支持
+	setServerOptions(serverCfg, cfg.Embedded)
+	lg, err := newServerLogger(cfg.Embedded.LogLevel, cfg.Embedded.MuteLogs)
+	if err != nil {
+		log.GetLogger().Error(fmt.Sprintf(`"LogLevel" field configure error: %s`, err))
+		inst.err <- err
+		return inst
+	}
+	serverCfg.ZapLoggerBuilder = embed.NewZapLoggerBuilder(lg)
+	// TLS通信，业务端口和管理端口使用相同证书
+	if cfg.SslEnabled {
+		tlsInfo, tlsDir, err := newTLSInfo(cfg)
+		if err != nil {
+			log.GetLogger().Error(fmt.Sprintf("TLS configure error: %s", err))
+			inst.err <- err
+			return inst
+		}
+		inst.tlsDir = tlsDir
+		defer func() {
+			if inst.Embed == nil {
+				// failed to start the server
+				inst.removeTLSDir()
+			}
+		}()
+		serverCfg.ClientTLSInfo = tlsInfo
+		serverCfg.PeerTLSInfo = tlsInfo
+		mgrAddrs = toHTTPS(mgrAddrs)
+		clusterAddrs = toHTTPS(clusterAddrs)
+	}
+	// 集群支持，管理端口格式为"sc-0=http(s)://IP:Port,sc-1=http(s)://IP:Port"时为多成员集群
 	serverCfg.Name = hostName
-	serverCfg.InitialCluster = hostName + "=" + mgrAddrs
+	members := etcdadpt.ParseClusters(hostName, mgrAddrs, "")
+	if len(members[hostName]) == 0 {
+		err := fmt.Errorf("member %s not found in the manager address %s", hostName, mgrAddrs)
+		log.GetLogger().Error(fmt.Sprintf(`"ManagerAddress" field configure error: %s`, err))
+		inst.err <- err
+		return inst
+	}
+	serverCfg.InitialCluster = toInitialCluster(members)
+	mgrAddrs = strings.Join(members[hostName], ",")
 	// 1. 业务端口，默认2379端口关闭
 	serverCfg.LCUrls = nil
 	serverCfg.ACUrls = nil
-	if len(cfg.ClusterAddresses) > 0 {
-		urls, err := parseURL(cfg.ClusterAddresses)
+	if len(clusterAddrs) > 0 {
+		urls, err := parseURL(memberURLs(hostName, clusterAddrs))
 		if err != nil {
 			log.GetLogger().Error(fmt.Sprintf(`"ClusterAddresses" field configure error: %s`, err))
 			inst.err <- err
@@ -634,6 +821,24 @@ func NewEmbeddedEtcd(cfg etcdadpt.Config) etcdadpt.Client {
 		serverCfg.AutoCompactionRetention = cfg.CompactInterval.String()
 	}
 
+	// 3. 加入已有集群
+	if cfg.Embedded.Join || cfg.Embedded.Learner {
+		if err := joinCluster(cfg, serverCfg, clusterAddrs); err != nil {
+			log.GetLogger().Error(fmt.Sprintf("join the existing cluster failed, error: %s", err))
+			inst.err <- err
+			return inst
+		}
+	}
+
+	// 4. 从快照恢复
+	if len(cfg.Embedded.RestoreFile) > 0 {
+		if err := restore(serverCfg, cfg.Embedded.RestoreFile); err != nil {
+			log.GetLogger().Error(fmt.Sprintf("restore from %s failed, error: %s", cfg.Embedded.RestoreFile, err))
+			inst.err <- err
+			return inst
+		}
+	}
+
 	etcd, err := embed.StartEtcd(serverCfg)
 	if err != nil {
 		log.GetLogger().Error(fmt.Sprintf("error to start etcd server, error: %s", err))
@@ -646,6 +851,34 @@ func NewEmbeddedEtcd(cfg etcdadpt.Config) etcdadpt.Client {
 	return inst
 }
 
+func setServerOptions(serverCfg *embed.Config, opts etcdadpt.EmbeddedConfig) {
+	// 存储目录，默认相对于工作目录
+	serverCfg.Dir = DefaultDataDir
+	if len(opts.DataDir) > 0 {
+		serverCfg.Dir = opts.DataDir
+	}
+	serverCfg.WalDir = opts.WALDir
+	serverCfg.QuotaBackendBytes = etcdserver.MaxQuotaBytes
+	if opts.QuotaBackendBytes > 0 {
+		serverCfg.QuotaBackendBytes = opts.QuotaBackendBytes
+	}
+	if opts.SnapshotCount > 0 {
+		serverCfg.SnapshotCount = opts.SnapshotCount
+	}
+	if opts.HeartbeatInterval > 0 {
+		serverCfg.TickMs = uint(opts.HeartbeatInterval / time.Millisecond)
+	}
+	if opts.ElectionTimeout > 0 {
+		serverCfg.ElectionMs = uint(opts.ElectionTimeout / time.Millisecond)
+	}
+	if opts.MaxRequestBytes > 0 {
+		serverCfg.MaxRequestBytes = opts.MaxRequestBytes
+	}
+	if opts.MaxTxnOps > 0 {
+		serverCfg.MaxTxnOps = opts.MaxTxnOps
+	}
+}
+
 func parseURL(addrs string) ([]url.URL, error) {
 	var urls []url.URL
 	ips := strings.Split(addrs, ",")
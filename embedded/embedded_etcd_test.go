@@ -0,0 +1,133 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package embedded_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/little-cui/etcdadpt"
+	"github.com/little-cui/etcdadpt/embedded"
+	"github.com/stretchr/testify/assert"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+)
+
+func newTestEmbeddedEtcd(t *testing.T, cfg etcdadpt.Config) etcdadpt.Client {
+	cfg.Init()
+	inst := embedded.NewEmbeddedEtcd(cfg)
+	select {
+	case err := <-inst.Err():
+		t.Fatal(err)
+	case <-inst.Ready():
+	}
+	return inst
+}
+
+func TestNewEmbeddedEtcd_Options(t *testing.T) {
+	dataDir1, walDir1 := t.TempDir(), t.TempDir()
+	inst1 := newTestEmbeddedEtcd(t, etcdadpt.Config{
+		ClusterName:      "e1",
+		ClusterAddresses: "e1=http://127.0.0.1:31379",
+		ManagerAddress:   "http://127.0.0.1:31380",
+		Embedded: etcdadpt.EmbeddedConfig{
+			DataDir:           dataDir1,
+			WALDir:            walDir1,
+			QuotaBackendBytes: 64 * 1024 * 1024,
+			SnapshotCount:     1000,
+			HeartbeatInterval: 50 * time.Millisecond,
+			ElectionTimeout:   500 * time.Millisecond,
+			MaxRequestBytes:   1024,
+			MaxTxnOps:         16,
+		},
+	})
+	defer inst1.Close()
+
+	dataDir2 := t.TempDir()
+	inst2 := newTestEmbeddedEtcd(t, etcdadpt.Config{
+		ClusterName:      "e2",
+		ClusterAddresses: "e2=http://127.0.0.1:32379",
+		ManagerAddress:   "http://127.0.0.1:32380",
+		Embedded:         etcdadpt.EmbeddedConfig{DataDir: dataDir2},
+	})
+	defer inst2.Close()
+
+	t.Run("data should be stored in the configured dirs", func(t *testing.T) {
+		_, err := os.Stat(filepath.Join(dataDir1, "member", "snap", "db"))
+		assert.NoError(t, err)
+		files, err := filepath.Glob(filepath.Join(walDir1, "*.wal"))
+		assert.NoError(t, err)
+		assert.NotEmpty(t, files)
+		_, err = os.Stat(filepath.Join(dataDir2, "member", "wal"))
+		assert.NoError(t, err)
+	})
+
+	t.Run("put to side by side instances should be isolated", func(t *testing.T) {
+		_, err := inst1.Do(context.Background(), etcdadpt.PUT,
+			etcdadpt.WithStrKey("/test_options"), etcdadpt.WithStrValue("e1"))
+		assert.NoError(t, err)
+
+		resp, err := inst2.Do(context.Background(), etcdadpt.GET, etcdadpt.WithStrKey("/test_options"))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(0), resp.Count)
+	})
+
+	t.Run("put a value larger than MaxRequestBytes should fail", func(t *testing.T) {
+		_, err := inst1.Do(context.Background(), etcdadpt.PUT,
+			etcdadpt.WithStrKey("/test_options"), etcdadpt.WithStrValue(strings.Repeat("a", 2048)))
+		assert.Error(t, err)
+
+		_, err = inst2.Do(context.Background(), etcdadpt.PUT,
+			etcdadpt.WithStrKey("/test_options"), etcdadpt.WithStrValue(strings.Repeat("a", 2048)))
+		assert.NoError(t, err)
+	})
+}
+
+func TestEtcdEmbed_WatchCompacted(t *testing.T) {
+	inst := newTestEmbeddedEtcd(t, etcdadpt.Config{
+		ClusterName:      "w1",
+		ClusterAddresses: "w1=" + freeURL(t),
+		ManagerAddress:   freeURL(t),
+		Embedded:         etcdadpt.EmbeddedConfig{DataDir: t.TempDir()},
+	})
+	defer inst.Close()
+
+	ctx := context.Background()
+	var rev int64
+	for i := 0; i < 3; i++ {
+		resp, err := inst.Do(ctx, etcdadpt.PUT, etcdadpt.WithStrKey("/test_compacted"), etcdadpt.WithStrValue("a"))
+		assert.NoError(t, err)
+		if rev == 0 {
+			rev = resp.Revision
+		}
+	}
+	assert.NoError(t, inst.Compact(ctx, 0))
+
+	t.Run("watch the compacted revision should return ErrCompacted", func(t *testing.T) {
+		wCtx, cancel := context.WithTimeout(ctx, time.Second)
+		defer cancel()
+		err := inst.Watch(wCtx, etcdadpt.WithStrKey("/test_compacted"), etcdadpt.WithRev(rev),
+			etcdadpt.WithWatchCallback(func(message string, evt *etcdadpt.Response) error {
+				return nil
+			}))
+		assert.Equal(t, rpctypes.ErrCompacted, err)
+	})
+}
@@ -0,0 +1,125 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package embedded
+
+import (
+	"strings"
+
+	"github.com/go-chassis/openlog"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/little-cui/etcdadpt/middleware/log"
+)
+
+// newServerLogger returns the zap logger of embedded etcd server, which writes
+// the logs to the adapter logger in the server category
+func newServerLogger(level string, mutes []string) (*zap.Logger, error) {
+	var lvl zapcore.Level
+	if len(level) > 0 {
+		if err := lvl.Set(level); err != nil {
+			return nil, err
+		}
+	}
+	return zap.New(&serverCore{
+		LevelEnabler: lvl,
+		logger:       log.GetCategoryLogger(log.CategoryServer),
+		mutes:        mutes,
+	}, zap.AddCaller()), nil
+}
+
+// fieldsEncoder encodes the fields only in JSON, without the time, level and message
+var fieldsEncoder = zapcore.NewJSONEncoder(zapcore.EncoderConfig{})
+
+// serverCore is the zapcore.Core bridging the zap logs to openlog.Logger,
+// the fields are appended to the message in JSON and also passed by openlog.WithTags
+type serverCore struct {
+	zapcore.LevelEnabler
+
+	logger openlog.Logger
+	// mutes the prefixes of the messages or the logger names, like 'raft', to drop
+	mutes  []string
+	fields []zapcore.Field
+}
+
+func (c *serverCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.fields = make([]zapcore.Field, 0, len(c.fields)+len(fields))
+	clone.fields = append(append(clone.fields, c.fields...), fields...)
+	return &clone
+}
+
+func (c *serverCore) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.Enabled(entry.Level) || c.muted(entry) {
+		return ce
+	}
+	return ce.AddCore(entry, c)
+}
+
+func (c *serverCore) muted(entry zapcore.Entry) bool {
+	for _, mute := range c.mutes {
+		if entry.LoggerName == mute || strings.HasPrefix(entry.Message, mute) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *serverCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	all := append(append(make([]zapcore.Field, 0, len(c.fields)+len(fields)), c.fields...), fields...)
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range all {
+		f.AddTo(enc)
+	}
+	tags := openlog.Tags(enc.Fields)
+	if len(entry.LoggerName) > 0 {
+		tags["logger"] = entry.LoggerName
+	}
+	if entry.Caller.Defined {
+		tags["caller"] = entry.Caller.TrimmedPath()
+	}
+
+	message := entry.Message
+	if len(all) > 0 {
+		buf, err := fieldsEncoder.EncodeEntry(zapcore.Entry{}, all)
+		if err != nil {
+			return err
+		}
+		message += " " + strings.TrimSpace(buf.String())
+		buf.Free()
+	}
+
+	opts := []openlog.Option{openlog.WithTags(tags)}
+	switch entry.Level {
+	case zapcore.DebugLevel:
+		c.logger.Debug(message, opts...)
+	case zapcore.InfoLevel:
+		c.logger.Info(message, opts...)
+	case zapcore.WarnLevel:
+		c.logger.Warn(message, opts...)
+	case zapcore.FatalLevel:
+		c.logger.Fatal(message, opts...)
+	default:
+		c.logger.Error(message, opts...)
+	}
+	return nil
+}
+
+func (c *serverCore) Sync() error {
+	return nil
+}
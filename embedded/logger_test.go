@@ -0,0 +1,130 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package embedded_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-chassis/openlog"
+	"github.com/little-cui/etcdadpt"
+	_ "github.com/little-cui/etcdadpt/embedded"
+	"github.com/little-cui/etcdadpt/middleware/log"
+	"github.com/stretchr/testify/assert"
+)
+
+type serverLog struct {
+	level   string
+	message string
+	tags    openlog.Tags
+}
+
+type recordLogger struct {
+	mux  sync.Mutex
+	logs []serverLog
+}
+
+func (r *recordLogger) record(level, message string, opts ...openlog.Option) {
+	r.mux.Lock()
+	r.logs = append(r.logs, serverLog{level: level, message: message, tags: openlog.ToOptions(opts...).Tags})
+	r.mux.Unlock()
+}
+func (r *recordLogger) Debug(message string, opts ...openlog.Option) {
+	r.record("debug", message, opts...)
+}
+func (r *recordLogger) Info(message string, opts ...openlog.Option) {
+	r.record("info", message, opts...)
+}
+func (r *recordLogger) Warn(message string, opts ...openlog.Option) {
+	r.record("warn", message, opts...)
+}
+func (r *recordLogger) Error(message string, opts ...openlog.Option) {
+	r.record("error", message, opts...)
+}
+func (r *recordLogger) Fatal(message string, opts ...openlog.Option) {
+	r.record("fatal", message, opts...)
+}
+
+// serverLogs returns the logs written by the embedded etcd server
+func (r *recordLogger) serverLogs() []serverLog {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	var logs []serverLog
+	for _, l := range r.logs {
+		if _, ok := l.tags["caller"]; ok {
+			logs = append(logs, l)
+		}
+	}
+	return logs
+}
+
+func TestServerLogger(t *testing.T) {
+	logger := &recordLogger{}
+	oldLogger := log.GetLogger()
+	log.SetLogger(logger)
+	defer log.SetLogger(oldLogger)
+
+	t.Run("server logs should be written to the adapter logger", func(t *testing.T) {
+		cfg := etcdadpt.Config{
+			Kind:             "embedded_etcd",
+			ClusterName:      "l1",
+			ClusterAddresses: "l1=http://127.0.0.1:38383",
+			ManagerAddress:   "http://127.0.0.1:38384",
+			Embedded: etcdadpt.EmbeddedConfig{
+				DataDir:  t.TempDir(),
+				LogLevel: "info",
+				MuteLogs: []string{"raft", "serving client traffic"},
+			},
+		}
+		cfg.Init()
+		inst, err := etcdadpt.NewInstance(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inst.Close()
+
+		logs := logger.serverLogs()
+		assert.NotEmpty(t, logs)
+		var started bool
+		for _, l := range logs {
+			assert.NotEqual(t, "debug", l.level)
+			assert.NotEqual(t, "raft", l.tags["logger"])
+			assert.NotContains(t, l.message, "serving client traffic")
+			if strings.HasPrefix(l.message, "starting an etcd server ") {
+				started = true
+				assert.Equal(t, "l1", l.tags["name"])
+				assert.Contains(t, l.message, `"name":"l1"`)
+			}
+		}
+		assert.True(t, started)
+	})
+
+	t.Run("invalid log level, should return error", func(t *testing.T) {
+		cfg := etcdadpt.Config{
+			Kind:             "embedded_etcd",
+			ClusterName:      "l2",
+			ClusterAddresses: "l2=http://127.0.0.1:38385",
+			ManagerAddress:   "http://127.0.0.1:38386",
+			Embedded:         etcdadpt.EmbeddedConfig{DataDir: t.TempDir(), LogLevel: "verbose"},
+		}
+		cfg.Init()
+		_, err := etcdadpt.NewInstance(cfg)
+		assert.Error(t, err)
+	})
+}
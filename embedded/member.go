@@ -0,0 +1,175 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package embedded
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.etcd.io/etcd/client/pkg/v3/transport"
+	"go.etcd.io/etcd/client/pkg/v3/types"
+	"go.etcd.io/etcd/server/v3/etcdserver/api/membership"
+
+	"github.com/little-cui/etcdadpt"
+	"github.com/little-cui/etcdadpt/middleware/log"
+)
+
+// versionPath the path of the peer handler to get the version of the member
+const versionPath = "/version"
+
+func (s *EtcdEmbed) ListMember(ctx context.Context) ([]*etcdadpt.Member, error) {
+	server := s.Embed.Server
+	var (
+		members []*etcdadpt.Member
+		others  []*etcdadpt.Member
+	)
+	for _, m := range server.Cluster().Members() {
+		member := toMember(m)
+		members = append(members, member)
+		if m.ID == server.ID() {
+			member.Healthy = true
+			continue
+		}
+		others = append(others, member)
+	}
+	if len(others) == 0 {
+		return members, nil
+	}
+	if err := s.checkMembersHealth(ctx, others); err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// checkMembersHealth sets the member healthy if any of its peer urls responds the
+// version request in time, the same as the members check each other, so it does
+// not depend on the client urls of the members
+func (s *EtcdEmbed) checkMembersHealth(ctx context.Context, members []*etcdadpt.Member) error {
+	tlsCfg, err := clientTLSConfig(s.Cfg, s.Embed.Config().PeerTLSInfo)
+	if err != nil {
+		return err
+	}
+	tr, err := transport.NewTransport(transport.TLSInfo{}, s.Cfg.DialTimeout)
+	if err != nil {
+		return err
+	}
+	tr.TLSClientConfig = tlsCfg
+	defer tr.CloseIdleConnections()
+
+	client := &http.Client{Transport: tr}
+	etcdadpt.CheckMembersHealth(ctx, members, func(member *etcdadpt.Member) []string {
+		return member.PeerURLs
+	}, func(ctx context.Context, u string) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u+versionPath, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("unexpected status %s", resp.Status)
+		}
+		return nil
+	})
+	return nil
+}
+
+func (s *EtcdEmbed) AddMember(ctx context.Context, peerURLs []string, isLearner bool) (*etcdadpt.Member, error) {
+	urls, err := types.NewURLs(peerURLs)
+	if err != nil {
+		return nil, err
+	}
+	otCtx, cancel := s.WithTimeout(ctx)
+	defer cancel()
+
+	now := time.Now()
+	var m *membership.Member
+	if isLearner {
+		m = membership.NewMemberAsLearner("", urls, "", &now)
+	} else {
+		m = membership.NewMember("", urls, "", &now)
+	}
+	if _, err := s.Embed.Server.AddMember(otCtx, *m); err != nil {
+		log.GetLogger().Error(fmt.Sprintf("add member %v failed, error: %s", peerURLs, err))
+		return nil, err
+	}
+	log.GetLogger().Info(fmt.Sprintf("added member %x %v, learner: %v", uint64(m.ID), peerURLs, isLearner))
+	return toMember(m), nil
+}
+
+func (s *EtcdEmbed) RemoveMember(ctx context.Context, id uint64) error {
+	otCtx, cancel := s.WithTimeout(ctx)
+	defer cancel()
+	if _, err := s.Embed.Server.RemoveMember(otCtx, id); err != nil {
+		log.GetLogger().Error(fmt.Sprintf("remove member %x failed, error: %s", id, err))
+		return toMemberError(err)
+	}
+	log.GetLogger().Info(fmt.Sprintf("removed member %x", id))
+	return nil
+}
+
+func (s *EtcdEmbed) PromoteMember(ctx context.Context, id uint64) error {
+	otCtx, cancel := s.WithTimeout(ctx)
+	defer cancel()
+	if _, err := s.Embed.Server.PromoteMember(otCtx, id); err != nil {
+		log.GetLogger().Error(fmt.Sprintf("promote member %x failed, error: %s", id, err))
+		return toMemberError(err)
+	}
+	log.GetLogger().Info(fmt.Sprintf("promoted member %x", id))
+	return nil
+}
+
+func (s *EtcdEmbed) UpdateMember(ctx context.Context, id uint64, peerURLs []string) error {
+	if _, err := types.NewURLs(peerURLs); err != nil {
+		return err
+	}
+	otCtx, cancel := s.WithTimeout(ctx)
+	defer cancel()
+	m := membership.Member{
+		ID:             types.ID(id),
+		RaftAttributes: membership.RaftAttributes{PeerURLs: peerURLs},
+	}
+	if _, err := s.Embed.Server.UpdateMember(otCtx, m); err != nil {
+		log.GetLogger().Error(fmt.Sprintf("update member %x peer urls %v failed, error: %s", id, peerURLs, err))
+		return toMemberError(err)
+	}
+	log.GetLogger().Info(fmt.Sprintf("updated member %x peer urls %v", id, peerURLs))
+	return nil
+}
+
+func toMember(m *membership.Member) *etcdadpt.Member {
+	return &etcdadpt.Member{
+		ID:         uint64(m.ID),
+		Name:       m.Name,
+		PeerURLs:   m.PeerURLs,
+		ClientURLs: m.ClientURLs,
+		IsLearner:  m.IsLearner,
+	}
+}
+
+func toMemberError(err error) error {
+	if err == membership.ErrIDNotFound {
+		return etcdadpt.ErrMemberNotFound
+	}
+	return err
+}
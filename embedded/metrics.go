@@ -0,0 +1,63 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package embedded
+
+import (
+	"context"
+	"time"
+
+	"github.com/little-cui/etcdadpt/middleware/metrics"
+)
+
+// serverMetricsInterval the interval to report the gauges of embedded etcd server
+const serverMetricsInterval = 10 * time.Second
+
+func (s *EtcdEmbed) reporter() metrics.Reporter {
+	return metrics.Reporter{Instance: s.Cfg.MetricsInstance}
+}
+
+func (s *EtcdEmbed) reportServerMetrics() {
+	server := s.Embed.Server
+	be := server.Backend()
+	s.reporter().ReportBackendInstance(len(server.Cluster().Members()))
+	s.reporter().ReportBackendRaftIndex(server.CommittedIndex(), server.AppliedIndex())
+	s.reporter().ReportBackendDBSize(be.Size(), be.SizeInUse())
+}
+
+func (s *EtcdEmbed) ReportMetricsLoop(ctx context.Context) {
+	s.reportServerMetrics()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(serverMetricsInterval):
+			s.reportServerMetrics()
+		}
+	}
+}
+
+func (s *EtcdEmbed) LeaderChangedLoop(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.Embed.Server.LeaderChangedNotify():
+			s.reporter().ReportBackendLeaderChanged()
+		}
+	}
+}
@@ -0,0 +1,173 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package embedded_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/little-cui/etcdadpt"
+	_ "github.com/little-cui/etcdadpt/embedded"
+	"github.com/little-cui/etcdadpt/middleware/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+var registry *prometheus.Registry
+
+// initMetrics inits the metrics with a new registry and closes them after the test
+func initMetrics(t *testing.T) {
+	registry = prometheus.NewRegistry()
+	metrics.Init(metrics.Options{Registerer: registry, InstanceName: "embedded", PrefixSegments: 1})
+	t.Cleanup(metrics.Close)
+}
+
+// gatherMetric returns the values of the metric grouped by the label,
+// the value of a histogram is the sample count
+func gatherMetric(t *testing.T, name, labelName string) map[string]float64 {
+	families, err := registry.Gather()
+	assert.NoError(t, err)
+	values := make(map[string]float64)
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			var key string
+			for _, label := range m.GetLabel() {
+				if label.GetName() == labelName {
+					key = label.GetValue()
+				}
+			}
+			switch {
+			case m.GetCounter() != nil:
+				values[key] += m.GetCounter().GetValue()
+			case m.GetGauge() != nil:
+				values[key] += m.GetGauge().GetValue()
+			case m.GetHistogram() != nil:
+				values[key] += float64(m.GetHistogram().GetSampleCount())
+			}
+		}
+	}
+	return values
+}
+
+func TestEtcdEmbed_Metrics(t *testing.T) {
+	initMetrics(t)
+
+	cfg := etcdadpt.Config{
+		Kind:             "embedded_etcd",
+		ClusterName:      "m1",
+		MetricsInstance:  "m1",
+		ClusterAddresses: "m1=http://127.0.0.1:38379",
+		ManagerAddress:   "http://127.0.0.1:38380",
+		Embedded:         etcdadpt.EmbeddedConfig{DataDir: t.TempDir()},
+	}
+	cfg.Init()
+	inst, err := etcdadpt.NewInstance(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inst.Close()
+
+	ctx := context.Background()
+	_, err = inst.Do(ctx, etcdadpt.PUT, etcdadpt.WithStrKey("/test_metrics/a"), etcdadpt.WithStrValue("a"))
+	assert.NoError(t, err)
+	_, err = inst.TxnWithCmp(ctx, []etcdadpt.OpOptions{etcdadpt.OpGet(etcdadpt.WithStrKey("/test_metrics/a"))}, nil, nil)
+	assert.NoError(t, err)
+	id, err := inst.LeaseGrant(ctx, 10)
+	assert.NoError(t, err)
+	assert.NoError(t, inst.LeaseRevoke(ctx, id))
+
+	t.Run("embedded operations should be reported with the same labels as remote", func(t *testing.T) {
+		operations := gatherMetric(t, "db_backend_operation_total", "operation")
+		assert.Equal(t, float64(1), operations["PUT"])
+		assert.Equal(t, float64(1), operations["TXN"])
+		assert.Equal(t, float64(1), operations["LEASE_GRANT"])
+		assert.Equal(t, float64(1), operations["LEASE_REVOKE"])
+		assert.Equal(t, float64(4), gatherMetric(t, "db_backend_operation_total", "instance")["m1"])
+	})
+
+	t.Run("embedded server gauges should be reported", func(t *testing.T) {
+		index := gatherMetric(t, "db_backend_raft_index", "type")
+		assert.True(t, index["committed"] > 0)
+		assert.True(t, index["applied"] > 0)
+		size := gatherMetric(t, "db_backend_db_size_bytes", "type")
+		assert.True(t, size["total"] > 0)
+		assert.Equal(t, float64(1), gatherMetric(t, "db_backend_total", "")[""])
+		assert.True(t, gatherMetric(t, "db_backend_raft_index", "instance")["m1"] > 0)
+	})
+}
+
+func TestEtcdEmbed_PayloadMetrics(t *testing.T) {
+	initMetrics(t)
+
+	cfg := etcdadpt.Config{
+		Kind:             "embedded_etcd",
+		ClusterName:      "m2",
+		ClusterAddresses: "m2=http://127.0.0.1:38381",
+		ManagerAddress:   "http://127.0.0.1:38382",
+		Embedded:         etcdadpt.EmbeddedConfig{DataDir: t.TempDir()},
+	}
+	cfg.Init()
+	inst, err := etcdadpt.NewInstance(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inst.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := make(chan struct{}, 1)
+	go func() {
+		_ = inst.Watch(ctx, etcdadpt.WithStrKey("/test_payload/"), etcdadpt.WithPrefix(),
+			etcdadpt.WithWatchCallback(func(message string, evt *etcdadpt.Response) error {
+				events <- struct{}{}
+				return nil
+			}))
+	}()
+	<-time.After(100 * time.Millisecond)
+
+	_, err = inst.Do(ctx, etcdadpt.PUT, etcdadpt.WithStrKey("/test_payload/a"), etcdadpt.WithStrValue("abc"))
+	assert.NoError(t, err)
+	select {
+	case <-events:
+	case <-time.After(3 * time.Second):
+		t.Fatal("watch event timed out")
+	}
+	resp, err := inst.Do(ctx, etcdadpt.GET, etcdadpt.WithStrKey("/test_payload/"), etcdadpt.WithPrefix(),
+		etcdadpt.WithOffset(0), etcdadpt.WithLimit(1))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(resp.Kvs))
+
+	t.Run("operations should be labeled by the key prefix", func(t *testing.T) {
+		latency := gatherMetric(t, "db_backend_operation_duration_seconds", "prefix")
+		assert.Equal(t, float64(2), latency["/test_payload"])
+	})
+
+	t.Run("payload size should be reported", func(t *testing.T) {
+		assert.Equal(t, float64(1), gatherMetric(t, "db_backend_request_value_bytes", "prefix")["/test_payload"])
+		assert.Equal(t, float64(1), gatherMetric(t, "db_backend_response_bytes", "prefix")["/test_payload"])
+	})
+
+	t.Run("watch events and paging pages should be counted", func(t *testing.T) {
+		assert.True(t, gatherMetric(t, "db_backend_watch_events_total", "")[""] >= 1)
+		assert.True(t, gatherMetric(t, "db_backend_paging_pages_total", "")[""] >= 1)
+	})
+}
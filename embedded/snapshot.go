@@ -0,0 +1,119 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package embedded
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"go.etcd.io/etcd/client/pkg/v3/fileutil"
+	"go.etcd.io/etcd/etcdutl/v3/snapshot"
+	"go.etcd.io/etcd/server/v3/embed"
+	"go.uber.org/zap"
+
+	"github.com/little-cui/etcdadpt/middleware/log"
+)
+
+const restoreDirSuffix = ".restore"
+
+// Snapshot writes the backend db and appends the sha256 hash like
+// the etcd maintenance snapshot, so it can be verified when restore
+func (s *EtcdEmbed) Snapshot(ctx context.Context, w io.Writer) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	snap := s.Embed.Server.Backend().Snapshot()
+	defer snap.Close()
+
+	h := sha256.New()
+	n, err := snap.WriteTo(&ctxWriter{ctx: ctx, w: io.MultiWriter(w, h)})
+	if err != nil {
+		// bolt does not wrap the error of the writer
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		log.GetLogger().Error(fmt.Sprintf("snapshot locally failed, error: %s", err))
+		return err
+	}
+	if _, err = w.Write(h.Sum(nil)); err != nil {
+		return err
+	}
+	log.GetLogger().Info(fmt.Sprintf("snapshot locally, size is %d", n))
+	return nil
+}
+
+// ctxWriter stops the copying once ctx is done
+type ctxWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+func (cw *ctxWriter) Write(p []byte) (int, error) {
+	if err := cw.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cw.w.Write(p)
+}
+
+// restore seeds the data dir from the snapshot file, skip if the member
+// has been initialized
+func restore(serverCfg *embed.Config, file string) error {
+	memberDir := filepath.Join(serverCfg.Dir, "member")
+	if fileutil.Exist(memberDir) {
+		log.GetLogger().Warn(fmt.Sprintf("member %s has been initialized, skip restoring from %s",
+			serverCfg.Name, file))
+		return nil
+	}
+
+	peerURLs := make([]string, 0, len(serverCfg.APUrls))
+	for _, u := range serverCfg.APUrls {
+		peerURLs = append(peerURLs, u.String())
+	}
+	// the data dir may contain other files, e.g. TLS files, so restore
+	// into a temporary dir and then move the member dir back
+	tmpDir := filepath.Clean(serverCfg.Dir) + restoreDirSuffix
+	if err := os.RemoveAll(tmpDir); err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	err := snapshot.NewV3(zap.NewNop()).Restore(snapshot.RestoreConfig{
+		SnapshotPath:        file,
+		Name:                serverCfg.Name,
+		OutputDataDir:       tmpDir,
+		OutputWALDir:        serverCfg.WalDir,
+		PeerURLs:            peerURLs,
+		InitialCluster:      serverCfg.InitialCluster,
+		InitialClusterToken: serverCfg.InitialClusterToken,
+	})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(serverCfg.Dir, 0700); err != nil {
+		return err
+	}
+	if err := os.Rename(filepath.Join(tmpDir, "member"), memberDir); err != nil {
+		return err
+	}
+	log.GetLogger().Warn(fmt.Sprintf("member %s restored from %s", serverCfg.Name, file))
+	return nil
+}
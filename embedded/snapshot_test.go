@@ -0,0 +1,118 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package embedded_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/little-cui/etcdadpt"
+	"github.com/little-cui/etcdadpt/embedded"
+	"github.com/stretchr/testify/assert"
+)
+
+// cancelWriter cancels the context after the first write
+type cancelWriter struct {
+	cancel context.CancelFunc
+	writes int
+}
+
+func (w *cancelWriter) Write(p []byte) (int, error) {
+	w.writes++
+	w.cancel()
+	return len(p), nil
+}
+
+func TestEtcdEmbed_Snapshot(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "snapshot.db")
+
+	t.Run("snapshot should pass", func(t *testing.T) {
+		inst := newTestEmbeddedEtcd(t, etcdadpt.Config{
+			ClusterName:      "s0",
+			ClusterAddresses: "s0=http://127.0.0.1:35379",
+			ManagerAddress:   "http://127.0.0.1:35380",
+			Embedded:         etcdadpt.EmbeddedConfig{DataDir: t.TempDir()},
+		})
+		defer inst.Close()
+
+		_, err := inst.Do(context.Background(), etcdadpt.PUT,
+			etcdadpt.WithStrKey("/test_snapshot"), etcdadpt.WithStrValue("a"))
+		assert.NoError(t, err)
+
+		f, err := os.Create(file)
+		assert.NoError(t, err)
+		defer f.Close()
+		err = inst.(etcdadpt.Snapshotter).Snapshot(context.Background(), f)
+		assert.NoError(t, err)
+	})
+
+	t.Run("snapshot canceled while copying should fail", func(t *testing.T) {
+		inst := newTestEmbeddedEtcd(t, etcdadpt.Config{
+			ClusterName:      "s3",
+			ClusterAddresses: "s3=http://127.0.0.1:38391",
+			ManagerAddress:   "http://127.0.0.1:38392",
+			Embedded:         etcdadpt.EmbeddedConfig{DataDir: t.TempDir()},
+		})
+		defer inst.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		w := &cancelWriter{cancel: cancel}
+		err := inst.(etcdadpt.Snapshotter).Snapshot(ctx, w)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, 1, w.writes)
+	})
+
+	t.Run("restore from snapshot should pass", func(t *testing.T) {
+		dataDir := t.TempDir()
+		inst := newTestEmbeddedEtcd(t, etcdadpt.Config{
+			ClusterName:      "s1",
+			ClusterAddresses: "s1=http://127.0.0.1:35479",
+			ManagerAddress:   "http://127.0.0.1:35480",
+			Embedded:         etcdadpt.EmbeddedConfig{DataDir: dataDir, RestoreFile: file},
+		})
+		defer inst.Close()
+
+		resp, err := inst.Do(context.Background(), etcdadpt.GET, etcdadpt.WithStrKey("/test_snapshot"))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), resp.Count)
+		assert.Equal(t, "a", string(resp.Kvs[0].Value))
+	})
+
+	t.Run("restore from corrupted snapshot should fail", func(t *testing.T) {
+		data, err := os.ReadFile(file)
+		assert.NoError(t, err)
+		data[len(data)-1]++
+		corrupted := filepath.Join(t.TempDir(), "corrupted.db")
+		assert.NoError(t, os.WriteFile(corrupted, data, 0600))
+
+		cfg := etcdadpt.Config{
+			ClusterName:      "s2",
+			ClusterAddresses: "s2=http://127.0.0.1:35579",
+			ManagerAddress:   "http://127.0.0.1:35580",
+			Embedded:         etcdadpt.EmbeddedConfig{DataDir: t.TempDir(), RestoreFile: corrupted},
+		}
+		cfg.Init()
+		inst := embedded.NewEmbeddedEtcd(cfg)
+		defer inst.Close()
+		err = <-inst.Err()
+		assert.Error(t, err)
+	})
+}
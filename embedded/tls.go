@@ -0,0 +1,118 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package embedded
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.etcd.io/etcd/client/pkg/v3/transport"
+
+	"github.com/little-cui/etcdadpt"
+)
+
+const (
+	tlsCertFile = "server.crt"
+	tlsKeyFile  = "server.key"
+	tlsCAFile   = "ca.crt"
+)
+
+var (
+	ErrRequiredCert     = errors.New("required CertFile and KeyFile, or TLSConfig with certificates")
+	ErrRequiredClientCA = errors.New("required CAFile or CACertificates to verify the client certificates of TLSConfig.ClientCAs")
+)
+
+// newTLSInfo returns the TLS info to serve client and peer traffic, the PEM files
+// in cfg take precedence over the cfg.TLSConfig. The key pair and the CA certificates
+// of cfg.TLSConfig are written to the returned temporary dir, which should be removed
+// after the server stopped
+func newTLSInfo(cfg etcdadpt.Config) (transport.TLSInfo, string, error) {
+	info := transport.TLSInfo{
+		CertFile:       cfg.CertFile,
+		KeyFile:        cfg.KeyFile,
+		TrustedCAFile:  cfg.CAFile,
+		ClientCertAuth: cfg.ClientCertAuth,
+	}
+	if !info.Empty() {
+		return info, "", nil
+	}
+
+	tlsCfg := cfg.TLSConfig
+	if tlsCfg == nil || len(tlsCfg.Certificates) == 0 {
+		return info, "", ErrRequiredCert
+	}
+	if tlsCfg.ClientCAs != nil && len(info.TrustedCAFile) == 0 && len(cfg.CACertificates) == 0 {
+		return info, "", ErrRequiredClientCA
+	}
+
+	// etcd server only loads the key pair and CAs from files, on every handshake
+	dir, err := os.MkdirTemp("", "etcdadpt-tls-")
+	if err != nil {
+		return info, "", err
+	}
+	if err := writeTLSFiles(&info, tlsCfg.Certificates[0], cfg.CACertificates, dir); err != nil {
+		os.RemoveAll(dir)
+		return info, "", err
+	}
+	info.CipherSuites = tlsCfg.CipherSuites
+	if tlsCfg.ClientAuth == tls.RequireAndVerifyClientCert {
+		info.ClientCertAuth = true
+	}
+	return info, dir, nil
+}
+
+func writeTLSFiles(info *transport.TLSInfo, cert tls.Certificate, cas []*x509.Certificate, dir string) error {
+	var certPEM []byte
+	for _, der := range cert.Certificate {
+		certPEM = append(certPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		return err
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+
+	info.CertFile = filepath.Join(dir, tlsCertFile)
+	info.KeyFile = filepath.Join(dir, tlsKeyFile)
+	if err := os.WriteFile(info.CertFile, certPEM, 0600); err != nil {
+		return err
+	}
+	if err := os.WriteFile(info.KeyFile, keyPEM, 0600); err != nil {
+		return err
+	}
+	if len(info.TrustedCAFile) > 0 || len(cas) == 0 {
+		return nil
+	}
+
+	var caPEM []byte
+	for _, ca := range cas {
+		caPEM = append(caPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw})...)
+	}
+	info.TrustedCAFile = filepath.Join(dir, tlsCAFile)
+	return os.WriteFile(info.TrustedCAFile, caPEM, 0600)
+}
+
+// toHTTPS makes sure that no plaintext url will be served when TLS enabled
+func toHTTPS(addrs string) string {
+	return strings.ReplaceAll(addrs, "http://", "https://")
+}
@@ -0,0 +1,217 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package embedded_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/little-cui/etcdadpt"
+	"github.com/little-cui/etcdadpt/embedded"
+	"github.com/stretchr/testify/assert"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+func newTestCert(t *testing.T, cn string, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	signer, signerKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	assert.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.NoError(t, err)
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func (c *testCert) keyPair(t *testing.T) tls.Certificate {
+	pair, err := tls.X509KeyPair(c.certPEM, c.keyPEM)
+	assert.NoError(t, err)
+	return pair
+}
+
+func TestNewEmbeddedEtcd_TLS(t *testing.T) {
+	ca := newTestCert(t, "ca", nil)
+	server := newTestCert(t, "server", ca)
+	client := newTestCert(t, "client", ca)
+
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	assert.NoError(t, os.WriteFile(caFile, ca.certPEM, 0600))
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+
+	inst := newTestEmbeddedEtcd(t, etcdadpt.Config{
+		ClusterName:      "tls",
+		ClusterAddresses: "tls=http://127.0.0.1:30379",
+		ManagerAddress:   "http://127.0.0.1:30380",
+		SslEnabled:       true,
+		TLSConfig:        &tls.Config{Certificates: []tls.Certificate{server.keyPair(t)}},
+		CAFile:           caFile,
+		ClientCertAuth:   true,
+		Embedded:         etcdadpt.EmbeddedConfig{DataDir: t.TempDir()},
+	})
+	defer inst.Close()
+
+	put := func(tlsCfg *tls.Config) error {
+		cli, err := clientv3.New(clientv3.Config{
+			Endpoints:   []string{"127.0.0.1:30379"},
+			DialTimeout: time.Second,
+			TLS:         tlsCfg,
+		})
+		if err != nil {
+			return err
+		}
+		defer cli.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_, err = cli.Put(ctx, "/test_tls", "a")
+		return err
+	}
+
+	t.Run("request with client certificate should pass", func(t *testing.T) {
+		err := put(&tls.Config{RootCAs: pool, Certificates: []tls.Certificate{client.keyPair(t)}})
+		assert.NoError(t, err)
+	})
+
+	t.Run("request without client certificate should fail", func(t *testing.T) {
+		err := put(&tls.Config{RootCAs: pool})
+		assert.Error(t, err)
+	})
+
+	t.Run("plaintext request should fail", func(t *testing.T) {
+		err := put(nil)
+		assert.Error(t, err)
+	})
+}
+
+func TestNewEmbeddedEtcd_TLSConfig(t *testing.T) {
+	ca := newTestCert(t, "ca", nil)
+	server := newTestCert(t, "server", ca)
+	client := newTestCert(t, "client", ca)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+
+	tlsDirs := func() []string {
+		dirs, err := filepath.Glob(filepath.Join(os.TempDir(), "etcdadpt-tls-*"))
+		assert.NoError(t, err)
+		return dirs
+	}
+	before := len(tlsDirs())
+
+	dataDir := t.TempDir()
+	inst := newTestEmbeddedEtcd(t, etcdadpt.Config{
+		ClusterName:      "tls2",
+		ClusterAddresses: "tls2=http://127.0.0.1:38387",
+		ManagerAddress:   "http://127.0.0.1:38388",
+		SslEnabled:       true,
+		TLSConfig: &tls.Config{
+			Certificates: []tls.Certificate{server.keyPair(t)},
+			ClientCAs:    pool,
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+		},
+		CACertificates: []*x509.Certificate{ca.cert},
+		Embedded:       etcdadpt.EmbeddedConfig{DataDir: dataDir},
+	})
+
+	t.Run("the client certificate signed by the CACertificates should pass", func(t *testing.T) {
+		cli, err := clientv3.New(clientv3.Config{
+			Endpoints:   []string{"127.0.0.1:38387"},
+			DialTimeout: time.Second,
+			TLS:         &tls.Config{RootCAs: pool, Certificates: []tls.Certificate{client.keyPair(t)}},
+		})
+		assert.NoError(t, err)
+		defer cli.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_, err = cli.Put(ctx, "/test_tls", "a")
+		assert.NoError(t, err)
+	})
+
+	t.Run("the private key should be written to a temporary dir and removed after closed", func(t *testing.T) {
+		_, err := os.Stat(filepath.Join(dataDir, "tls"))
+		assert.True(t, os.IsNotExist(err))
+
+		dirs := tlsDirs()
+		assert.Equal(t, before+1, len(dirs))
+		for _, dir := range dirs {
+			info, err := os.Stat(filepath.Join(dir, "server.key"))
+			if err == nil {
+				assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+			}
+		}
+
+		inst.Close()
+		assert.Equal(t, before, len(tlsDirs()))
+	})
+
+	t.Run("ClientCAs without CACertificates, should return error", func(t *testing.T) {
+		cfg := etcdadpt.Config{
+			ClusterName:      "tls3",
+			ClusterAddresses: "tls3=http://127.0.0.1:38389",
+			ManagerAddress:   "http://127.0.0.1:38390",
+			SslEnabled:       true,
+			TLSConfig:        &tls.Config{Certificates: []tls.Certificate{server.keyPair(t)}, ClientCAs: pool},
+			Embedded:         etcdadpt.EmbeddedConfig{DataDir: t.TempDir()},
+		}
+		cfg.Init()
+		inst := embedded.NewEmbeddedEtcd(cfg)
+		assert.Equal(t, embedded.ErrRequiredClientCA, <-inst.Err())
+		assert.Equal(t, before, len(tlsDirs()))
+	})
+}
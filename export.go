@@ -0,0 +1,387 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package etcdadpt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/go-chassis/openlog"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/little-cui/etcdadpt/middleware/log"
+)
+
+const (
+	// ImportSkipExisting skip the key if it exists
+	ImportSkipExisting ImportMode = iota
+	// ImportOverwrite overwrite the key if it exists
+	ImportOverwrite
+	// ImportFailOnConflict stop importing if any key exists
+	ImportFailOnConflict
+)
+
+var ErrImportConflict = errors.New("import key conflict")
+
+type ImportMode int
+
+func (im ImportMode) String() string {
+	switch im {
+	case ImportSkipExisting:
+		return "SKIP_EXISTING"
+	case ImportOverwrite:
+		return "OVERWRITE"
+	case ImportFailOnConflict:
+		return "FAIL_ON_CONFLICT"
+	default:
+		return "IMPORT_MODE" + fmt.Sprint(int(im))
+	}
+}
+
+// ExportRecord is one line of the JSON Lines export file,
+// the Value is encoded in base64
+type ExportRecord struct {
+	Key            string `json:"key"`
+	Value          []byte `json:"value"`
+	Lease          int64  `json:"lease,omitempty"`
+	LeaseTTL       int64  `json:"leaseTTL,omitempty"`
+	CreateRevision int64  `json:"createRevision"`
+	ModRevision    int64  `json:"modRevision"`
+	Version        int64  `json:"version"`
+}
+
+type ImportResult struct {
+	Imported int64
+	Skipped  int64
+}
+
+// Export writes the kvs with the prefix to w in JSON Lines format, the pages are
+// listed by key at the revision of the first page, so the export is consistent
+// even if the prefix is written meanwhile
+func Export(ctx context.Context, w io.Writer, prefix string) (int64, error) {
+	encoder := json.NewEncoder(w)
+	ttls := make(map[int64]int64)
+	end := clientv3.GetPrefixRangeEnd(prefix)
+
+	var (
+		count int64
+		rev   int64
+	)
+	for next := prefix; ; {
+		resp, err := Instance().Do(ctx, GET, WithStrKey(next), WithStrEndKey(end),
+			WithRev(rev), WithLimit(DefaultPageCount))
+		if err != nil {
+			return count, err
+		}
+		rev = resp.Revision
+		for _, kv := range resp.Kvs {
+			ttl, err := getLeaseTTL(ctx, ttls, kv.Lease)
+			if err != nil {
+				return count, err
+			}
+			err = encoder.Encode(&ExportRecord{
+				Key:            string(kv.Key),
+				Value:          kv.Value,
+				Lease:          kv.Lease,
+				LeaseTTL:       ttl,
+				CreateRevision: kv.CreateRevision,
+				ModRevision:    kv.ModRevision,
+				Version:        kv.Version,
+			})
+			if err != nil {
+				return count, err
+			}
+			count++
+		}
+		if int64(len(resp.Kvs)) < DefaultPageCount {
+			return count, nil
+		}
+		// the next page starts from the key right after the last one
+		next = string(resp.Kvs[len(resp.Kvs)-1].Key) + "\x00"
+	}
+}
+
+func getLeaseTTL(ctx context.Context, ttls map[int64]int64, leaseID int64) (int64, error) {
+	if leaseID == 0 {
+		return 0, nil
+	}
+	if ttl, ok := ttls[leaseID]; ok {
+		return ttl, nil
+	}
+	ttl, err := LeaseTimeToLive(ctx, leaseID)
+	if err != nil && err != ErrLeaseNotFound {
+		return 0, err
+	}
+	ttls[leaseID] = ttl
+	return ttl, nil
+}
+
+// ImportOption configures the Import
+type ImportOption func(*importer)
+
+// WithImportBatchSize sets the number of the records put in one txn, it should not be greater
+// than the max txn ops of the server, the default value is MaxTxnNumberOneTime
+func WithImportBatchSize(n int) ImportOption {
+	return func(im *importer) { im.batchSize = n }
+}
+
+// importer grants the leases only for the records to be written,
+// the leases unused for the concurrent writing are revoked at the end
+type importer struct {
+	mode      ImportMode
+	batchSize int
+	result    *ImportResult
+	// leases the exported lease id to the granted one
+	leases map[int64]int64
+	// used the granted leases attached to the written records
+	used map[int64]struct{}
+}
+
+// Import reads the JSON Lines records from r and puts them in batches,
+// the records with lease are attached to the new granted leases.
+// The duplicate keys are written in order as if the records were imported one by one.
+// With ImportFailOnConflict, all the records are read and their keys are checked
+// before writing anything, a duplicate key is also a conflict, but the keys created concurrently can still fail a
+// later batch, then the batches written before are kept and counted in Imported
+func Import(ctx context.Context, r io.Reader, mode ImportMode, opts ...ImportOption) (*ImportResult, error) {
+	im := &importer{
+		mode:      mode,
+		batchSize: MaxTxnNumberOneTime,
+		result:    &ImportResult{},
+		leases:    make(map[int64]int64),
+		used:      make(map[int64]struct{}),
+	}
+	for _, opt := range opts {
+		opt(im)
+	}
+	if im.batchSize <= 0 {
+		im.batchSize = MaxTxnNumberOneTime
+	}
+	defer im.revokeUnused(ctx)
+
+	next := decodeNext(json.NewDecoder(r))
+	if im.mode == ImportFailOnConflict {
+		records, err := im.checkConflicts(ctx, next)
+		if err != nil {
+			return im.result, err
+		}
+		next = sliceNext(records)
+	}
+
+	batch := make([]*ExportRecord, 0, im.batchSize)
+	keys := make(map[string]struct{}, im.batchSize)
+	for {
+		record, err := next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return im.result, err
+		}
+		// etcd rejects a txn putting the same key twice,
+		// so the batch is written before the duplicate key
+		if _, ok := keys[record.Key]; ok || len(batch) == im.batchSize {
+			if err = im.importBatch(ctx, batch); err != nil {
+				return im.result, err
+			}
+			batch = batch[:0]
+			keys = make(map[string]struct{}, im.batchSize)
+		}
+		batch = append(batch, record)
+		keys[record.Key] = struct{}{}
+	}
+	if len(batch) == 0 {
+		return im.result, nil
+	}
+	return im.result, im.importBatch(ctx, batch)
+}
+
+// decodeNext returns the function to decode the records one by one, io.EOF at the end
+func decodeNext(decoder *json.Decoder) func() (*ExportRecord, error) {
+	return func() (*ExportRecord, error) {
+		record := &ExportRecord{}
+		if err := decoder.Decode(record); err != nil {
+			return nil, err
+		}
+		return record, nil
+	}
+}
+
+// sliceNext returns the function to iterate the records, io.EOF at the end
+func sliceNext(records []*ExportRecord) func() (*ExportRecord, error) {
+	return func() (*ExportRecord, error) {
+		if len(records) == 0 {
+			return nil, io.EOF
+		}
+		record := records[0]
+		records = records[1:]
+		return record, nil
+	}
+}
+
+// checkConflicts reads all the records and returns ErrImportConflict if any key exists or is duplicated
+func (im *importer) checkConflicts(ctx context.Context, next func() (*ExportRecord, error)) ([]*ExportRecord, error) {
+	var records []*ExportRecord
+	keys := make(map[string]struct{})
+	for {
+		record, err := next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := keys[record.Key]; ok {
+			return nil, fmt.Errorf("%w, key %s is duplicated", ErrImportConflict, record.Key)
+		}
+		keys[record.Key] = struct{}{}
+		records = append(records, record)
+	}
+	for start := 0; start < len(records); start += im.batchSize {
+		end := start + im.batchSize
+		if end > len(records) {
+			end = len(records)
+		}
+		resp, err := Instance().TxnWithCmp(ctx, importGetOps(records[start:end]), nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.Kvs) > 0 {
+			return nil, fmt.Errorf("%w, key %s exists", ErrImportConflict, resp.Kvs[0].Key)
+		}
+	}
+	return records, nil
+}
+
+func (im *importer) importBatch(ctx context.Context, batch []*ExportRecord) error {
+	if im.mode == ImportOverwrite {
+		_, err := im.putWithCmp(ctx, batch, nil, nil)
+		return err
+	}
+
+	// skip the existing keys before granting the leases
+	resp, err := Instance().TxnWithCmp(ctx, importGetOps(batch), nil, nil)
+	if err != nil {
+		return err
+	}
+	for {
+		batch, err = im.skipExisting(batch, resp.Kvs)
+		if err != nil || len(batch) == 0 {
+			return err
+		}
+		cmps := make([]CmpOptions, 0, len(batch))
+		for _, record := range batch {
+			cmps = append(cmps, NotExistKey(record.Key))
+		}
+		// put all if none of them exist, or else get the keys created concurrently
+		resp, err = im.putWithCmp(ctx, batch, cmps, importGetOps(batch))
+		if err != nil || resp.Succeeded {
+			return err
+		}
+	}
+}
+
+func (im *importer) putWithCmp(ctx context.Context, batch []*ExportRecord, cmps []CmpOptions, fail []OpOptions) (*Response, error) {
+	ops := make([]OpOptions, 0, len(batch))
+	for _, record := range batch {
+		op, err := im.toPutOp(ctx, record)
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, op)
+	}
+	resp, err := Instance().TxnWithCmp(ctx, ops, cmps, fail)
+	if err != nil {
+		return nil, err
+	}
+	if len(cmps) > 0 && !resp.Succeeded {
+		return resp, nil
+	}
+	for _, op := range ops {
+		if op.Lease != 0 {
+			im.used[op.Lease] = struct{}{}
+		}
+	}
+	im.result.Imported += int64(len(batch))
+	return resp, nil
+}
+
+func (im *importer) toPutOp(ctx context.Context, record *ExportRecord) (OpOptions, error) {
+	opts := []OpOption{WithStrKey(record.Key), WithValue(record.Value)}
+	if record.Lease == 0 || record.LeaseTTL <= 0 {
+		return OpPut(opts...), nil
+	}
+	leaseID, ok := im.leases[record.Lease]
+	if !ok {
+		var err error
+		leaseID, err = Instance().LeaseGrant(ctx, record.LeaseTTL)
+		if err != nil {
+			return OpOptions{}, err
+		}
+		im.leases[record.Lease] = leaseID
+	}
+	return OpPut(append(opts, WithLease(leaseID))...), nil
+}
+
+// skipExisting returns the records whose keys are not in kvs
+func (im *importer) skipExisting(batch []*ExportRecord, kvs []*mvccpb.KeyValue) ([]*ExportRecord, error) {
+	if len(kvs) == 0 {
+		return batch, nil
+	}
+	if im.mode == ImportFailOnConflict {
+		return nil, fmt.Errorf("%w, key %s exists", ErrImportConflict, kvs[0].Key)
+	}
+	existing := make(map[string]struct{}, len(kvs))
+	for _, kv := range kvs {
+		existing[string(kv.Key)] = struct{}{}
+	}
+	remain := batch[:0]
+	for _, record := range batch {
+		if _, ok := existing[record.Key]; ok {
+			im.result.Skipped++
+			continue
+		}
+		remain = append(remain, record)
+	}
+	return remain, nil
+}
+
+func (im *importer) revokeUnused(ctx context.Context) {
+	for _, leaseID := range im.leases {
+		if _, ok := im.used[leaseID]; ok {
+			continue
+		}
+		if err := Instance().LeaseRevoke(ctx, leaseID); err != nil && err != ErrLeaseNotFound {
+			log.GetLogger().Warn("revoke the unused lease failed", openlog.WithTags(openlog.Tags{
+				"lease": leaseID,
+			}), openlog.WithErr(err))
+		}
+	}
+}
+
+func importGetOps(batch []*ExportRecord) []OpOptions {
+	gets := make([]OpOptions, 0, len(batch))
+	for _, record := range batch {
+		gets = append(gets, OpGet(WithStrKey(record.Key), WithKeyOnly()))
+	}
+	return gets
+}
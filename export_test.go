@@ -0,0 +1,142 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package etcdadpt_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"testing"
+
+	_ "github.com/little-cui/etcdadpt/test"
+
+	"github.com/little-cui/etcdadpt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportAndImport(t *testing.T) {
+	ctx := context.Background()
+	prefix := "/test_export/"
+	defer etcdadpt.Instance().Do(ctx, etcdadpt.DEL, etcdadpt.WithStrKey(prefix), etcdadpt.WithPrefix())
+
+	var input bytes.Buffer
+	encoder := json.NewEncoder(&input)
+	for i := 0; i < etcdadpt.DefaultPageCount+1; i++ {
+		v := strconv.Itoa(i)
+		assert.NoError(t, encoder.Encode(&etcdadpt.ExportRecord{Key: prefix + v, Value: []byte(v)}))
+	}
+
+	t.Run("import more than one batch should be ok", func(t *testing.T) {
+		result, err := etcdadpt.Import(ctx, bytes.NewReader(input.Bytes()), etcdadpt.ImportOverwrite)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(etcdadpt.DefaultPageCount+1), result.Imported)
+	})
+
+	t.Run("import with batch size should be ok", func(t *testing.T) {
+		result, err := etcdadpt.Import(ctx, bytes.NewReader(input.Bytes()), etcdadpt.ImportOverwrite,
+			etcdadpt.WithImportBatchSize(100))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(etcdadpt.DefaultPageCount+1), result.Imported)
+	})
+
+	leaseID, err := etcdadpt.Instance().LeaseGrant(ctx, 60)
+	assert.NoError(t, err)
+	_, err = etcdadpt.Instance().Do(ctx, etcdadpt.PUT, etcdadpt.WithStrKey(prefix+"lease"),
+		etcdadpt.WithStrValue("lease"), etcdadpt.WithLease(leaseID))
+	assert.NoError(t, err)
+
+	var output bytes.Buffer
+	t.Run("export more than one page should be ok", func(t *testing.T) {
+		n, err := etcdadpt.Export(ctx, &output, prefix)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(etcdadpt.DefaultPageCount+2), n)
+		assert.Equal(t, etcdadpt.DefaultPageCount+2, bytes.Count(output.Bytes(), []byte("\n")))
+
+		var record etcdadpt.ExportRecord
+		decoder := json.NewDecoder(bytes.NewReader(output.Bytes()))
+		for decoder.More() {
+			assert.NoError(t, decoder.Decode(&record))
+			if record.Key == prefix+"lease" {
+				break
+			}
+		}
+		assert.Equal(t, prefix+"lease", record.Key)
+		assert.Equal(t, []byte("lease"), record.Value)
+		assert.Equal(t, leaseID, record.Lease)
+		assert.True(t, record.LeaseTTL > 0 && record.LeaseTTL <= 60)
+	})
+
+	t.Run("import existing keys with fail mode should return conflict", func(t *testing.T) {
+		_, err := etcdadpt.Import(ctx, bytes.NewReader(output.Bytes()), etcdadpt.ImportFailOnConflict)
+		assert.True(t, errors.Is(err, etcdadpt.ErrImportConflict))
+	})
+
+	t.Run("import a later batch conflicts with fail mode should write nothing", func(t *testing.T) {
+		var conflict bytes.Buffer
+		encoder := json.NewEncoder(&conflict)
+		for _, key := range []string{"new_a", "new_b", "0"} {
+			assert.NoError(t, encoder.Encode(&etcdadpt.ExportRecord{Key: prefix + key, Value: []byte(key)}))
+		}
+		result, err := etcdadpt.Import(ctx, &conflict, etcdadpt.ImportFailOnConflict,
+			etcdadpt.WithImportBatchSize(1))
+		assert.True(t, errors.Is(err, etcdadpt.ErrImportConflict))
+		assert.Equal(t, int64(0), result.Imported)
+
+		exist, err := etcdadpt.Exist(ctx, prefix+"new_a")
+		assert.NoError(t, err)
+		assert.False(t, exist)
+	})
+
+	t.Run("import duplicate keys should write them in order", func(t *testing.T) {
+		var dup bytes.Buffer
+		encoder := json.NewEncoder(&dup)
+		for _, v := range []string{"a", "b"} {
+			assert.NoError(t, encoder.Encode(&etcdadpt.ExportRecord{Key: prefix + "dup", Value: []byte(v)}))
+		}
+		result, err := etcdadpt.Import(ctx, bytes.NewReader(dup.Bytes()), etcdadpt.ImportOverwrite)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), result.Imported)
+		kv, err := etcdadpt.Get(ctx, prefix+"dup")
+		assert.NoError(t, err)
+		assert.Equal(t, "b", string(kv.Value))
+
+		_, err = etcdadpt.Import(ctx, bytes.NewReader(dup.Bytes()), etcdadpt.ImportFailOnConflict)
+		assert.True(t, errors.Is(err, etcdadpt.ErrImportConflict))
+
+		_, err = etcdadpt.Instance().Do(ctx, etcdadpt.DEL, etcdadpt.WithStrKey(prefix+"dup"))
+		assert.NoError(t, err)
+	})
+
+	t.Run("import existing keys with skip mode should skip them", func(t *testing.T) {
+		_, err := etcdadpt.Instance().Do(ctx, etcdadpt.DEL, etcdadpt.WithStrKey(prefix+"lease"))
+		assert.NoError(t, err)
+
+		result, err := etcdadpt.Import(ctx, bytes.NewReader(output.Bytes()), etcdadpt.ImportSkipExisting)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), result.Imported)
+		assert.Equal(t, int64(etcdadpt.DefaultPageCount+1), result.Skipped)
+
+		kv, err := etcdadpt.Get(ctx, prefix+"lease")
+		assert.NoError(t, err)
+		assert.Equal(t, "lease", string(kv.Value))
+		assert.NotZero(t, kv.Lease)
+		assert.NotEqual(t, leaseID, kv.Lease)
+	})
+}
@@ -0,0 +1,240 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package etcdadpt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultHealthTimeout = 3 * time.Second
+	// DefaultQuotaBackendBytes the default backend quota of etcd server, 2GB
+	DefaultQuotaBackendBytes = 2 * 1024 * 1024 * 1024
+
+	HealthStatusOK   = "ok"
+	HealthStatusFail = "fail"
+)
+
+// Health is the connection health reported by the plugins
+type Health struct {
+	// Live is false if the client is closed or the embedded server stopped
+	Live bool
+	// Endpoints the client endpoints connected
+	Endpoints []string
+	// LastSync and LastSyncError the time and the error of the last members sync,
+	// LastSync is zero if the members are never synced
+	LastSync      time.Time
+	LastSyncError error
+	// QuotaBackendBytes the backend quota of the server, 0 if unknown
+	QuotaBackendBytes int64
+}
+
+// HealthReporter is implemented by the plugins reporting the health without requests
+type HealthReporter interface {
+	Health() Health
+}
+
+type HealthOptions struct {
+	// Timeout the timeout of the status request of the readiness check, default DefaultHealthTimeout
+	Timeout time.Duration
+	// QuotaBackendBytes the backend quota of the remote etcd to calculate the quota usage,
+	// default DefaultQuotaBackendBytes, the embedded etcd uses its own quota
+	QuotaBackendBytes int64
+	// MaxQuotaUsage the client is not ready if the ratio of db size to quota exceeds it,
+	// 0 means never
+	MaxQuotaUsage float64
+}
+
+// HealthResult is the result of the health check, the body of HealthHandler
+type HealthResult struct {
+	// Status HealthStatusOK or HealthStatusFail
+	Status string `json:"status"`
+	Live   bool   `json:"live"`
+	Ready  bool   `json:"ready"`
+
+	Endpoints     []string   `json:"endpoints,omitempty"`
+	LastSync      *time.Time `json:"lastSync,omitempty"`
+	LastSyncError string     `json:"lastSyncError,omitempty"`
+	// Breaker the state of the circuit breaker if enabled
+	Breaker string `json:"breaker,omitempty"`
+	// Leader the hex member ID of the leader, empty if no leader
+	Leader            string        `json:"leader,omitempty"`
+	DBSize            int64         `json:"dbSize,omitempty"`
+	DBSizeInUse       int64         `json:"dbSizeInUse,omitempty"`
+	QuotaBackendBytes int64         `json:"quotaBackendBytes,omitempty"`
+	QuotaUsage        float64       `json:"quotaUsage,omitempty"`
+	Alarms            []HealthAlarm `json:"alarms,omitempty"`
+	// Errors the reasons why the client is not live or ready
+	Errors []string `json:"errors,omitempty"`
+}
+
+type HealthAlarm struct {
+	// MemberID the hex member ID
+	MemberID string `json:"memberId"`
+	Type     string `json:"type"`
+}
+
+// HealthHandler is the http.Handler reporting the liveness and readiness of the client in JSON,
+// the path ends with "/livez" reports the liveness only, the others report the readiness,
+// it responds 200 if ok, otherwise 503
+type HealthHandler struct {
+	client Client
+	opts   HealthOptions
+}
+
+// NewHealthHandler returns the HealthHandler of client, nil means the Instance()
+func NewHealthHandler(client Client, opts HealthOptions) *HealthHandler {
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultHealthTimeout
+	}
+	if opts.QuotaBackendBytes <= 0 {
+		opts.QuotaBackendBytes = DefaultQuotaBackendBytes
+	}
+	return &HealthHandler{client: client, opts: opts}
+}
+
+func (h *HealthHandler) getClient() Client {
+	if h.client != nil {
+		return h.client
+	}
+	return pluginInst
+}
+
+func (h *HealthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var result *HealthResult
+	if strings.HasSuffix(r.URL.Path, "/livez") {
+		result = h.Live()
+	} else {
+		result = h.Ready(r.Context())
+	}
+	body, err := json.Marshal(result)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if result.Status != HealthStatusOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_, _ = w.Write(body)
+}
+
+// Live checks the liveness by the health reported by the plugin, without any request
+func (h *HealthHandler) Live() *HealthResult {
+	result := &HealthResult{Live: true}
+	client := h.getClient()
+	if client == nil {
+		result.fail(false, "client is not initialized")
+		return result
+	}
+	if reporter, ok := Unwrap(client).(HealthReporter); ok {
+		health := reporter.Health()
+		result.Endpoints = health.Endpoints
+		if !health.LastSync.IsZero() {
+			result.LastSync = &health.LastSync
+		}
+		if health.LastSyncError != nil {
+			result.LastSyncError = health.LastSyncError.Error()
+		}
+		if health.QuotaBackendBytes > 0 {
+			result.QuotaBackendBytes = health.QuotaBackendBytes
+		}
+		if !health.Live {
+			result.fail(false, "client is closed or the server stopped")
+		}
+	}
+	if cb, ok := client.(*CircuitBreaker); ok {
+		result.Breaker = cb.State().String()
+	}
+	if result.Live {
+		result.Status = HealthStatusOK
+	}
+	return result
+}
+
+// Ready checks the readiness, the client is ready if it is live, initialized, the circuit breaker
+// is not open, the last members sync succeeded, and the cluster has a leader and no alarms
+func (h *HealthHandler) Ready(ctx context.Context) *HealthResult {
+	result := h.Live()
+	if !result.Live {
+		return result
+	}
+	result.Ready = true
+	client := h.getClient()
+
+	select {
+	case <-client.Ready():
+	default:
+		result.fail(true, "client is not ready")
+		return result
+	}
+	if result.Breaker == BreakerOpen.String() {
+		result.fail(true, ErrCircuitOpen.Error())
+	}
+	if len(result.LastSyncError) > 0 {
+		result.fail(true, "sync members failed: "+result.LastSyncError)
+	}
+
+	// request the plugin directly, the checks are not counted by the breaker
+	ctx, cancel := context.WithTimeout(ctx, h.opts.Timeout)
+	defer cancel()
+	status, err := Unwrap(client).Status(ctx)
+	if err != nil {
+		result.fail(true, "get status failed: "+err.Error())
+		return result
+	}
+	if status.Leader == 0 {
+		result.fail(true, "no leader")
+	} else {
+		result.Leader = strconv.FormatUint(status.Leader, 16)
+	}
+	if len(status.AlarmsError) > 0 {
+		result.fail(true, "list alarms failed: "+status.AlarmsError)
+	}
+	for _, alarm := range status.Alarms {
+		memberID := strconv.FormatUint(alarm.MemberID, 16)
+		result.Alarms = append(result.Alarms, HealthAlarm{MemberID: memberID, Type: alarm.Type})
+		result.fail(true, fmt.Sprintf("alarm %s is activated on member %s", alarm.Type, memberID))
+	}
+
+	result.DBSize, result.DBSizeInUse = status.DBSize, status.DBSizeInUse
+	if result.QuotaBackendBytes <= 0 {
+		result.QuotaBackendBytes = h.opts.QuotaBackendBytes
+	}
+	result.QuotaUsage = float64(status.DBSize) / float64(result.QuotaBackendBytes)
+	if h.opts.MaxQuotaUsage > 0 && result.QuotaUsage > h.opts.MaxQuotaUsage {
+		result.fail(true, fmt.Sprintf("quota usage %.2f exceeds %.2f", result.QuotaUsage, h.opts.MaxQuotaUsage))
+	}
+	return result
+}
+
+// fail sets the result not ready, or not live if live is false
+func (r *HealthResult) fail(live bool, reason string) {
+	r.Status = HealthStatusFail
+	r.Ready = false
+	if !live {
+		r.Live = false
+	}
+	r.Errors = append(r.Errors, reason)
+}
@@ -0,0 +1,140 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package etcdadpt_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/little-cui/etcdadpt"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeHealthClient struct {
+	etcdadpt.Client
+
+	health etcdadpt.Health
+	status *etcdadpt.StatusResponse
+	err    error
+}
+
+func (c *fakeHealthClient) Ready() <-chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}
+
+func (c *fakeHealthClient) Health() etcdadpt.Health {
+	return c.health
+}
+
+func (c *fakeHealthClient) Status(_ context.Context) (*etcdadpt.StatusResponse, error) {
+	return c.status, c.err
+}
+
+func probe(t *testing.T, handler http.Handler, path string) (int, *etcdadpt.HealthResult) {
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	var result etcdadpt.HealthResult
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	return w.Code, &result
+}
+
+func TestHealthHandler(t *testing.T) {
+	t.Run("the instance is connected, should be live and ready", func(t *testing.T) {
+		handler := etcdadpt.NewHealthHandler(nil, etcdadpt.HealthOptions{})
+		code, result := probe(t, handler, "/healthz/livez")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, etcdadpt.HealthStatusOK, result.Status)
+		assert.True(t, result.Live)
+		if isEmbedded() {
+			// the embedded test instance exposes no client urls
+			assert.Empty(t, result.Endpoints)
+		} else {
+			assert.NotEmpty(t, result.Endpoints)
+		}
+
+		code, result = probe(t, handler, "/healthz/readyz")
+		assert.Equal(t, http.StatusOK, code)
+		assert.True(t, result.Ready)
+		assert.NotEmpty(t, result.Leader)
+		assert.True(t, result.DBSize > 0)
+		assert.True(t, result.QuotaBackendBytes > 0)
+		assert.True(t, result.QuotaUsage > 0)
+		assert.Empty(t, result.Errors)
+	})
+
+	t.Run("the server stopped, should not be live", func(t *testing.T) {
+		handler := etcdadpt.NewHealthHandler(&fakeHealthClient{}, etcdadpt.HealthOptions{})
+		code, result := probe(t, handler, "/livez")
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, etcdadpt.HealthStatusFail, result.Status)
+		assert.False(t, result.Live)
+		assert.NotEmpty(t, result.Errors)
+	})
+
+	t.Run("sync failed or alarms activated, should be live but not ready", func(t *testing.T) {
+		client := &fakeHealthClient{
+			health: etcdadpt.Health{Live: true, LastSyncError: errors.New("unavailable")},
+			status: &etcdadpt.StatusResponse{
+				Leader: 0xa,
+				DBSize: 90,
+				Alarms: []etcdadpt.Alarm{{MemberID: 0xb, Type: "NOSPACE"}},
+			},
+		}
+		handler := etcdadpt.NewHealthHandler(etcdadpt.NewChainClient(client), etcdadpt.HealthOptions{
+			QuotaBackendBytes: 100,
+			MaxQuotaUsage:     0.8,
+		})
+		code, _ := probe(t, handler, "/livez")
+		assert.Equal(t, http.StatusOK, code)
+
+		code, result := probe(t, handler, "/readyz")
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.True(t, result.Live)
+		assert.False(t, result.Ready)
+		assert.Equal(t, "unavailable", result.LastSyncError)
+		assert.Equal(t, "a", result.Leader)
+		assert.Equal(t, []etcdadpt.HealthAlarm{{MemberID: "b", Type: "NOSPACE"}}, result.Alarms)
+		assert.Equal(t, 0.9, result.QuotaUsage)
+		assert.Equal(t, 3, len(result.Errors))
+	})
+
+	t.Run("get status failed, should not be ready", func(t *testing.T) {
+		client := &fakeHealthClient{health: etcdadpt.Health{Live: true}, err: errors.New("no leader")}
+		code, result := probe(t, etcdadpt.NewHealthHandler(client, etcdadpt.HealthOptions{}), "/readyz")
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, []string{"get status failed: no leader"}, result.Errors)
+	})
+
+	t.Run("list alarms failed, should not be ready", func(t *testing.T) {
+		client := &fakeHealthClient{
+			health: etcdadpt.Health{Live: true},
+			status: &etcdadpt.StatusResponse{Leader: 0xa, AlarmsError: "timeout"},
+		}
+		code, result := probe(t, etcdadpt.NewHealthHandler(client, etcdadpt.HealthOptions{}), "/readyz")
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, "a", result.Leader)
+		assert.Equal(t, []string{"list alarms failed: timeout"}, result.Errors)
+	})
+}
@@ -0,0 +1,488 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package etcdadpt
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/go-chassis/openlog"
+	"github.com/little-cui/etcdadpt/middleware/log"
+	"github.com/little-cui/etcdadpt/middleware/metrics"
+)
+
+const (
+	OperationTxn           = "TXN"
+	OperationLeaseGrant    = "LEASE_GRANT"
+	OperationLeaseRenew    = "LEASE_RENEW"
+	OperationLeaseRevoke   = "LEASE_REVOKE"
+	OperationLeaseTTL      = "LEASE_TTL"
+	OperationWatch         = "WATCH"
+	OperationCompact       = "COMPACT"
+	OperationDefragment    = "DEFRAGMENT"
+	OperationSnapshot      = "SNAPSHOT"
+	OperationStatus        = "STATUS"
+	OperationClusterList   = "CLUSTER_LIST"
+	OperationMemberList    = "MEMBER_LIST"
+	OperationMemberAdd     = "MEMBER_ADD"
+	OperationMemberRemove  = "MEMBER_REMOVE"
+	OperationMemberPromote = "MEMBER_PROMOTE"
+	OperationMemberUpdate  = "MEMBER_UPDATE"
+	OperationLock          = "LOCK"
+)
+
+var (
+	defaultInterceptors = []Interceptor{MetricsInterceptor, LoggingInterceptor}
+	interceptorsLock    sync.RWMutex
+)
+
+// Invocation is a call of the Client methods
+type Invocation struct {
+	// MetricsInstance the "instance" label of the metrics, Config.MetricsInstance
+	MetricsInstance string
+	// Operation the kind of the operation, like GET, PUT, DELETE, TXN and LEASE_GRANT
+	Operation string
+	// Options the OpOptions of Do and Watch, or the success OpOptions of txn,
+	// the interceptors can replace the ones of Do and txn before calling next
+	Options []OpOptions
+	// Cmps and FailOptions the compares and the fail OpOptions of txn
+	Cmps        []CmpOptions
+	FailOptions []OpOptions
+	// LeaseID, TTL and Revision the arguments of the lease and compact operations
+	LeaseID  int64
+	TTL      int64
+	Revision int64
+	// MemberID and PeerURLs the arguments of the member operations
+	MemberID uint64
+	PeerURLs []string
+	// Endpoint the endpoint of backend if the Client provides, it is updated to the one
+	// actually serving the request after the call, see SetRequestEndpoint
+	Endpoint string
+}
+
+func (inv *Invocation) String() string {
+	var buf bytes.Buffer
+	buf.WriteString(inv.Operation)
+	switch {
+	case len(inv.Options) == 1 && len(inv.Cmps) == 0 && len(inv.FailOptions) == 0:
+		buf.WriteString(" ")
+		buf.WriteString(inv.Options[0].String())
+	case len(inv.Options) > 0 || len(inv.FailOptions) > 0:
+		buf.WriteString(fmt.Sprintf(" {if: %s, then: %d, else: %d}", inv.Cmps, len(inv.Options), len(inv.FailOptions)))
+	}
+	if inv.LeaseID != 0 {
+		buf.WriteString(fmt.Sprintf(" lease=%d", inv.LeaseID))
+	}
+	if inv.TTL != 0 {
+		buf.WriteString(fmt.Sprintf(" ttl=%d", inv.TTL))
+	}
+	if inv.Revision != 0 {
+		buf.WriteString(fmt.Sprintf(" reserve=%d", inv.Revision))
+	}
+	if inv.MemberID != 0 {
+		buf.WriteString(fmt.Sprintf(" member=%x", inv.MemberID))
+	}
+	if len(inv.PeerURLs) > 0 {
+		buf.WriteString(fmt.Sprintf(" peers=%v", inv.PeerURLs))
+	}
+	return buf.String()
+}
+
+type endpointRecorderKey struct{}
+
+type endpointRecorder struct {
+	mux      sync.Mutex
+	endpoint string
+}
+
+func (r *endpointRecorder) set(endpoint string) {
+	r.mux.Lock()
+	r.endpoint = endpoint
+	r.mux.Unlock()
+}
+
+func (r *endpointRecorder) get() string {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	return r.endpoint
+}
+
+// SetRequestEndpoint records the endpoint actually serving the request of ctx, the plugins
+// call it after sending the request, then Invocation.Endpoint is updated when the call returns
+func SetRequestEndpoint(ctx context.Context, endpoint string) {
+	if r, ok := ctx.Value(endpointRecorderKey{}).(*endpointRecorder); ok {
+		r.set(endpoint)
+	}
+}
+
+// Handler calls the Client method of the invocation
+type Handler func(ctx context.Context, inv *Invocation) (result interface{}, err error)
+
+// Interceptor intercepts the invocation, calls next to continue, the result
+// is the return value of the Client method, like *Response, lease ID or nil
+type Interceptor func(ctx context.Context, inv *Invocation, next Handler) (result interface{}, err error)
+
+// RegisterInterceptor appends the interceptor to the default ones applied to the clients created later
+func RegisterInterceptor(interceptor Interceptor) {
+	interceptorsLock.Lock()
+	defer interceptorsLock.Unlock()
+	defaultInterceptors = append(defaultInterceptors, interceptor)
+}
+
+func getDefaultInterceptors() []Interceptor {
+	interceptorsLock.RLock()
+	defer interceptorsLock.RUnlock()
+	return append([]Interceptor(nil), defaultInterceptors...)
+}
+
+// MetricsInterceptor reports the latency, result and payload size of the operations, except WATCH
+func MetricsInterceptor(ctx context.Context, inv *Invocation, next Handler) (interface{}, error) {
+	if inv.Operation == OperationWatch {
+		return next(ctx, inv)
+	}
+	reporter := metrics.Reporter{Instance: inv.MetricsInstance}
+	var key string
+	if len(inv.Options) > 0 {
+		key = string(inv.Options[0].Key)
+	}
+	start := time.Now()
+	result, err := next(ctx, inv)
+	reporter.ReportBackendKeyOperationCompleted(inv.Operation, key, err, start)
+
+	var write, read bool
+	var valueSize int
+	for _, op := range inv.Options {
+		switch op.Action {
+		case ActionPut:
+			write = true
+			valueSize += len(op.Value)
+		case ActionGet:
+			read = true
+		}
+	}
+	if write {
+		reporter.ReportBackendValueSize(inv.Operation, key, valueSize)
+	}
+	if resp, ok := result.(*Response); ok && resp != nil && read {
+		var respSize int
+		for _, kv := range resp.Kvs {
+			respSize += len(kv.Key) + len(kv.Value)
+		}
+		reporter.ReportBackendResponseSize(inv.Operation, key, respSize)
+	}
+	return result, err
+}
+
+// LoggingInterceptor logs the operations slower than log.SlowThreshold with the structured fields,
+// except the long-running ones like WATCH, COMPACT, DEFRAGMENT, SNAPSHOT and LOCK
+func LoggingInterceptor(ctx context.Context, inv *Invocation, next Handler) (interface{}, error) {
+	switch inv.Operation {
+	case OperationWatch, OperationCompact, OperationDefragment, OperationSnapshot, OperationLock:
+		return next(ctx, inv)
+	}
+	start := time.Now()
+	result, err := next(ctx, inv)
+	cost := time.Since(start)
+	if cost < log.SlowThreshold() {
+		return result, err
+	}
+	tags := openlog.Tags{
+		log.FieldOperation: inv.Operation,
+		log.FieldCost:      cost.String(),
+		log.FieldEndpoint:  inv.Endpoint,
+	}
+	if len(inv.Options) > 0 {
+		tags[log.FieldKey] = string(inv.Options[0].Key)
+	}
+	if resp, ok := result.(*Response); ok && resp != nil {
+		tags[log.FieldRevision] = resp.Revision
+	}
+	message := fmt.Sprintf("[%s]slow operation %s", cost, inv)
+	if err != nil {
+		message += fmt.Sprintf(", error: %s", err)
+	}
+	log.GetCategoryLogger(log.CategoryOperation).Warn(message, openlog.WithTags(tags), openlog.WithErr(err))
+	return result, err
+}
+
+// ChainClient wraps the Client with the interceptors, the first one is the outermost
+type ChainClient struct {
+	Client
+
+	interceptors    []Interceptor
+	metricsInstance string
+}
+
+func NewChainClient(client Client, interceptors ...Interceptor) *ChainClient {
+	return &ChainClient{Client: client, interceptors: interceptors}
+}
+
+// Unwrap returns the wrapped client
+func (c *ChainClient) Unwrap() Client {
+	return c.Client
+}
+
+func (c *ChainClient) invoke(ctx context.Context, inv *Invocation, h Handler) (interface{}, error) {
+	inv.MetricsInstance = c.metricsInstance
+	if ep, ok := c.Client.(interface{ Endpoint() string }); ok {
+		inv.Endpoint = ep.Endpoint()
+	}
+	call := h
+	h = func(ctx context.Context, inv *Invocation) (interface{}, error) {
+		recorder := &endpointRecorder{}
+		result, err := call(context.WithValue(ctx, endpointRecorderKey{}, recorder), inv)
+		if endpoint := recorder.get(); len(endpoint) > 0 {
+			inv.Endpoint = endpoint
+		}
+		return result, err
+	}
+	for i := len(c.interceptors) - 1; i >= 0; i-- {
+		interceptor, next := c.interceptors[i], h
+		h = func(ctx context.Context, inv *Invocation) (interface{}, error) {
+			return interceptor(ctx, inv, next)
+		}
+	}
+	return h(ctx, inv)
+}
+
+// withOp replaces the OpOptions with op
+func withOp(op OpOptions) OpOption {
+	return func(o *OpOptions) { *o = op }
+}
+
+func (c *ChainClient) Do(ctx context.Context, opts ...OpOption) (*Response, error) {
+	op := OptionsToOp(opts...)
+	inv := &Invocation{Operation: op.Action.String(), Options: []OpOptions{op}}
+	result, err := c.invoke(ctx, inv, func(ctx context.Context, inv *Invocation) (interface{}, error) {
+		return c.Client.Do(ctx, withOp(inv.Options[0]))
+	})
+	resp, _ := result.(*Response)
+	return resp, err
+}
+
+func (c *ChainClient) Txn(ctx context.Context, ops []OpOptions) (*Response, error) {
+	inv := &Invocation{Operation: OperationTxn, Options: ops}
+	result, err := c.invoke(ctx, inv, func(ctx context.Context, inv *Invocation) (interface{}, error) {
+		return c.Client.Txn(ctx, inv.Options)
+	})
+	resp, _ := result.(*Response)
+	return resp, err
+}
+
+func (c *ChainClient) TxnWithCmp(ctx context.Context, success []OpOptions, cmp []CmpOptions, fail []OpOptions) (*Response, error) {
+	inv := &Invocation{Operation: OperationTxn, Options: success, Cmps: cmp, FailOptions: fail}
+	result, err := c.invoke(ctx, inv, func(ctx context.Context, inv *Invocation) (interface{}, error) {
+		return c.Client.TxnWithCmp(ctx, inv.Options, inv.Cmps, inv.FailOptions)
+	})
+	resp, _ := result.(*Response)
+	return resp, err
+}
+
+func (c *ChainClient) LeaseGrant(ctx context.Context, TTL int64) (int64, error) {
+	inv := &Invocation{Operation: OperationLeaseGrant, TTL: TTL}
+	result, err := c.invoke(ctx, inv, func(ctx context.Context, _ *Invocation) (interface{}, error) {
+		return c.Client.LeaseGrant(ctx, TTL)
+	})
+	leaseID, _ := result.(int64)
+	return leaseID, err
+}
+
+func (c *ChainClient) LeaseRenew(ctx context.Context, leaseID int64) (int64, error) {
+	inv := &Invocation{Operation: OperationLeaseRenew, LeaseID: leaseID}
+	result, err := c.invoke(ctx, inv, func(ctx context.Context, _ *Invocation) (interface{}, error) {
+		return c.Client.LeaseRenew(ctx, leaseID)
+	})
+	TTL, _ := result.(int64)
+	return TTL, err
+}
+
+func (c *ChainClient) LeaseRevoke(ctx context.Context, leaseID int64) error {
+	inv := &Invocation{Operation: OperationLeaseRevoke, LeaseID: leaseID}
+	_, err := c.invoke(ctx, inv, func(ctx context.Context, _ *Invocation) (interface{}, error) {
+		return nil, c.Client.LeaseRevoke(ctx, leaseID)
+	})
+	return err
+}
+
+func (c *ChainClient) LeaseTimeToLive(ctx context.Context, leaseID int64) (int64, error) {
+	inv := &Invocation{Operation: OperationLeaseTTL, LeaseID: leaseID}
+	result, err := c.invoke(ctx, inv, func(ctx context.Context, _ *Invocation) (interface{}, error) {
+		return leaseTimeToLive(ctx, c.Client, leaseID)
+	})
+	TTL, _ := result.(int64)
+	return TTL, err
+}
+
+func (c *ChainClient) Watch(ctx context.Context, opts ...OpOption) error {
+	inv := &Invocation{Operation: OperationWatch, Options: []OpOptions{OptionsToOp(opts...)}}
+	_, err := c.invoke(ctx, inv, func(ctx context.Context, _ *Invocation) (interface{}, error) {
+		return nil, c.Client.Watch(ctx, opts...)
+	})
+	return err
+}
+
+func (c *ChainClient) Compact(ctx context.Context, reserve int64) error {
+	inv := &Invocation{Operation: OperationCompact, Revision: reserve}
+	_, err := c.invoke(ctx, inv, func(ctx context.Context, _ *Invocation) (interface{}, error) {
+		return nil, c.Client.Compact(ctx, reserve)
+	})
+	return err
+}
+
+func (c *ChainClient) Defragment(ctx context.Context) error {
+	inv := &Invocation{Operation: OperationDefragment}
+	_, err := c.invoke(ctx, inv, func(ctx context.Context, _ *Invocation) (interface{}, error) {
+		return nil, defragment(ctx, c.Client)
+	})
+	return err
+}
+
+func (c *ChainClient) Snapshot(ctx context.Context, w io.Writer) error {
+	inv := &Invocation{Operation: OperationSnapshot}
+	_, err := c.invoke(ctx, inv, func(ctx context.Context, _ *Invocation) (interface{}, error) {
+		return nil, snapshot(ctx, c.Client, w)
+	})
+	return err
+}
+
+func (c *ChainClient) ListCluster(ctx context.Context) (Clusters, error) {
+	inv := &Invocation{Operation: OperationClusterList}
+	result, err := c.invoke(ctx, inv, func(ctx context.Context, _ *Invocation) (interface{}, error) {
+		return c.Client.ListCluster(ctx)
+	})
+	clusters, _ := result.(Clusters)
+	return clusters, err
+}
+
+func (c *ChainClient) ListMember(ctx context.Context) ([]*Member, error) {
+	inv := &Invocation{Operation: OperationMemberList}
+	result, err := c.invoke(ctx, inv, func(ctx context.Context, _ *Invocation) (interface{}, error) {
+		manager, err := memberManager(c.Client)
+		if err != nil {
+			return nil, err
+		}
+		return manager.ListMember(ctx)
+	})
+	members, _ := result.([]*Member)
+	return members, err
+}
+
+func (c *ChainClient) AddMember(ctx context.Context, peerURLs []string, isLearner bool) (*Member, error) {
+	inv := &Invocation{Operation: OperationMemberAdd, PeerURLs: peerURLs}
+	result, err := c.invoke(ctx, inv, func(ctx context.Context, _ *Invocation) (interface{}, error) {
+		manager, err := memberManager(c.Client)
+		if err != nil {
+			return nil, err
+		}
+		return manager.AddMember(ctx, peerURLs, isLearner)
+	})
+	member, _ := result.(*Member)
+	return member, err
+}
+
+func (c *ChainClient) RemoveMember(ctx context.Context, id uint64) error {
+	inv := &Invocation{Operation: OperationMemberRemove, MemberID: id}
+	_, err := c.invoke(ctx, inv, func(ctx context.Context, _ *Invocation) (interface{}, error) {
+		manager, err := memberManager(c.Client)
+		if err != nil {
+			return nil, err
+		}
+		return nil, manager.RemoveMember(ctx, id)
+	})
+	return err
+}
+
+func (c *ChainClient) PromoteMember(ctx context.Context, id uint64) error {
+	inv := &Invocation{Operation: OperationMemberPromote, MemberID: id}
+	_, err := c.invoke(ctx, inv, func(ctx context.Context, _ *Invocation) (interface{}, error) {
+		manager, err := memberManager(c.Client)
+		if err != nil {
+			return nil, err
+		}
+		return nil, manager.PromoteMember(ctx, id)
+	})
+	return err
+}
+
+func (c *ChainClient) UpdateMember(ctx context.Context, id uint64, peerURLs []string) error {
+	inv := &Invocation{Operation: OperationMemberUpdate, MemberID: id, PeerURLs: peerURLs}
+	_, err := c.invoke(ctx, inv, func(ctx context.Context, _ *Invocation) (interface{}, error) {
+		manager, err := memberManager(c.Client)
+		if err != nil {
+			return nil, err
+		}
+		return nil, manager.UpdateMember(ctx, id, peerURLs)
+	})
+	return err
+}
+
+func (c *ChainClient) Status(ctx context.Context) (*StatusResponse, error) {
+	inv := &Invocation{Operation: OperationStatus}
+	result, err := c.invoke(ctx, inv, func(ctx context.Context, _ *Invocation) (interface{}, error) {
+		return c.Client.Status(ctx)
+	})
+	status, _ := result.(*StatusResponse)
+	return status, err
+}
+
+// Unwrap returns the innermost client of the wrappers like ChainClient and CircuitBreaker
+func Unwrap(client Client) Client {
+	for {
+		w, ok := client.(interface{ Unwrap() Client })
+		if !ok {
+			return client
+		}
+		client = w.Unwrap()
+	}
+}
+
+// invokeClient calls h with the interceptors of the ChainClient wrapped by client, it is
+// used by the operations composed of the multiple client calls, like the DLock acquisition
+func invokeClient(ctx context.Context, client Client, inv *Invocation, h Handler) (interface{}, error) {
+	if c := chainOf(client); c != nil {
+		return c.invoke(ctx, inv, h)
+	}
+	return h(ctx, inv)
+}
+
+// chainOf returns the ChainClient wrapped by client, nil if not found
+func chainOf(client Client) *ChainClient {
+	for client != nil {
+		if c, ok := client.(*ChainClient); ok {
+			return c
+		}
+		w, ok := client.(interface{ Unwrap() Client })
+		if !ok {
+			break
+		}
+		client = w.Unwrap()
+	}
+	return nil
+}
+
+// reporterOf returns the metrics reporter with the instance label of the ChainClient wrapped by client
+func reporterOf(client Client) metrics.Reporter {
+	if c := chainOf(client); c != nil {
+		return metrics.Reporter{Instance: c.metricsInstance}
+	}
+	return metrics.Reporter{}
+}
@@ -0,0 +1,205 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package etcdadpt_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chassis/openlog"
+	"github.com/little-cui/etcdadpt"
+	"github.com/little-cui/etcdadpt/middleware/log"
+	"github.com/little-cui/etcdadpt/middleware/tracing"
+	"github.com/stretchr/testify/assert"
+)
+
+type spanKey struct{}
+
+type fakeTracer struct {
+	mux      sync.Mutex
+	names    []string
+	parents  map[string]string
+	requests []*tracing.Request
+	results  []*tracing.Result
+}
+
+func (t *fakeTracer) Start(ctx context.Context, operationName string, request *tracing.Request) (context.Context, tracing.EndFunc) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if t.parents == nil {
+		t.parents = make(map[string]string)
+	}
+	parent, _ := ctx.Value(spanKey{}).(string)
+	t.parents[operationName] = parent
+	t.names = append(t.names, operationName)
+	t.requests = append(t.requests, request)
+	return context.WithValue(ctx, spanKey{}, operationName), func(result *tracing.Result) {
+		t.mux.Lock()
+		t.results = append(t.results, result)
+		t.mux.Unlock()
+	}
+}
+
+type tagsLogger struct {
+	messages []string
+	tags     []openlog.Tags
+}
+
+func (l *tagsLogger) Debug(message string, opts ...openlog.Option) {}
+func (l *tagsLogger) Info(message string, opts ...openlog.Option)  {}
+func (l *tagsLogger) Warn(message string, opts ...openlog.Option) {
+	l.messages = append(l.messages, message)
+	l.tags = append(l.tags, openlog.ToOptions(opts...).Tags)
+}
+func (l *tagsLogger) Error(message string, opts ...openlog.Option) {}
+func (l *tagsLogger) Fatal(message string, opts ...openlog.Option) {}
+
+func TestChainClient(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("interceptors should be called in order", func(t *testing.T) {
+		var (
+			calls []string
+			invs  []*etcdadpt.Invocation
+		)
+		record := func(name string) etcdadpt.Interceptor {
+			return func(ctx context.Context, inv *etcdadpt.Invocation, next etcdadpt.Handler) (interface{}, error) {
+				calls = append(calls, name+":before")
+				invs = append(invs, inv)
+				result, err := next(ctx, inv)
+				calls = append(calls, name+":after")
+				return result, err
+			}
+		}
+		inner := &fakeClient{}
+		c := etcdadpt.NewChainClient(inner, record("a"), record("b"))
+
+		resp, err := c.Do(ctx, etcdadpt.GET, etcdadpt.WithStrKey("/test_chain"))
+		assert.NoError(t, err)
+		assert.True(t, resp.Succeeded)
+		assert.Equal(t, []string{"a:before", "b:before", "b:after", "a:after"}, calls)
+		assert.Equal(t, "GET", invs[0].Operation)
+		assert.Equal(t, "/test_chain", string(invs[0].Options[0].Key))
+		assert.Equal(t, 1, inner.Calls())
+		assert.Equal(t, inner, etcdadpt.Unwrap(c))
+	})
+
+	t.Run("interceptor returns error, should not call the client", func(t *testing.T) {
+		errDenied := errors.New("denied")
+		inner := &fakeClient{}
+		c := etcdadpt.NewChainClient(inner,
+			func(ctx context.Context, inv *etcdadpt.Invocation, next etcdadpt.Handler) (interface{}, error) {
+				if inv.Operation == etcdadpt.ActionPut.String() {
+					return nil, errDenied
+				}
+				return next(ctx, inv)
+			})
+
+		resp, err := c.Do(ctx, etcdadpt.PUT, etcdadpt.WithStrKey("/test_chain"))
+		assert.Equal(t, errDenied, err)
+		assert.Nil(t, resp)
+		assert.Equal(t, 0, inner.Calls())
+	})
+
+	t.Run("interceptor replaces the options, should call the client with the new ones", func(t *testing.T) {
+		inner := &fakeClient{}
+		c := etcdadpt.NewChainClient(inner,
+			func(ctx context.Context, inv *etcdadpt.Invocation, next etcdadpt.Handler) (interface{}, error) {
+				op := inv.Options[0]
+				op.PrevKV = true
+				inv.Options = []etcdadpt.OpOptions{op}
+				return next(ctx, inv)
+			})
+
+		_, err := c.Do(ctx, etcdadpt.PUT, etcdadpt.WithStrKey("/test_chain"), etcdadpt.WithStrValue("a"))
+		assert.NoError(t, err)
+		assert.True(t, inner.last.PrevKV)
+		assert.Equal(t, "/test_chain", string(inner.last.Key))
+		assert.Equal(t, "a", string(inner.last.Value))
+	})
+
+	t.Run("trace the operations, should begin and end the spans", func(t *testing.T) {
+		tracer := &fakeTracer{}
+		tracing.Register(tracer)
+		defer tracing.Register(nil)
+
+		inner := &fakeClient{}
+		c := etcdadpt.NewChainClient(inner, tracing.Interceptor)
+		_, err := c.Do(ctx, etcdadpt.GET, etcdadpt.WithStrKey("/test_chain"))
+		assert.NoError(t, err)
+
+		inner.set(errors.New("unavailable"), 0)
+		_, err = c.Do(ctx, etcdadpt.DEL, etcdadpt.WithStrKey("/test_chain"))
+		assert.Error(t, err)
+
+		assert.Equal(t, []string{"etcd:do", "etcd:do"}, tracer.names)
+		assert.Equal(t, "/test_chain", string(tracer.requests[0].Options.Key))
+		assert.Equal(t, etcdadpt.ActionDelete, tracer.requests[1].Options.Action)
+		assert.True(t, tracer.results[0].Succeeded)
+		assert.Equal(t, etcdadpt.ActionGet, tracer.results[0].Action)
+		assert.False(t, tracer.results[1].Succeeded)
+		assert.Equal(t, "unavailable", tracer.results[1].Err.Error())
+	})
+
+	t.Run("slow operation, should be logged with the fields", func(t *testing.T) {
+		logger := &tagsLogger{}
+		oldLogger := log.GetLogger()
+		log.SetLogger(logger)
+		log.SetOptions(log.Options{SlowThreshold: time.Nanosecond})
+		defer func() {
+			log.SetLogger(oldLogger)
+			log.SetOptions(log.Options{})
+		}()
+
+		c := etcdadpt.NewChainClient(&fakeClient{}, etcdadpt.LoggingInterceptor)
+		_, err := c.Do(ctx, etcdadpt.GET, etcdadpt.WithStrKey("/test_chain"))
+		assert.NoError(t, err)
+
+		assert.Len(t, logger.messages, 1)
+		assert.Contains(t, logger.messages[0], "slow operation GET")
+		assert.Contains(t, logger.messages[0], "key=/test_chain")
+		assert.Equal(t, "GET", logger.tags[0][log.FieldOperation])
+		assert.Equal(t, "/test_chain", logger.tags[0][log.FieldKey])
+		assert.NotEmpty(t, logger.tags[0][log.FieldCost])
+	})
+
+	t.Run("the nested operations of lock should be traced as the children", func(t *testing.T) {
+		tracer := &fakeTracer{}
+		tracing.Register(tracer)
+		defer tracing.Register(nil)
+
+		lock, err := etcdadpt.LockContext(context.WithValue(ctx, spanKey{}, "caller"), "test_chain_lock", 5)
+		assert.NoError(t, err)
+		tracing.Register(nil)
+		assert.NoError(t, lock.Unlock())
+
+		assert.Equal(t, "etcd:lock", tracer.names[0])
+		assert.Equal(t, "caller", tracer.parents["etcd:lock"])
+		assert.Equal(t, "etcd:lock", tracer.parents["etcd:grant"])
+		assert.Equal(t, "etcd:lock", tracer.parents["etcd:txn"])
+		assert.True(t, tracer.results[len(tracer.results)-1].Succeeded)
+	})
+
+	t.Run("the instance should be intercepted", func(t *testing.T) {
+		_, ok := etcdadpt.Instance().(*etcdadpt.ChainClient)
+		assert.True(t, ok)
+	})
+}
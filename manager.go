@@ -83,7 +83,13 @@ func NewInstance(cfg Config) (Client, error) {
 	case err := <-inst.Err():
 		return nil, err
 	case <-inst.Ready():
-		return inst, nil
+		interceptors := append(getDefaultInterceptors(), cfg.Interceptors...)
+		chain := NewChainClient(inst, interceptors...)
+		chain.metricsInstance = cfg.MetricsInstance
+		if cfg.CircuitBreaker != nil {
+			return NewCircuitBreaker(chain, *cfg.CircuitBreaker, cfg.ErrorFunc, cfg.ConnectedFunc), nil
+		}
+		return chain, nil
 	}
 }
 
@@ -94,3 +100,9 @@ func Instance() Client {
 	}
 	return pluginInst
 }
+
+// SetInstance replaces the instance of Etcd client, it is used when the caller
+// creates the client by NewInstance and handles the init error by itself
+func SetInstance(inst Client) {
+	pluginInst = inst
+}
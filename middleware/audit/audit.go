@@ -0,0 +1,244 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package audit
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/go-chassis/openlog"
+	"github.com/little-cui/etcdadpt"
+	"github.com/little-cui/etcdadpt/middleware/log"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+)
+
+// Record the audit record of a key changed by PUT or DELETE
+type Record struct {
+	Time time.Time `json:"time"`
+	// Identity the caller identity in the context, see WithIdentity
+	Identity string `json:"identity,omitempty"`
+	// Operation PUT, DELETE or TXN, Action PUT or DELETE
+	Operation string `json:"operation"`
+	Action    string `json:"action"`
+	Key       string `json:"key"`
+	// PrevValueHash and ValueHash the sha256 hex of the previous and new values,
+	// PrevValueHash is empty if the key does not exist before, ValueHash is empty if deleted
+	PrevValueHash string `json:"prevValueHash,omitempty"`
+	ValueHash     string `json:"valueHash,omitempty"`
+	// Revision the revision of the change, 0 if failed
+	Revision  int64  `json:"revision,omitempty"`
+	Succeeded bool   `json:"succeeded"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Sink writes the audit records
+type Sink interface {
+	Write(ctx context.Context, records []*Record) error
+}
+
+var (
+	globalSink Sink
+	sinkLock   sync.RWMutex
+)
+
+// Register registers the sink to audit the PUT, DELETE and TXN operations
+// of all the clients, nil means no audit
+func Register(sink Sink) {
+	sinkLock.Lock()
+	defer sinkLock.Unlock()
+	globalSink = sink
+}
+
+func getSink() Sink {
+	sinkLock.RLock()
+	defer sinkLock.RUnlock()
+	return globalSink
+}
+
+func init() {
+	etcdadpt.RegisterInterceptor(Interceptor)
+}
+
+type identityKey struct{}
+
+// WithIdentity returns the context carrying the caller identity recorded by audit
+func WithIdentity(ctx context.Context, identity string) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// IdentityFromContext returns the caller identity set by WithIdentity
+func IdentityFromContext(ctx context.Context) string {
+	identity, _ := ctx.Value(identityKey{}).(string)
+	return identity
+}
+
+// Interceptor audits the PUT and DELETE of Do and TXN by the registered Sink,
+// the reads are never audited
+func Interceptor(ctx context.Context, inv *etcdadpt.Invocation, next etcdadpt.Handler) (interface{}, error) {
+	sink := getSink()
+	if sink == nil || !mutating(inv) {
+		return next(ctx, inv)
+	}
+
+	// request the previous key-values to hash the previous values
+	requested := withPrevKV(inv)
+	result, err := next(ctx, inv)
+	resp, _ := result.(*etcdadpt.Response)
+
+	records := toRecords(ctx, inv, resp, err)
+	if resp != nil && !requested {
+		resp.PrevKvs = nil
+	}
+	if len(records) > 0 {
+		if werr := sink.Write(ctx, records); werr != nil {
+			log.GetLogger().Error("write audit records failed", openlog.WithTags(openlog.Tags{
+				log.FieldOperation: inv.Operation,
+				log.FieldKey:       records[0].Key,
+			}), openlog.WithErr(werr))
+		}
+	}
+	return result, err
+}
+
+func mutating(inv *etcdadpt.Invocation) bool {
+	switch inv.Operation {
+	case etcdadpt.ActionPut.String(), etcdadpt.ActionDelete.String(), etcdadpt.OperationTxn:
+	default:
+		return false
+	}
+	for _, ops := range [][]etcdadpt.OpOptions{inv.Options, inv.FailOptions} {
+		for _, op := range ops {
+			if op.Action != etcdadpt.ActionGet {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// withPrevKV replaces the OpOptions of inv with the copies requesting the previous
+// key-values, returns true if all the mutating ones have requested them
+func withPrevKV(inv *etcdadpt.Invocation) bool {
+	requested := true
+	copyOps := func(ops []etcdadpt.OpOptions) []etcdadpt.OpOptions {
+		if len(ops) == 0 {
+			return ops
+		}
+		copied := make([]etcdadpt.OpOptions, len(ops))
+		for i, op := range ops {
+			if op.Action != etcdadpt.ActionGet {
+				requested = requested && op.PrevKV
+				op.PrevKV = true
+			}
+			copied[i] = op
+		}
+		return copied
+	}
+	inv.Options = copyOps(inv.Options)
+	inv.FailOptions = copyOps(inv.FailOptions)
+	return requested
+}
+
+func toRecords(ctx context.Context, inv *etcdadpt.Invocation, resp *etcdadpt.Response, err error) []*Record {
+	ops := inv.Options
+	if len(inv.Cmps) > 0 && resp != nil && !resp.Succeeded {
+		// the compares failed, the fail OpOptions are executed
+		ops = inv.FailOptions
+	}
+	var prevKvs []*mvccpb.KeyValue
+	if resp != nil {
+		prevKvs = resp.PrevKvs
+	}
+
+	now := time.Now()
+	identity := IdentityFromContext(ctx)
+	var records []*Record
+	newRecord := func(op etcdadpt.OpOptions, key []byte) *Record {
+		r := &Record{
+			Time:      now,
+			Identity:  identity,
+			Operation: inv.Operation,
+			Action:    op.Action.String(),
+			Key:       string(key),
+			Succeeded: err == nil && resp != nil,
+		}
+		if err != nil {
+			r.Error = err.Error()
+		}
+		if r.Succeeded {
+			r.Revision = resp.Revision
+		}
+		if op.Action == etcdadpt.ActionPut {
+			r.ValueHash = hash(op.Value)
+		}
+		records = append(records, r)
+		return r
+	}
+	for _, op := range ops {
+		switch op.Action {
+		case etcdadpt.ActionPut:
+			r := newRecord(op, op.Key)
+			if kv := findPrevKv(prevKvs, op.Key); kv != nil {
+				r.PrevValueHash = hash(kv.Value)
+			}
+		case etcdadpt.ActionDelete:
+			var deleted bool
+			for _, kv := range prevKvs {
+				if inRange(op, kv.Key) {
+					newRecord(op, kv.Key).PrevValueHash = hash(kv.Value)
+					deleted = true
+				}
+			}
+			if !deleted {
+				// failed or nothing deleted
+				newRecord(op, op.Key)
+			}
+		}
+	}
+	return records
+}
+
+func findPrevKv(prevKvs []*mvccpb.KeyValue, key []byte) *mvccpb.KeyValue {
+	for _, kv := range prevKvs {
+		if bytes.Equal(kv.Key, key) {
+			return kv
+		}
+	}
+	return nil
+}
+
+// inRange returns true if key is in the range of the DELETE op
+func inRange(op etcdadpt.OpOptions, key []byte) bool {
+	switch {
+	case op.Prefix:
+		return bytes.HasPrefix(key, op.Key)
+	case len(op.EndKey) > 0:
+		return bytes.Compare(key, op.Key) >= 0 && bytes.Compare(key, op.EndKey) < 0
+	default:
+		return bytes.Equal(key, op.Key)
+	}
+}
+
+func hash(value []byte) string {
+	sum := sha256.Sum256(value)
+	return hex.EncodeToString(sum[:])
+}
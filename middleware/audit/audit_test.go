@@ -0,0 +1,192 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package audit_test
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	_ "github.com/little-cui/etcdadpt/test"
+
+	"github.com/little-cui/etcdadpt"
+	"github.com/little-cui/etcdadpt/middleware/audit"
+	"github.com/stretchr/testify/assert"
+)
+
+type memorySink struct {
+	mux     sync.Mutex
+	records []*audit.Record
+}
+
+func (s *memorySink) Write(_ context.Context, records []*audit.Record) error {
+	s.mux.Lock()
+	s.records = append(s.records, records...)
+	s.mux.Unlock()
+	return nil
+}
+
+func (s *memorySink) reset() []*audit.Record {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	records := s.records
+	s.records = nil
+	return records
+}
+
+func hashOf(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+// newInstance wraps the test instance with the audit interceptor only, it is independent of
+// whether the interceptor registered in init is in the chain of the test instance
+func newInstance() etcdadpt.Client {
+	return etcdadpt.NewChainClient(etcdadpt.Unwrap(etcdadpt.Instance()), audit.Interceptor)
+}
+
+func TestInterceptor(t *testing.T) {
+	sink := &memorySink{}
+	audit.Register(sink)
+	defer audit.Register(nil)
+
+	inst := newInstance()
+	ctx := audit.WithIdentity(context.Background(), "alice")
+	_, _ = inst.Do(ctx, etcdadpt.DEL, etcdadpt.WithStrKey("/test_audit/"), etcdadpt.WithPrefix())
+	sink.reset()
+
+	t.Run("put should be recorded with the value hashes", func(t *testing.T) {
+		resp, err := inst.Do(ctx, etcdadpt.PUT, etcdadpt.WithStrKey("/test_audit/a"), etcdadpt.WithStrValue("a1"))
+		assert.NoError(t, err)
+		assert.Nil(t, resp.PrevKvs)
+		resp, err = inst.Do(ctx, etcdadpt.PUT, etcdadpt.WithStrKey("/test_audit/a"), etcdadpt.WithStrValue("a2"))
+		assert.NoError(t, err)
+		assert.Nil(t, resp.PrevKvs, "the previous key-values should not be returned if not requested")
+
+		records := sink.reset()
+		assert.Equal(t, 2, len(records))
+		assert.Equal(t, "alice", records[0].Identity)
+		assert.Equal(t, "PUT", records[0].Operation)
+		assert.Equal(t, "/test_audit/a", records[0].Key)
+		assert.Empty(t, records[0].PrevValueHash)
+		assert.Equal(t, hashOf("a1"), records[0].ValueHash)
+		assert.Equal(t, hashOf("a1"), records[1].PrevValueHash)
+		assert.Equal(t, hashOf("a2"), records[1].ValueHash)
+		assert.Equal(t, resp.Revision, records[1].Revision)
+		assert.True(t, records[1].Succeeded)
+	})
+
+	t.Run("reads should not be recorded", func(t *testing.T) {
+		_, err := inst.Do(ctx, etcdadpt.GET, etcdadpt.WithStrKey("/test_audit/a"))
+		assert.NoError(t, err)
+		_, err = inst.Txn(ctx, []etcdadpt.OpOptions{etcdadpt.OpGet(etcdadpt.WithStrKey("/test_audit/a"))})
+		assert.NoError(t, err)
+		assert.Empty(t, sink.reset())
+	})
+
+	t.Run("txn should record the executed branch", func(t *testing.T) {
+		resp, err := inst.TxnWithCmp(ctx, []etcdadpt.OpOptions{
+			etcdadpt.OpPut(etcdadpt.WithStrKey("/test_audit/b"), etcdadpt.WithStrValue("b")),
+		}, etcdadpt.If(etcdadpt.NotExistKey("/test_audit/a")), []etcdadpt.OpOptions{
+			etcdadpt.OpPut(etcdadpt.WithStrKey("/test_audit/c"), etcdadpt.WithStrValue("c")),
+		})
+		assert.NoError(t, err)
+		assert.False(t, resp.Succeeded)
+
+		records := sink.reset()
+		assert.Equal(t, 1, len(records))
+		assert.Equal(t, "TXN", records[0].Operation)
+		assert.Equal(t, "/test_audit/c", records[0].Key)
+	})
+
+	t.Run("delete by prefix should record every deleted key", func(t *testing.T) {
+		resp, err := inst.Do(ctx, etcdadpt.DEL, etcdadpt.WithStrKey("/test_audit/"), etcdadpt.WithPrefix(),
+			etcdadpt.WithPrevKv())
+		assert.NoError(t, err)
+		assert.Equal(t, 2, len(resp.PrevKvs), "the previous key-values requested should be returned")
+
+		records := sink.reset()
+		assert.Equal(t, 2, len(records))
+		hashes := map[string]string{}
+		for _, r := range records {
+			assert.Equal(t, "DELETE", r.Action)
+			assert.Empty(t, r.ValueHash)
+			hashes[r.Key] = r.PrevValueHash
+		}
+		assert.Equal(t, map[string]string{"/test_audit/a": hashOf("a2"), "/test_audit/c": hashOf("c")}, hashes)
+	})
+}
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	sink := audit.NewFileSink(audit.FileOptions{Path: path, MaxBackups: 1})
+	defer sink.Close()
+
+	ctx := context.Background()
+	assert.NoError(t, sink.Write(ctx, []*audit.Record{{Key: "/a"}, {Key: "/b"}}))
+	assert.NoError(t, sink.Rotate())
+	assert.NoError(t, sink.Write(ctx, []*audit.Record{{Key: "/c"}}))
+
+	f, err := os.Open(path)
+	assert.NoError(t, err)
+	defer f.Close()
+	var keys []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r audit.Record
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &r))
+		keys = append(keys, r.Key)
+	}
+	assert.Equal(t, []string{"/c"}, keys)
+
+	files, err := filepath.Glob(filepath.Join(filepath.Dir(path), "audit-*.log"))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(files))
+}
+
+func TestEtcdSink(t *testing.T) {
+	inst := newInstance()
+	sink := audit.NewEtcdSink(inst, "/test_audit_records/")
+	audit.Register(sink)
+	defer audit.Register(nil)
+
+	ctx := context.Background()
+	_, err := inst.Do(ctx, etcdadpt.PUT, etcdadpt.WithStrKey("/test_audit_sink"), etcdadpt.WithStrValue("a"))
+	assert.NoError(t, err)
+	_, err = inst.Do(ctx, etcdadpt.DEL, etcdadpt.WithStrKey("/test_audit_sink"))
+	assert.NoError(t, err)
+	audit.Register(nil)
+
+	resp, err := inst.Do(ctx, etcdadpt.GET, etcdadpt.WithStrKey(sink.Prefix()), etcdadpt.WithPrefix())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(resp.Kvs), "the records written by the sink should not be audited")
+	var r audit.Record
+	assert.NoError(t, json.Unmarshal(resp.Kvs[1].Value, &r))
+	assert.Equal(t, "DELETE", r.Action)
+	assert.Equal(t, "/test_audit_sink", r.Key)
+	assert.Equal(t, hashOf("a"), r.PrevValueHash)
+
+	_, err = inst.Do(ctx, etcdadpt.DEL, etcdadpt.WithStrKey(sink.Prefix()), etcdadpt.WithPrefix())
+	assert.NoError(t, err)
+}
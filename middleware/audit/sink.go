@@ -0,0 +1,134 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+
+	"github.com/little-cui/etcdadpt"
+)
+
+// DefaultEtcdPrefix the reserved prefix of the audit records in etcd
+const DefaultEtcdPrefix = "/etcdadpt/audit/"
+
+type FileOptions struct {
+	// Path the audit file, the rotated ones are in the same directory
+	Path string
+	// MaxSize the megabytes of the file before it is rotated, default 100
+	MaxSize int
+	// MaxBackups and MaxAge(days) the rotated files to retain, 0 means retain all
+	MaxBackups int
+	MaxAge     int
+	// Compress compresses the rotated files by gzip
+	Compress bool
+}
+
+// FileSink writes the records to the rotated file in JSON lines
+type FileSink struct {
+	mux    sync.Mutex
+	writer *lumberjack.Logger
+}
+
+func NewFileSink(opts FileOptions) *FileSink {
+	return &FileSink{writer: &lumberjack.Logger{
+		Filename:   opts.Path,
+		MaxSize:    opts.MaxSize,
+		MaxBackups: opts.MaxBackups,
+		MaxAge:     opts.MaxAge,
+		Compress:   opts.Compress,
+	}}
+}
+
+func (s *FileSink) Write(_ context.Context, records []*Record) error {
+	var buf []byte
+	for _, r := range records {
+		b, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, b...), '\n')
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	_, err := s.writer.Write(buf)
+	return err
+}
+
+// Rotate closes the current file and starts a new one
+func (s *FileSink) Rotate() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.writer.Rotate()
+}
+
+func (s *FileSink) Close() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.writer.Close()
+}
+
+// EtcdSink writes the records under the reserved prefix of etcd, the records
+// are written by the plugin client directly, so they are not audited
+type EtcdSink struct {
+	client etcdadpt.Client
+	prefix string
+	seq    uint64
+}
+
+// NewEtcdSink returns the sink writing the records by client, prefix default DefaultEtcdPrefix
+func NewEtcdSink(client etcdadpt.Client, prefix string) *EtcdSink {
+	if len(prefix) == 0 {
+		prefix = DefaultEtcdPrefix
+	}
+	return &EtcdSink{client: etcdadpt.Unwrap(client), prefix: prefix}
+}
+
+// Prefix returns the prefix of the records
+func (s *EtcdSink) Prefix() string {
+	return s.prefix
+}
+
+func (s *EtcdSink) Write(ctx context.Context, records []*Record) error {
+	ops := make([]etcdadpt.OpOptions, 0, len(records))
+	for _, r := range records {
+		b, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		// the keys are ordered by the time of the records
+		key := fmt.Sprintf("%s%019d-%010d", s.prefix, r.Time.UnixNano(), atomic.AddUint64(&s.seq, 1))
+		ops = append(ops, etcdadpt.OpPut(etcdadpt.WithStrKey(key), etcdadpt.WithValue(b)))
+	}
+	for len(ops) > 0 {
+		n := len(ops)
+		if n > etcdadpt.MaxTxnNumberOneTime {
+			n = etcdadpt.MaxTxnNumberOneTime
+		}
+		if _, err := s.client.Txn(ctx, ops[:n]); err != nil {
+			return err
+		}
+		ops = ops[n:]
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chassis/openlog"
+)
+
+// Category the category of the logs, the level and sampling can be configured by category
+type Category string
+
+const (
+	// CategoryDefault the logs not in any category
+	CategoryDefault Category = ""
+	// CategoryOperation the logs of the slow operations, large request paging,
+	// compaction, defragmentation and snapshot
+	CategoryOperation Category = "operation"
+	// CategoryLock the logs of the distributed lock
+	CategoryLock Category = "lock"
+	// CategoryHealth the logs of the health check, reconnection and members sync
+	CategoryHealth Category = "health"
+	// CategoryRetry the logs of the retries of the remote requests
+	CategoryRetry Category = "retry"
+	// CategoryServer the logs of the embedded etcd server
+	CategoryServer Category = "server"
+)
+
+// the field names of the structured logs, passed by openlog.WithTags
+const (
+	FieldOperation = "op"
+	FieldKey       = "key"
+	FieldRevision  = "revision"
+	FieldCost      = "cost"
+	FieldEndpoint  = "endpoint"
+	FieldLockID    = "lockId"
+)
+
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+	// LevelOff mutes all the logs except fatal ones
+	LevelOff
+)
+
+const (
+	DefaultSlowThreshold  = time.Second
+	DefaultSampleInterval = time.Second
+)
+
+type CategoryOptions struct {
+	// Level the minimum level of the logs, default LevelDebug
+	Level Level
+	// SampleInitial and SampleThereafter sample the logs with the same level and message,
+	// the first SampleInitial ones in every SampleInterval are logged, then every
+	// SampleThereafter-th one, SampleInitial = 0 means no sampling
+	SampleInitial    int
+	SampleThereafter int
+	// SampleInterval default DefaultSampleInterval
+	SampleInterval time.Duration
+}
+
+type Options struct {
+	// SlowThreshold the operations slower than it are logged at warn level, default DefaultSlowThreshold
+	SlowThreshold time.Duration
+	// Categories the options of the categories, the category not in it logs everything
+	Categories map[Category]CategoryOptions
+}
+
+type settings struct {
+	slowThreshold time.Duration
+	samplers      map[Category]*sampler
+}
+
+var current atomic.Value // *settings
+
+func init() {
+	SetOptions(Options{})
+}
+
+// SetOptions sets the slow threshold and the options of the categories
+func SetOptions(opts Options) {
+	s := &settings{slowThreshold: opts.SlowThreshold, samplers: make(map[Category]*sampler, len(opts.Categories))}
+	if s.slowThreshold <= 0 {
+		s.slowThreshold = DefaultSlowThreshold
+	}
+	for category, o := range opts.Categories {
+		if o.SampleInterval <= 0 {
+			o.SampleInterval = DefaultSampleInterval
+		}
+		s.samplers[category] = &sampler{opts: o}
+	}
+	current.Store(s)
+}
+
+// SlowThreshold returns the threshold of the slow operations
+func SlowThreshold() time.Duration {
+	return current.Load().(*settings).slowThreshold
+}
+
+// GetCategoryLogger returns the logger filtering the logs by the options of the category
+func GetCategoryLogger(category Category) openlog.Logger {
+	return categoryLogger{category: category}
+}
+
+type categoryLogger struct {
+	category Category
+}
+
+func (l categoryLogger) allow(level Level, message string) bool {
+	s, ok := current.Load().(*settings).samplers[l.category]
+	if !ok {
+		return true
+	}
+	return s.allow(level, message)
+}
+
+func (l categoryLogger) Debug(message string, opts ...openlog.Option) {
+	if l.allow(LevelDebug, message) {
+		GetLogger().Debug(message, opts...)
+	}
+}
+
+func (l categoryLogger) Info(message string, opts ...openlog.Option) {
+	if l.allow(LevelInfo, message) {
+		GetLogger().Info(message, opts...)
+	}
+}
+
+func (l categoryLogger) Warn(message string, opts ...openlog.Option) {
+	if l.allow(LevelWarn, message) {
+		GetLogger().Warn(message, opts...)
+	}
+}
+
+func (l categoryLogger) Error(message string, opts ...openlog.Option) {
+	if l.allow(LevelError, message) {
+		GetLogger().Error(message, opts...)
+	}
+}
+
+func (l categoryLogger) Fatal(message string, opts ...openlog.Option) {
+	GetLogger().Fatal(message, opts...)
+}
+
+type sampler struct {
+	opts CategoryOptions
+
+	mux     sync.Mutex
+	counts  map[string]int
+	resetAt time.Time
+}
+
+func (s *sampler) allow(level Level, message string) bool {
+	if level < s.opts.Level {
+		return false
+	}
+	if s.opts.SampleInitial <= 0 {
+		return true
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if now := time.Now(); now.After(s.resetAt) {
+		s.counts = make(map[string]int)
+		s.resetAt = now.Add(s.opts.SampleInterval)
+	}
+	key := strconv.Itoa(int(level)) + message
+	n := s.counts[key] + 1
+	s.counts[key] = n
+	if n <= s.opts.SampleInitial {
+		return true
+	}
+	return s.opts.SampleThereafter > 0 && (n-s.opts.SampleInitial)%s.opts.SampleThereafter == 0
+}
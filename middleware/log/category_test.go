@@ -0,0 +1,124 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-chassis/openlog"
+	"github.com/little-cui/etcdadpt/middleware/log"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordLogger struct {
+	messages []string
+	tags     []openlog.Tags
+}
+
+func (r *recordLogger) record(message string, opts ...openlog.Option) {
+	r.messages = append(r.messages, message)
+	r.tags = append(r.tags, openlog.ToOptions(opts...).Tags)
+}
+func (r *recordLogger) Debug(message string, opts ...openlog.Option) {
+	r.record("debug:"+message, opts...)
+}
+func (r *recordLogger) Info(message string, opts ...openlog.Option) {
+	r.record("info:"+message, opts...)
+}
+func (r *recordLogger) Warn(message string, opts ...openlog.Option) {
+	r.record("warn:"+message, opts...)
+}
+func (r *recordLogger) Error(message string, opts ...openlog.Option) {
+	r.record("error:"+message, opts...)
+}
+func (r *recordLogger) Fatal(message string, opts ...openlog.Option) {
+	r.record("fatal:"+message, opts...)
+}
+
+func withRecordLogger(t *testing.T, opts log.Options) *recordLogger {
+	r := &recordLogger{}
+	oldLogger := log.GetLogger()
+	log.SetLogger(r)
+	log.SetOptions(opts)
+	t.Cleanup(func() {
+		log.SetLogger(oldLogger)
+		log.SetOptions(log.Options{})
+	})
+	return r
+}
+
+func TestGetCategoryLogger(t *testing.T) {
+	t.Run("category not configured should log everything", func(t *testing.T) {
+		r := withRecordLogger(t, log.Options{})
+		logger := log.GetCategoryLogger(log.CategoryLock)
+		logger.Debug("a", openlog.WithTags(openlog.Tags{log.FieldKey: "k"}))
+		logger.Info("b")
+		assert.Equal(t, []string{"debug:a", "info:b"}, r.messages)
+		assert.Equal(t, "k", r.tags[0][log.FieldKey])
+	})
+
+	t.Run("level should filter the lower logs", func(t *testing.T) {
+		r := withRecordLogger(t, log.Options{Categories: map[log.Category]log.CategoryOptions{
+			log.CategoryLock:  {Level: log.LevelWarn},
+			log.CategoryRetry: {Level: log.LevelOff},
+		}})
+		lock := log.GetCategoryLogger(log.CategoryLock)
+		lock.Debug("a")
+		lock.Info("b")
+		lock.Warn("c")
+		lock.Error("d")
+		retry := log.GetCategoryLogger(log.CategoryRetry)
+		retry.Error("e")
+		retry.Fatal("f")
+		log.GetCategoryLogger(log.CategoryHealth).Debug("g")
+		assert.Equal(t, []string{"warn:c", "error:d", "fatal:f", "debug:g"}, r.messages)
+	})
+
+	t.Run("sampling should drop the repeated messages", func(t *testing.T) {
+		r := withRecordLogger(t, log.Options{Categories: map[log.Category]log.CategoryOptions{
+			log.CategoryOperation: {SampleInitial: 2, SampleThereafter: 3, SampleInterval: time.Minute},
+		}})
+		logger := log.GetCategoryLogger(log.CategoryOperation)
+		for i := 0; i < 8; i++ {
+			logger.Warn("a")
+		}
+		logger.Warn("b")
+		logger.Info("a")
+		assert.Equal(t, []string{"warn:a", "warn:a", "warn:a", "warn:a", "warn:b", "info:a"}, r.messages)
+	})
+
+	t.Run("sampling should restart every interval", func(t *testing.T) {
+		r := withRecordLogger(t, log.Options{Categories: map[log.Category]log.CategoryOptions{
+			log.CategoryOperation: {SampleInitial: 1, SampleInterval: 50 * time.Millisecond},
+		}})
+		logger := log.GetCategoryLogger(log.CategoryOperation)
+		logger.Warn("a")
+		logger.Warn("a")
+		time.Sleep(100 * time.Millisecond)
+		logger.Warn("a")
+		assert.Equal(t, []string{"warn:a", "warn:a"}, r.messages)
+	})
+}
+
+func TestSlowThreshold(t *testing.T) {
+	assert.Equal(t, log.DefaultSlowThreshold, log.SlowThreshold())
+	log.SetOptions(log.Options{SlowThreshold: time.Millisecond})
+	defer log.SetOptions(log.Options{})
+	assert.Equal(t, time.Millisecond, log.SlowThreshold())
+}
@@ -18,26 +18,109 @@
 package metrics
 
 import (
-	"time"
+	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/prometheus/client_golang/prometheus"
 )
 
 const (
-	success = "SUCCESS"
-	failure = "FAILURE"
+	success     = "SUCCESS"
+	failure     = "FAILURE"
+	otherPrefix = "other"
+)
+
+// DefaultMaxPrefixes the default maximum number of the "prefix" label values
+const DefaultMaxPrefixes = 100
+
+var (
+	// DefaultLatencyBuckets the default buckets of the operation latency, from 1ms to 10s
+	DefaultLatencyBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+	// DefaultSizeBuckets the default buckets of the payload size, from 64B to 16MB
+	DefaultSizeBuckets = prometheus.ExponentialBuckets(64, 4, 10)
 )
 
 var (
+	current  atomic.Value // *collectors
+	initLock sync.Mutex
+)
+
+type collectors struct {
 	options          Options
 	backendCounter   *prometheus.GaugeVec
 	operationCounter *prometheus.CounterVec
-	operationLatency *prometheus.SummaryVec
-)
+	operationLatency *prometheus.HistogramVec
+	valueSize        *prometheus.HistogramVec
+	responseSize     *prometheus.HistogramVec
+	watchEvents      *prometheus.CounterVec
+	pagingPages      *prometheus.CounterVec
+	txnChunks        *prometheus.CounterVec
+	operationRetries *prometheus.CounterVec
+	circuitState     *prometheus.GaugeVec
+	circuitRejected  *prometheus.CounterVec
+	raftIndex        *prometheus.GaugeVec
+	dbSize           *prometheus.GaugeVec
+	leaderChanges    *prometheus.CounterVec
+
+	registered []prometheus.Collector
+	// err the first error of the registration
+	err error
+
+	prefixes     map[string]struct{}
+	prefixesLock sync.Mutex
+}
+
+func load() *collectors {
+	c, _ := current.Load().(*collectors)
+	return c
+}
+
+// Init registers the metrics to Options.Registerer, it is idempotent,
+// the metrics registered by the previous Init are unregistered first.
+// It returns the error if any metric can not be registered, e.g. the registry
+// keeps the label names of the unregistered metrics, so the "prefix" label can
+// not be added or removed by changing PrefixSegments, then the reports are
+// ignored until the next successful Init
+func Init(opts Options) error {
+	initLock.Lock()
+	defer initLock.Unlock()
+
+	opts.init()
+	if old := load(); old != nil {
+		old.unregister()
+	}
+	c := newCollectors(opts)
+	if c.err != nil {
+		c.unregister()
+		current.Store((*collectors)(nil))
+		return c.err
+	}
+	current.Store(c)
+	return nil
+}
+
+// Close unregisters the metrics from Options.Registerer, the reports after Close are ignored
+func Close() {
+	initLock.Lock()
+	defer initLock.Unlock()
+
+	if old := load(); old != nil {
+		old.unregister()
+		current.Store((*collectors)(nil))
+	}
+}
+
+func newCollectors(options Options) *collectors {
+	c := &collectors{options: options, prefixes: make(map[string]struct{})}
+	operationLabels := []string{"instance", "operation", "status"}
+	payloadLabels := []string{"instance", "operation"}
+	if options.PrefixSegments > 0 {
+		operationLabels = append(operationLabels, "prefix")
+		payloadLabels = append(payloadLabels, "prefix")
+	}
 
-func Init(opts Options) {
-	options = opts
-	backendCounter = NewGaugeVec(
+	c.backendCounter = c.newGaugeVec(
 		prometheus.GaugeOpts{
 			Namespace: options.Namespace,
 			Subsystem: "db",
@@ -45,43 +128,183 @@ func Init(opts Options) {
 			Help:      "Gauge of the backend instance",
 		}, []string{"instance"})
 
-	operationCounter = NewCounterVec(
+	c.operationCounter = c.newCounterVec(
 		prometheus.CounterOpts{
 			Namespace: options.Namespace,
 			Subsystem: "db",
 			Name:      "backend_operation_total",
 			Help:      "Counter of backend operation",
-		}, []string{"instance", "operation", "status"})
-
-	operationLatency = NewSummaryVec(
-		prometheus.SummaryOpts{
-			Namespace:  options.Namespace,
-			Subsystem:  "db",
-			Name:       "backend_operation_durations_microseconds",
-			Help:       "Latency of backend operation",
-			Objectives: PXX,
-		}, []string{"instance", "operation", "status"})
+		}, operationLabels)
+
+	c.operationLatency = c.newHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: options.Namespace,
+			Subsystem: "db",
+			Name:      "backend_operation_duration_seconds",
+			Help:      "Latency of backend operation",
+			Buckets:   options.LatencyBuckets,
+		}, operationLabels)
+
+	c.valueSize = c.newHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: options.Namespace,
+			Subsystem: "db",
+			Name:      "backend_request_value_bytes",
+			Help:      "Size of the values written by backend operation",
+			Buckets:   options.SizeBuckets,
+		}, payloadLabels)
+
+	c.responseSize = c.newHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: options.Namespace,
+			Subsystem: "db",
+			Name:      "backend_response_bytes",
+			Help:      "Size of the key-values read by backend operation",
+			Buckets:   options.SizeBuckets,
+		}, payloadLabels)
+
+	c.watchEvents = c.newCounterVec(
+		prometheus.CounterOpts{
+			Namespace: options.Namespace,
+			Subsystem: "db",
+			Name:      "backend_watch_events_total",
+			Help:      "Counter of the events received by backend watchers",
+		}, []string{"instance"})
+
+	c.pagingPages = c.newCounterVec(
+		prometheus.CounterOpts{
+			Namespace: options.Namespace,
+			Subsystem: "db",
+			Name:      "backend_paging_pages_total",
+			Help:      "Counter of the pages requested by backend large request paging",
+		}, []string{"instance"})
+
+	c.txnChunks = c.newCounterVec(
+		prometheus.CounterOpts{
+			Namespace: options.Namespace,
+			Subsystem: "db",
+			Name:      "backend_txn_chunks_total",
+			Help:      "Counter of the chunks committed by the txn split for exceeding the max operations",
+		}, []string{"instance"})
+
+	c.operationRetries = c.newCounterVec(
+		prometheus.CounterOpts{
+			Namespace: options.Namespace,
+			Subsystem: "db",
+			Name:      "backend_operation_retries_total",
+			Help:      "Counter of backend operation retries",
+		}, []string{"instance", "operation"})
+
+	c.circuitState = c.newGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: options.Namespace,
+			Subsystem: "db",
+			Name:      "backend_circuit_state",
+			Help:      "Gauge of the backend circuit breaker state, 0: closed, 1: open, 2: half-open",
+		}, []string{"instance"})
+
+	c.circuitRejected = c.newCounterVec(
+		prometheus.CounterOpts{
+			Namespace: options.Namespace,
+			Subsystem: "db",
+			Name:      "backend_circuit_rejected_total",
+			Help:      "Counter of backend operation rejected by the circuit breaker",
+		}, []string{"instance", "operation"})
+
+	c.raftIndex = c.newGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: options.Namespace,
+			Subsystem: "db",
+			Name:      "backend_raft_index",
+			Help:      "Gauge of the raft index of embedded etcd server, type: committed or applied",
+		}, []string{"instance", "type"})
+
+	c.dbSize = c.newGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: options.Namespace,
+			Subsystem: "db",
+			Name:      "backend_db_size_bytes",
+			Help:      "Gauge of the db size of embedded etcd server, type: total or in_use",
+		}, []string{"instance", "type"})
+
+	c.leaderChanges = c.newCounterVec(
+		prometheus.CounterOpts{
+			Namespace: options.Namespace,
+			Subsystem: "db",
+			Name:      "backend_leader_changes_total",
+			Help:      "Counter of the leader changes seen by embedded etcd server",
+		}, []string{"instance"})
+	return c
 }
 
-func ReportBackendInstance(c int) {
-	if backendCounter == nil {
-		return
+// register registers the collector, reuses the existing one if it has
+// been registered by others, for example, another Init with a shared registerer
+func (c *collectors) register(collector prometheus.Collector) prometheus.Collector {
+	if c.err != nil {
+		return collector
 	}
+	err := c.options.Registerer.Register(collector)
+	if err != nil {
+		are, ok := err.(prometheus.AlreadyRegisteredError)
+		if !ok {
+			c.err = err
+			return collector
+		}
+		collector = are.ExistingCollector
+	}
+	c.registered = append(c.registered, collector)
+	return collector
+}
+
+func (c *collectors) unregister() {
+	for _, collector := range c.registered {
+		c.options.Registerer.Unregister(collector)
+	}
+	c.registered = nil
+}
 
-	instance := options.InstanceName
-	backendCounter.WithLabelValues(instance).Set(float64(c))
+func (c *collectors) newCounterVec(opts prometheus.CounterOpts, labelNames []string) *prometheus.CounterVec {
+	return c.register(prometheus.NewCounterVec(opts, labelNames)).(*prometheus.CounterVec)
 }
 
-func ReportBackendOperationCompleted(operation string, err error, start time.Time) {
-	if operationLatency == nil || operationCounter == nil {
-		return
+func (c *collectors) newGaugeVec(opts prometheus.GaugeOpts, labelNames []string) *prometheus.GaugeVec {
+	return c.register(prometheus.NewGaugeVec(opts, labelNames)).(*prometheus.GaugeVec)
+}
+
+func (c *collectors) newHistogramVec(opts prometheus.HistogramOpts, labelNames []string) *prometheus.HistogramVec {
+	return c.register(prometheus.NewHistogramVec(opts, labelNames)).(*prometheus.HistogramVec)
+}
+
+func (c *collectors) withPrefix(key string, labels ...string) []string {
+	if c.options.PrefixSegments <= 0 {
+		return labels
 	}
-	instance := options.InstanceName
-	elapsed := float64(time.Since(start).Nanoseconds()) / float64(time.Microsecond)
-	status := success
-	if err != nil {
-		status = failure
+	return append(labels, c.keyPrefix(key))
+}
+
+// keyPrefix returns the first Options.PrefixSegments path segments of the key, for example,
+// "/cse-sr/ms/files/default" returns "/cse-sr/ms" if the segments is 2. If the number of
+// the returned prefixes exceeds Options.MaxPrefixes, the new ones are "other"
+func (c *collectors) keyPrefix(key string) string {
+	if len(key) == 0 {
+		return ""
+	}
+	n := c.options.PrefixSegments
+	trimmed := strings.TrimPrefix(key, "/")
+	segments := strings.SplitN(trimmed, "/", n+1)
+	if len(segments) > n {
+		segments = segments[:n]
+	}
+	prefix := key[:len(key)-len(trimmed)] + strings.Join(segments, "/")
+
+	c.prefixesLock.Lock()
+	defer c.prefixesLock.Unlock()
+	if _, ok := c.prefixes[prefix]; ok {
+		return prefix
+	}
+	if len(c.prefixes) >= c.options.MaxPrefixes {
+		return otherPrefix
 	}
-	operationLatency.WithLabelValues(instance, operation, status).Observe(elapsed)
-	operationCounter.WithLabelValues(instance, operation, status).Inc()
+	c.prefixes[prefix] = struct{}{}
+	return prefix
 }
@@ -0,0 +1,105 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyPrefix(t *testing.T) {
+	c := &collectors{
+		options:  Options{PrefixSegments: 2, MaxPrefixes: 3},
+		prefixes: make(map[string]struct{}),
+	}
+
+	assert.Equal(t, "/cse-sr/ms", c.keyPrefix("/cse-sr/ms/files/default/default"))
+	assert.Equal(t, "/cse-sr", c.keyPrefix("/cse-sr"))
+	assert.Equal(t, "cse-sr/kv", c.keyPrefix("cse-sr/kv/default"))
+	assert.Equal(t, "", c.keyPrefix(""))
+
+	t.Run("prefixes exceed the max should be other", func(t *testing.T) {
+		assert.Equal(t, otherPrefix, c.keyPrefix("/cse-sr/inst/files"))
+		assert.Equal(t, "/cse-sr/ms", c.keyPrefix("/cse-sr/ms/indexes"))
+	})
+}
+
+func countOf(t *testing.T, registry *prometheus.Registry, name string) map[string]float64 {
+	families, err := registry.Gather()
+	assert.NoError(t, err)
+	values := make(map[string]float64)
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			for _, label := range m.GetLabel() {
+				if label.GetName() == "instance" {
+					values[label.GetValue()] += m.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	return values
+}
+
+func TestInit(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	defer Close()
+
+	t.Run("init twice should not panic", func(t *testing.T) {
+		assert.NoError(t, Init(Options{Registerer: registry, InstanceName: "default"}))
+		assert.NoError(t, Init(Options{Registerer: registry, InstanceName: "default"}))
+		ReportBackendOperationCompleted("GET", nil, time.Now())
+		assert.Equal(t, float64(1), countOf(t, registry, "db_backend_operation_total")["default"])
+	})
+
+	t.Run("reporters should report with their own instance labels", func(t *testing.T) {
+		Reporter{Instance: "a"}.ReportBackendOperationCompleted("GET", nil, time.Now())
+		Reporter{Instance: "b"}.ReportBackendOperationCompleted("GET", errors.New("error"), time.Now())
+		Reporter{Instance: "b"}.ReportBackendOperationCompleted("GET", nil, time.Now())
+		values := countOf(t, registry, "db_backend_operation_total")
+		assert.Equal(t, float64(1), values["default"])
+		assert.Equal(t, float64(1), values["a"])
+		assert.Equal(t, float64(2), values["b"])
+	})
+
+	t.Run("close should unregister the metrics", func(t *testing.T) {
+		Close()
+		ReportBackendOperationCompleted("GET", nil, time.Now())
+		families, err := registry.Gather()
+		assert.NoError(t, err)
+		assert.Empty(t, families)
+
+		assert.NoError(t, Init(Options{Registerer: registry}))
+		assert.Empty(t, countOf(t, registry, "db_backend_operation_total"))
+	})
+
+	t.Run("init with the prefix label changed should return error", func(t *testing.T) {
+		err := Init(Options{Registerer: registry, PrefixSegments: 1})
+		assert.Error(t, err)
+		ReportBackendOperationCompleted("GET", nil, time.Now())
+		families, err := registry.Gather()
+		assert.NoError(t, err)
+		assert.Empty(t, families)
+	})
+}
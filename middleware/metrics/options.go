@@ -17,9 +17,47 @@
 
 package metrics
 
+import (
+	"github.com/go-chassis/go-chassis/v2/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
 type Options struct {
+	// Registerer the prometheus registerer of the metrics,
+	// default the system registry of go-chassis
+	Registerer prometheus.Registerer
 	// Namespace is prometheus namespace
 	Namespace string
 	// InstanceName is the "instance" label
 	InstanceName string
+	// LatencyBuckets the buckets(seconds) of the operation latency histogram,
+	// default DefaultLatencyBuckets
+	LatencyBuckets []float64
+	// SizeBuckets the buckets(bytes) of the payload size histograms, default DefaultSizeBuckets
+	SizeBuckets []float64
+	// PrefixSegments adds the "prefix" label, the first N path segments of the key,
+	// to the operation metrics, 0 means disabled
+	PrefixSegments int
+	// MaxPrefixes the maximum number of the "prefix" label values, the keys out of
+	// them are reported as "other", default DefaultMaxPrefixes
+	MaxPrefixes int
+}
+
+func (opts *Options) init() {
+	if opts.Registerer == nil {
+		opts.Registerer = defaultRegisterer()
+	}
+	if len(opts.LatencyBuckets) == 0 {
+		opts.LatencyBuckets = DefaultLatencyBuckets
+	}
+	if len(opts.SizeBuckets) == 0 {
+		opts.SizeBuckets = DefaultSizeBuckets
+	}
+	if opts.MaxPrefixes <= 0 {
+		opts.MaxPrefixes = DefaultMaxPrefixes
+	}
+}
+
+func defaultRegisterer() prometheus.Registerer {
+	return metrics.GetSystemPrometheusRegistry()
 }
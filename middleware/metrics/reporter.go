@@ -0,0 +1,214 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"time"
+)
+
+// Reporter reports the metrics with the "instance" label, it is used when there
+// are multiple clients, the zero value reports with Options.InstanceName
+type Reporter struct {
+	// Instance the "instance" label, default Options.InstanceName
+	Instance string
+}
+
+func (r Reporter) instance(c *collectors) string {
+	if len(r.Instance) == 0 {
+		return c.options.InstanceName
+	}
+	return r.Instance
+}
+
+func (r Reporter) ReportBackendInstance(count int) {
+	c := load()
+	if c == nil {
+		return
+	}
+	c.backendCounter.WithLabelValues(r.instance(c)).Set(float64(count))
+}
+
+func (r Reporter) ReportBackendOperationCompleted(operation string, err error, start time.Time) {
+	r.ReportBackendKeyOperationCompleted(operation, "", err, start)
+}
+
+// ReportBackendKeyOperationCompleted reports the operation with the "prefix" label of the key
+func (r Reporter) ReportBackendKeyOperationCompleted(operation, key string, err error, start time.Time) {
+	c := load()
+	if c == nil {
+		return
+	}
+	elapsed := time.Since(start).Seconds()
+	status := success
+	if err != nil {
+		status = failure
+	}
+	labels := c.withPrefix(key, r.instance(c), operation, status)
+	c.operationLatency.WithLabelValues(labels...).Observe(elapsed)
+	c.operationCounter.WithLabelValues(labels...).Inc()
+}
+
+// ReportBackendValueSize reports the total size of the values written by the operation
+func (r Reporter) ReportBackendValueSize(operation, key string, size int) {
+	c := load()
+	if c == nil {
+		return
+	}
+	c.valueSize.WithLabelValues(c.withPrefix(key, r.instance(c), operation)...).Observe(float64(size))
+}
+
+// ReportBackendResponseSize reports the total size of the key-values read by the operation
+func (r Reporter) ReportBackendResponseSize(operation, key string, size int) {
+	c := load()
+	if c == nil {
+		return
+	}
+	c.responseSize.WithLabelValues(c.withPrefix(key, r.instance(c), operation)...).Observe(float64(size))
+}
+
+func (r Reporter) ReportBackendWatchEvents(count int) {
+	c := load()
+	if c == nil {
+		return
+	}
+	c.watchEvents.WithLabelValues(r.instance(c)).Add(float64(count))
+}
+
+func (r Reporter) ReportBackendPagingPage() {
+	c := load()
+	if c == nil {
+		return
+	}
+	c.pagingPages.WithLabelValues(r.instance(c)).Inc()
+}
+
+func (r Reporter) ReportBackendTxnChunks(count int) {
+	c := load()
+	if c == nil {
+		return
+	}
+	c.txnChunks.WithLabelValues(r.instance(c)).Add(float64(count))
+}
+
+func (r Reporter) ReportBackendOperationRetried(operation string) {
+	c := load()
+	if c == nil {
+		return
+	}
+	c.operationRetries.WithLabelValues(r.instance(c), operation).Inc()
+}
+
+func (r Reporter) ReportBackendCircuitState(state int) {
+	c := load()
+	if c == nil {
+		return
+	}
+	c.circuitState.WithLabelValues(r.instance(c)).Set(float64(state))
+}
+
+func (r Reporter) ReportBackendCircuitRejected(operation string) {
+	c := load()
+	if c == nil {
+		return
+	}
+	c.circuitRejected.WithLabelValues(r.instance(c), operation).Inc()
+}
+
+func (r Reporter) ReportBackendRaftIndex(committed, applied uint64) {
+	c := load()
+	if c == nil {
+		return
+	}
+	instance := r.instance(c)
+	c.raftIndex.WithLabelValues(instance, "committed").Set(float64(committed))
+	c.raftIndex.WithLabelValues(instance, "applied").Set(float64(applied))
+}
+
+func (r Reporter) ReportBackendDBSize(size, inUse int64) {
+	c := load()
+	if c == nil {
+		return
+	}
+	instance := r.instance(c)
+	c.dbSize.WithLabelValues(instance, "total").Set(float64(size))
+	c.dbSize.WithLabelValues(instance, "in_use").Set(float64(inUse))
+}
+
+func (r Reporter) ReportBackendLeaderChanged() {
+	c := load()
+	if c == nil {
+		return
+	}
+	c.leaderChanges.WithLabelValues(r.instance(c)).Inc()
+}
+
+func ReportBackendInstance(c int) {
+	Reporter{}.ReportBackendInstance(c)
+}
+
+func ReportBackendOperationCompleted(operation string, err error, start time.Time) {
+	Reporter{}.ReportBackendOperationCompleted(operation, err, start)
+}
+
+func ReportBackendKeyOperationCompleted(operation, key string, err error, start time.Time) {
+	Reporter{}.ReportBackendKeyOperationCompleted(operation, key, err, start)
+}
+
+func ReportBackendValueSize(operation, key string, size int) {
+	Reporter{}.ReportBackendValueSize(operation, key, size)
+}
+
+func ReportBackendResponseSize(operation, key string, size int) {
+	Reporter{}.ReportBackendResponseSize(operation, key, size)
+}
+
+func ReportBackendWatchEvents(c int) {
+	Reporter{}.ReportBackendWatchEvents(c)
+}
+
+func ReportBackendPagingPage() {
+	Reporter{}.ReportBackendPagingPage()
+}
+
+func ReportBackendTxnChunks(c int) {
+	Reporter{}.ReportBackendTxnChunks(c)
+}
+
+func ReportBackendOperationRetried(operation string) {
+	Reporter{}.ReportBackendOperationRetried(operation)
+}
+
+func ReportBackendCircuitState(state int) {
+	Reporter{}.ReportBackendCircuitState(state)
+}
+
+func ReportBackendCircuitRejected(operation string) {
+	Reporter{}.ReportBackendCircuitRejected(operation)
+}
+
+func ReportBackendRaftIndex(committed, applied uint64) {
+	Reporter{}.ReportBackendRaftIndex(committed, applied)
+}
+
+func ReportBackendDBSize(size, inUse int64) {
+	Reporter{}.ReportBackendDBSize(size, inUse)
+}
+
+func ReportBackendLeaderChanged() {
+	Reporter{}.ReportBackendLeaderChanged()
+}
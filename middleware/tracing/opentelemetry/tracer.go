@@ -0,0 +1,120 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package opentelemetry implements the tracing.Tracer by OpenTelemetry,
+// the spans are started as the children of the span in the request context
+package opentelemetry
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"strconv"
+
+	"github.com/little-cui/etcdadpt/middleware/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentationName the name of the tracer provided by the TracerProvider
+const InstrumentationName = "github.com/little-cui/etcdadpt"
+
+const (
+	DBSystemEtcd = "etcd"
+
+	KeyAttributeKey       = attribute.Key("db.etcd.key")
+	OpCountAttributeKey   = attribute.Key("db.etcd.op_count")
+	ActionAttributeKey    = attribute.Key("db.etcd.action")
+	SucceededAttributeKey = attribute.Key("db.etcd.succeeded")
+	KeyCountAttributeKey  = attribute.Key("db.etcd.key_count")
+	RevisionAttributeKey  = attribute.Key("db.etcd.revision")
+	EndpointAttributeKey  = attribute.Key("db.etcd.endpoint")
+)
+
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+// NewTracer creates the Tracer by the TracerProvider, the global one if it is nil
+func NewTracer(provider trace.TracerProvider) *Tracer {
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	return &Tracer{tracer: provider.Tracer(InstrumentationName)}
+}
+
+// Register registers the Tracer created by the TracerProvider to tracing
+func Register(provider trace.TracerProvider) {
+	tracing.Register(NewTracer(provider))
+}
+
+func (t *Tracer) Start(ctx context.Context, operationName string, request *tracing.Request) (context.Context, tracing.EndFunc) {
+	attrs := []attribute.KeyValue{
+		semconv.DBSystemKey.String(DBSystemEtcd),
+		semconv.DBOperationKey.String(request.Operation),
+		OpCountAttributeKey.Int(request.OpCount),
+	}
+	if len(request.Options.Key) > 0 {
+		attrs = append(attrs, KeyAttributeKey.String(string(request.Options.Key)))
+	}
+	ctx, span := t.tracer.Start(ctx, operationName,
+		trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+	return ctx, func(result *tracing.Result) {
+		end(span, result)
+	}
+}
+
+func end(span trace.Span, result *tracing.Result) {
+	defer span.End()
+
+	span.SetAttributes(
+		ActionAttributeKey.String(result.Action.String()),
+		SucceededAttributeKey.Bool(result.Succeeded),
+		KeyCountAttributeKey.Int64(result.Count),
+		RevisionAttributeKey.Int64(result.Revision),
+	)
+	if len(result.Endpoint) > 0 {
+		span.SetAttributes(endpointAttributes(result.Endpoint)...)
+	}
+	if result.Err != nil {
+		span.RecordError(result.Err)
+		span.SetStatus(codes.Error, result.Err.Error())
+		return
+	}
+	span.SetStatus(codes.Ok, "")
+}
+
+// endpointAttributes returns the endpoint and the net.peer attributes parsed from it
+func endpointAttributes(endpoint string) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{EndpointAttributeKey.String(endpoint)}
+	host := endpoint
+	if u, err := url.Parse(endpoint); err == nil && len(u.Host) > 0 {
+		host = u.Host
+	}
+	name, port, err := net.SplitHostPort(host)
+	if err != nil {
+		return append(attrs, semconv.NetPeerNameKey.String(host))
+	}
+	attrs = append(attrs, semconv.NetPeerNameKey.String(name))
+	if p, err := strconv.Atoi(port); err == nil {
+		attrs = append(attrs, semconv.NetPeerPortKey.Int(p))
+	}
+	return attrs
+}
@@ -0,0 +1,141 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package opentelemetry_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	_ "github.com/little-cui/etcdadpt/test"
+
+	"github.com/little-cui/etcdadpt"
+	"github.com/little-cui/etcdadpt/middleware/tracing"
+	"github.com/little-cui/etcdadpt/middleware/tracing/opentelemetry"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func attributesOf(span *sdktrace.SpanSnapshot) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+// isEmbedded returns true if the test instance is the embedded etcd, see test/init.go
+func isEmbedded() bool {
+	kind := os.Getenv("TEST_DB_KIND")
+	return kind == "embedded_etcd" || kind == "embeded_etcd"
+}
+
+func TestTracer(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	opentelemetry.Register(provider)
+	defer tracing.Register(nil)
+
+	// wrap the test instance with the tracing interceptor only, it is independent of
+	// whether the interceptor registered in init is in the chain of the test instance
+	inst := etcdadpt.NewChainClient(etcdadpt.Unwrap(etcdadpt.Instance()), tracing.Interceptor)
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "parent")
+	_, err := inst.Do(ctx, etcdadpt.PUT, etcdadpt.WithStrKey("/test_otel/a"), etcdadpt.WithStrValue("a"))
+	assert.NoError(t, err)
+	_, err = inst.TxnWithCmp(ctx, []etcdadpt.OpOptions{
+		etcdadpt.OpGet(etcdadpt.WithStrKey("/test_otel/"), etcdadpt.WithPrefix()),
+		etcdadpt.OpDel(etcdadpt.WithStrKey("/test_otel/"), etcdadpt.WithPrefix()),
+	}, nil, nil)
+	assert.NoError(t, err)
+	err = inst.LeaseRevoke(ctx, 1)
+	assert.Error(t, err)
+	parent.End()
+
+	spans := exporter.GetSpans()
+	assert.Equal(t, 4, len(spans))
+
+	t.Run("spans should be the children of the span in context", func(t *testing.T) {
+		for _, span := range spans[:3] {
+			assert.Equal(t, parent.SpanContext().TraceID(), span.SpanContext.TraceID())
+			assert.Equal(t, parent.SpanContext().SpanID(), span.Parent.SpanID())
+		}
+	})
+
+	t.Run("span should have the semantic attributes", func(t *testing.T) {
+		span := spans[0]
+		assert.Equal(t, "etcd:do", span.Name)
+		assert.Equal(t, codes.Ok, span.StatusCode)
+		attrs := attributesOf(span)
+		assert.Equal(t, "PUT", attrs["db.etcd.action"].AsString())
+		assert.True(t, attrs["db.etcd.succeeded"].AsBool())
+		assert.Equal(t, "etcd", attrs["db.system"].AsString())
+		assert.Equal(t, "PUT", attrs["db.operation"].AsString())
+		assert.Equal(t, "/test_otel/a", attrs["db.etcd.key"].AsString())
+		assert.True(t, attrs["db.etcd.revision"].AsInt64() > 0)
+		if isEmbedded() {
+			// the embedded test instance exposes no client urls
+			assert.NotContains(t, attrs, attribute.Key("db.etcd.endpoint"))
+		} else {
+			assert.NotEmpty(t, attrs["db.etcd.endpoint"].AsString())
+			assert.NotEmpty(t, attrs["net.peer.name"].AsString())
+			assert.True(t, attrs["net.peer.port"].AsInt64() > 0)
+		}
+
+		span = spans[1]
+		assert.Equal(t, "etcd:txn", span.Name)
+		attrs = attributesOf(span)
+		assert.Equal(t, "TXN", attrs["db.operation"].AsString())
+		assert.Equal(t, int64(2), attrs["db.etcd.op_count"].AsInt64())
+		assert.Equal(t, int64(1), attrs["db.etcd.key_count"].AsInt64())
+	})
+
+	t.Run("failed span should record the error", func(t *testing.T) {
+		span := spans[2]
+		assert.Equal(t, "etcd:revoke", span.Name)
+		assert.Equal(t, codes.Error, span.StatusCode)
+		assert.NotEmpty(t, span.StatusMessage)
+		assert.Equal(t, 1, len(span.MessageEvents))
+		assert.Equal(t, "exception", span.MessageEvents[0].Name)
+		assert.False(t, attributesOf(span)["db.etcd.succeeded"].AsBool())
+	})
+
+	t.Run("pages should be traced as the children of the paging request", func(t *testing.T) {
+		if isEmbedded() {
+			t.Skip("the embedded etcd gets the page in one range request")
+		}
+		_, err := inst.Do(ctx, etcdadpt.PUT, etcdadpt.WithStrKey("/test_otel/b"), etcdadpt.WithStrValue("b"))
+		assert.NoError(t, err)
+		defer inst.Do(context.Background(), etcdadpt.DEL, etcdadpt.WithStrKey("/test_otel/b"))
+		exporter.Reset()
+
+		_, err = inst.Do(ctx, etcdadpt.GET, etcdadpt.WithStrKey("/test_otel/"), etcdadpt.WithPrefix(),
+			etcdadpt.WithOffset(0), etcdadpt.WithLimit(1))
+		assert.NoError(t, err)
+		spans := exporter.GetSpans()
+		assert.Equal(t, 2, len(spans))
+		page, get := spans[0], spans[1]
+		assert.Equal(t, "etcd:page", page.Name)
+		assert.Equal(t, "etcd:do", get.Name)
+		assert.Equal(t, get.SpanContext.SpanID(), page.Parent.SpanID())
+		assert.Equal(t, int64(1), attributesOf(page)["db.etcd.key_count"].AsInt64())
+	})
+}
@@ -19,6 +19,8 @@ package tracing
 
 import (
 	"context"
+	"strconv"
+	"strings"
 
 	"github.com/little-cui/etcdadpt"
 )
@@ -26,35 +28,131 @@ import (
 var globalTracer Tracer
 
 type Request struct {
-	Ctx      context.Context
 	Endpoint string
 	Options  etcdadpt.OpOptions
+	// Operation the operation of etcdadpt, like GET, PUT and TXN
+	Operation string
+	// OpCount the number of the OpOptions, it is greater than 1 in a txn
+	OpCount int
 }
 
-type Response struct {
-	Code    int
-	Message string
+// Result the result of the operation
+type Result struct {
+	Action etcdadpt.Action
+	// Succeeded is false if the operation failed or the compares of txn failed
+	Succeeded bool
+	// Count and Revision the count and revision of the *etcdadpt.Response if returned
+	Count    int64
+	Revision int64
+	// Endpoint the endpoint actually serving the request
+	Endpoint string
+	// Err the error returned by the operation
+	Err error
 }
 
+// EndFunc ends the span with the result of the operation
+type EndFunc func(result *Result)
+
 type Tracer interface {
-	Begin(operationName string, request *Request) (span interface{})
-	End(span interface{}, response *Response)
+	// Start starts a span as the child of the one in ctx,
+	// returns the context carrying the new span and the func to end it
+	Start(ctx context.Context, operationName string, request *Request) (context.Context, EndFunc)
 }
 
 func Register(tracer Tracer) {
 	globalTracer = tracer
 }
 
-func Begin(operationName string, request *Request) (span interface{}) {
+func noopEnd(*Result) {}
+
+// Start starts a span by the registered Tracer, the nested operations called
+// with the returned context are traced as the children of the span
+func Start(ctx context.Context, operationName string, request *Request) (context.Context, EndFunc) {
 	if globalTracer == nil {
-		return nil
+		return ctx, noopEnd
 	}
-	return globalTracer.Begin(operationName, request)
+	return globalTracer.Start(ctx, operationName, request)
 }
 
-func End(span interface{}, response *Response) {
-	if globalTracer == nil {
-		return
+var spanNames = map[string]string{
+	"GET":                           "etcd:do",
+	"PUT":                           "etcd:do",
+	"DELETE":                        "etcd:do",
+	etcdadpt.OperationTxn:           "etcd:txn",
+	etcdadpt.OperationLeaseGrant:    "etcd:grant",
+	etcdadpt.OperationLeaseRenew:    "etcd:keepalive",
+	etcdadpt.OperationLeaseRevoke:   "etcd:revoke",
+	etcdadpt.OperationLeaseTTL:      "etcd:timetolive",
+	etcdadpt.OperationMemberList:    "etcd:member:list",
+	etcdadpt.OperationMemberAdd:     "etcd:member:add",
+	etcdadpt.OperationMemberRemove:  "etcd:member:remove",
+	etcdadpt.OperationMemberPromote: "etcd:member:promote",
+	etcdadpt.OperationMemberUpdate:  "etcd:member:update",
+}
+
+var spanActions = map[string]etcdadpt.Action{
+	etcdadpt.OperationLeaseGrant:    etcdadpt.ActionPut,
+	etcdadpt.OperationLeaseRenew:    etcdadpt.ActionPut,
+	etcdadpt.OperationLeaseRevoke:   etcdadpt.ActionDelete,
+	etcdadpt.OperationMemberAdd:     etcdadpt.ActionPut,
+	etcdadpt.OperationMemberRemove:  etcdadpt.ActionDelete,
+	etcdadpt.OperationMemberPromote: etcdadpt.ActionPut,
+	etcdadpt.OperationMemberUpdate:  etcdadpt.ActionPut,
+}
+
+func init() {
+	etcdadpt.RegisterInterceptor(Interceptor)
+}
+
+// Interceptor traces the operations by the registered Tracer, except WATCH
+func Interceptor(ctx context.Context, inv *etcdadpt.Invocation, next etcdadpt.Handler) (interface{}, error) {
+	if globalTracer == nil || inv.Operation == etcdadpt.OperationWatch {
+		return next(ctx, inv)
+	}
+	name, ok := spanNames[inv.Operation]
+	if !ok {
+		name = "etcd:" + strings.ToLower(inv.Operation)
+	}
+	op := toOpOptions(inv)
+	ctx, end := Start(ctx, name, &Request{
+		Endpoint:  inv.Endpoint,
+		Options:   op,
+		Operation: inv.Operation,
+		OpCount:   len(inv.Options) + len(inv.FailOptions),
+	})
+	result, err := next(ctx, inv)
+	end(toResult(op.Action, inv.Endpoint, result, err))
+	return result, err
+}
+
+func toResult(action etcdadpt.Action, endpoint string, result interface{}, err error) *Result {
+	r := &Result{Action: action, Succeeded: err == nil, Endpoint: endpoint, Err: err}
+	if resp, ok := result.(*etcdadpt.Response); ok && resp != nil {
+		r.Succeeded = r.Succeeded && resp.Succeeded
+		r.Count, r.Revision = resp.Count, resp.Revision
+	}
+	return r
+}
+
+// toOpOptions returns the first OpOptions of the invocation, or the
+// OpOptions with the key of the lease or member id
+func toOpOptions(inv *etcdadpt.Invocation) etcdadpt.OpOptions {
+	if len(inv.Options) > 0 {
+		return inv.Options[0]
+	}
+	if len(inv.FailOptions) > 0 {
+		return inv.FailOptions[0]
+	}
+	op := etcdadpt.OpOptions{Action: spanActions[inv.Operation]}
+	switch {
+	case inv.LeaseID != 0:
+		op.Key = []byte(strconv.FormatInt(inv.LeaseID, 10))
+	case inv.TTL != 0:
+		op.Key = []byte(strconv.FormatInt(inv.TTL, 10))
+	case inv.MemberID != 0:
+		op.Key = []byte(strconv.FormatUint(inv.MemberID, 16))
+	case len(inv.PeerURLs) > 0:
+		op.Key = []byte(strings.Join(inv.PeerURLs, ","))
 	}
-	globalTracer.End(span, response)
+	return op
 }
@@ -40,6 +40,8 @@ const (
 	OperationGlobalLock = "GLOBAL_LOCK"
 )
 
+var lockLogger = log.GetCategoryLogger(log.CategoryLock)
+
 var ErrLeaseIDNotExists = errors.New("leaseID is nil")
 var ErrLockKeyFail = errors.New("fail to lock key")
 
@@ -59,7 +61,7 @@ var (
 	pid      = os.Getpid()
 )
 
-func newDLock(key string, ttl int64, wait bool) (*DLock, error) {
+func newDLock(ctx context.Context, key string, ttl int64, wait bool) (*DLock, error) {
 	var err error
 	if len(key) == 0 {
 		return nil, nil
@@ -78,16 +80,17 @@ func newDLock(key string, ttl int64, wait bool) (*DLock, error) {
 		mutex:    &sync.Mutex{},
 	}
 	for try := 1; try <= DefaultRetryTimes; try++ {
-		err = l.lock(wait)
+		err = l.lock(ctx, wait)
 		if err == nil {
 			return l, err
 		}
 
-		if !wait {
+		if !wait || ctx.Err() != nil {
 			break
 		}
 	}
-	log.GetLogger().Error(fmt.Sprintf("lock key %s failed, id=%s", l.key, l.id), openlog.WithErr(err))
+	lockLogger.Error(fmt.Sprintf("lock key %s failed, id=%s, error: %s", l.key, l.id, err),
+		openlog.WithTags(l.tags()), openlog.WithErr(err))
 	return nil, err
 }
 
@@ -95,29 +98,46 @@ func (m *DLock) ID() string {
 	return m.id
 }
 
-func (m *DLock) lock(wait bool) (err error) {
+func (m *DLock) tags() openlog.Tags {
+	return openlog.Tags{log.FieldKey: m.key, log.FieldLockID: m.id}
+}
+
+// lock acquires the lock as a LOCK operation intercepted by the client interceptors,
+// the requests of the acquisition are called with the context of the operation
+func (m *DLock) lock(ctx context.Context, wait bool) error {
+	inv := &Invocation{Operation: OperationLock, Options: []OpOptions{OpPut(WithStrKey(m.key), WithStrValue(m.id))}}
+	_, err := invokeClient(ctx, Instance(), inv, func(ctx context.Context, _ *Invocation) (interface{}, error) {
+		return nil, m.acquire(ctx, wait)
+	})
+	return err
+}
+
+func (m *DLock) acquire(ctx context.Context, wait bool) (err error) {
 	if !IsDebug {
 		m.mutex.Lock()
 	}
-	log.GetLogger().Info(fmt.Sprintf("trying to create a lock: key=%s, id=%s", m.key, m.id))
+	lockLogger.Debug(fmt.Sprintf("trying to create a lock: key=%s, id=%s", m.key, m.id), openlog.WithTags(m.tags()))
+	start := time.Now()
 	var leaseID int64
 	var opts []OpOption
 	if m.ttl > 0 {
-		leaseID, err = Instance().LeaseGrant(m.ctx, m.ttl)
+		leaseID, err = Instance().LeaseGrant(ctx, m.ttl)
 		if err != nil {
 			return err
 		}
 		opts = append(opts, WithLease(leaseID))
 	}
-	success, err := Insert(m.ctx, m.key, m.id, opts...)
+	success, err := Insert(ctx, m.key, m.id, opts...)
 	if err == nil && success {
 		m.leaseID = leaseID
-		log.GetLogger().Info(fmt.Sprintf("succeed to create lock, key=%s, id=%s", m.key, m.id))
+		tags := m.tags()
+		tags[log.FieldCost] = time.Since(start).String()
+		lockLogger.Info(fmt.Sprintf("succeed to create lock, key=%s, id=%s", m.key, m.id), openlog.WithTags(tags))
 		return nil
 	}
 
 	if leaseID > 0 {
-		err = Instance().LeaseRevoke(m.ctx, leaseID)
+		err = Instance().LeaseRevoke(ctx, leaseID)
 		if err != nil {
 			return err
 		}
@@ -127,12 +147,13 @@ func (m *DLock) lock(wait bool) (err error) {
 		return fmt.Errorf("err: %w ,key %s is locked by id=%s", ErrLockKeyFail, m.key, m.id)
 	}
 
-	log.GetLogger().Error(fmt.Sprintf("key %s is locked, waiting for other node releases it, id=%s", m.key, m.id), openlog.WithErr(err))
+	lockLogger.Info(fmt.Sprintf("key %s is locked, waiting for other node releases it, id=%s", m.key, m.id),
+		openlog.WithTags(m.tags()), openlog.WithErr(err))
 
-	ctx, cancel := context.WithTimeout(m.ctx, time.Duration(m.ttl)*time.Second)
+	wCtx, cancel := context.WithTimeout(ctx, time.Duration(m.ttl)*time.Second)
 	gopool.Go(func(context.Context) {
 		defer cancel()
-		err := Instance().Watch(ctx,
+		err := Instance().Watch(wCtx,
 			WithStrKey(m.key),
 			WithWatchCallback(
 				func(message string, evt *Response) error {
@@ -143,12 +164,16 @@ func (m *DLock) lock(wait bool) (err error) {
 					return nil
 				}))
 		if err != nil {
-			log.GetLogger().Warn(fmt.Sprintf("%s, key=%s, id=%s", err.Error(), m.key, m.id))
+			lockLogger.Warn(fmt.Sprintf("watch lock stopped, key=%s, id=%s, error: %s", m.key, m.id, err),
+				openlog.WithTags(m.tags()), openlog.WithErr(err))
 		}
 	})
 	select {
-	case <-ctx.Done():
-		return ctx.Err() // 可以重新尝试获取锁
+	case <-wCtx.Done():
+		if ctx.Err() != nil {
+			return ctx.Err() // the caller canceled the acquisition
+		}
+		return wCtx.Err() // 可以重新尝试获取锁
 	case <-m.ctx.Done():
 		cancel()
 		return m.ctx.Err() // 机制错误，不应该超时的
@@ -175,10 +200,11 @@ func (m *DLock) Unlock() (err error) {
 	for i := 1; i <= DefaultRetryTimes; i++ {
 		_, err := Delete(m.ctx, m.key)
 		if err == nil {
-			log.GetLogger().Info(fmt.Sprintf("delete lock OK, key=%s, id=%s", m.key, m.id))
+			lockLogger.Info(fmt.Sprintf("delete lock OK, key=%s, id=%s", m.key, m.id), openlog.WithTags(m.tags()))
 			return nil
 		}
-		log.GetLogger().Error(fmt.Sprintf("delete lock failed, key=%s, id=%s", m.key, m.id), openlog.WithErr(err))
+		lockLogger.Error(fmt.Sprintf("delete lock failed, key=%s, id=%s, error: %s", m.key, m.id, err),
+			openlog.WithTags(m.tags()), openlog.WithErr(err))
 	}
 	return err
 }
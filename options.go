@@ -44,6 +44,11 @@ type OpOptions struct {
 	Global               bool
 	GlobalInstanceSearch bool
 	InstanceSearch       bool
+	// Retry and MaxRetries override the RetryPolicy of config,
+	// Retry is only used if RetrySet, for RetryDefault is the zero value
+	Retry      RetryMode
+	RetrySet   bool
+	MaxRetries int
 }
 
 func (op OpOptions) String() string {
@@ -152,6 +157,10 @@ func WithStrEndKey(key string) OpOption  { return WithEndKey([]byte(key)) }
 func WithStrValue(value string) OpOption { return WithValue([]byte(value)) }
 func WithOffset(i int64) OpOption        { return func(op *OpOptions) { op.Offset = i } }
 func WithLimit(i int64) OpOption         { return func(op *OpOptions) { op.Limit = i } }
+func WithMaxRetries(n int) OpOption      { return func(op *OpOptions) { op.MaxRetries = n } }
+func WithRetry(mode RetryMode) OpOption {
+	return func(op *OpOptions) { op.Retry, op.RetrySet = mode, true }
+}
 func WatchPrefixOpOptions(key string) []OpOption {
 	return []OpOption{GET, WithStrKey(key), WithPrefix(), WithPrevKv()}
 }
@@ -0,0 +1,163 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/little-cui/etcdadpt"
+	"github.com/little-cui/etcdadpt/middleware/log"
+)
+
+// RefreshToken starts to refresh the auth token in background, the user
+// gets itself periodically, then the client will re-authenticate when
+// the token is invalid, and the simple token will be kept alive
+func (c *Client) RefreshToken() {
+	if len(c.Cfg.Username) > 0 && c.Cfg.TokenRefreshInterval > 0 {
+		c.goroutine.Do(c.RefreshTokenLoop)
+	}
+}
+
+func (c *Client) RefreshTokenLoop(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(c.Cfg.TokenRefreshInterval):
+			otCtx, cancel := c.WithTimeout(ctx)
+			_, err := c.EtcdClient().UserGet(otCtx, c.Cfg.Username)
+			cancel()
+			if err != nil {
+				log.GetLogger().Error(fmt.Sprintf("refresh the token of user %s failed, error: %s",
+					c.Cfg.Username, err))
+			}
+		}
+	}
+}
+
+func (c *Client) EnableAuth(ctx context.Context) error {
+	otCtx, cancel := c.WithTimeout(ctx)
+	defer cancel()
+	if _, err := c.EtcdClient().AuthEnable(otCtx); err != nil {
+		return err
+	}
+	log.GetLogger().Warn("etcd auth enabled")
+	return nil
+}
+
+func (c *Client) DisableAuth(ctx context.Context) error {
+	otCtx, cancel := c.WithTimeout(ctx)
+	defer cancel()
+	if _, err := c.EtcdClient().AuthDisable(otCtx); err != nil {
+		return err
+	}
+	log.GetLogger().Warn("etcd auth disabled")
+	return nil
+}
+
+func (c *Client) AddUser(ctx context.Context, name, password string) error {
+	otCtx, cancel := c.WithTimeout(ctx)
+	defer cancel()
+	if _, err := c.EtcdClient().UserAdd(otCtx, name, password); err != nil {
+		return err
+	}
+	log.GetLogger().Info(fmt.Sprintf("etcd user %s added", name))
+	return nil
+}
+
+func (c *Client) ChangePassword(ctx context.Context, name, password string) error {
+	otCtx, cancel := c.WithTimeout(ctx)
+	defer cancel()
+	if _, err := c.EtcdClient().UserChangePassword(otCtx, name, password); err != nil {
+		return err
+	}
+	log.GetLogger().Info(fmt.Sprintf("etcd user %s password changed", name))
+	return nil
+}
+
+func (c *Client) DeleteUser(ctx context.Context, name string) error {
+	otCtx, cancel := c.WithTimeout(ctx)
+	defer cancel()
+	if _, err := c.EtcdClient().UserDelete(otCtx, name); err != nil {
+		return err
+	}
+	log.GetLogger().Info(fmt.Sprintf("etcd user %s deleted", name))
+	return nil
+}
+
+func (c *Client) AddRole(ctx context.Context, name string) error {
+	otCtx, cancel := c.WithTimeout(ctx)
+	defer cancel()
+	if _, err := c.EtcdClient().RoleAdd(otCtx, name); err != nil {
+		return err
+	}
+	log.GetLogger().Info(fmt.Sprintf("etcd role %s added", name))
+	return nil
+}
+
+func (c *Client) DeleteRole(ctx context.Context, name string) error {
+	otCtx, cancel := c.WithTimeout(ctx)
+	defer cancel()
+	if _, err := c.EtcdClient().RoleDelete(otCtx, name); err != nil {
+		return err
+	}
+	log.GetLogger().Info(fmt.Sprintf("etcd role %s deleted", name))
+	return nil
+}
+
+func (c *Client) GrantRole(ctx context.Context, user, role string) error {
+	otCtx, cancel := c.WithTimeout(ctx)
+	defer cancel()
+	if _, err := c.EtcdClient().UserGrantRole(otCtx, user, role); err != nil {
+		return err
+	}
+	log.GetLogger().Info(fmt.Sprintf("etcd role %s granted to user %s", role, user))
+	return nil
+}
+
+func (c *Client) GrantPermission(ctx context.Context, role, prefix string, perm etcdadpt.Permission) error {
+	otCtx, cancel := c.WithTimeout(ctx)
+	defer cancel()
+	_, err := c.EtcdClient().RoleGrantPermission(otCtx, role, prefix, clientv3.GetPrefixRangeEnd(prefix),
+		clientv3.PermissionType(perm))
+	if err != nil {
+		return err
+	}
+	log.GetLogger().Info(fmt.Sprintf("etcd role %s granted %s permission of prefix %s", role, perm, prefix))
+	return nil
+}
+
+// toPermissionError wraps the permission denied error with the actions and keys of the operations,
+// the values are excluded
+func toPermissionError(err error, ops ...etcdadpt.OpOptions) error {
+	if !errors.Is(err, rpctypes.ErrPermissionDenied) {
+		return err
+	}
+	targets := make([]string, 0, len(ops))
+	for _, op := range ops {
+		targets = append(targets, op.Action.String()+" "+string(op.Key))
+	}
+	return fmt.Errorf("%w, %s", etcdadpt.ErrPermissionDenied, strings.Join(targets, ", "))
+}
@@ -0,0 +1,162 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package remote_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/little-cui/etcdadpt"
+	"github.com/little-cui/etcdadpt/remote"
+	"github.com/stretchr/testify/assert"
+	"go.etcd.io/etcd/server/v3/embed"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// startAuthEtcd starts etcd and returns the client url and the observed server logs
+func startAuthEtcd(t *testing.T) (string, *observer.ObservedLogs) {
+	clientURL, _ := url.Parse("http://127.0.0.1:36379")
+	peerURL, _ := url.Parse("http://127.0.0.1:36380")
+	cfg := embed.NewConfig()
+	cfg.Dir = t.TempDir()
+	cfg.LCUrls, cfg.ACUrls = []url.URL{*clientURL}, []url.URL{*clientURL}
+	cfg.LPUrls, cfg.APUrls = []url.URL{*peerURL}, []url.URL{*peerURL}
+	cfg.InitialCluster = cfg.InitialClusterFromName(cfg.Name)
+	core, logs := observer.New(zap.DebugLevel)
+	cfg.ZapLoggerBuilder = embed.NewZapLoggerBuilder(zap.New(core))
+	// expire the simple token quickly
+	cfg.AuthTokenTTL = 1
+
+	e, err := embed.StartEtcd(cfg)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(e.Close)
+	select {
+	case <-e.Server.ReadyNotify():
+	case <-time.After(10 * time.Second):
+		t.Fatal("start etcd timed out")
+	}
+	return clientURL.String(), logs
+}
+
+func newAuthClient(t *testing.T, ep, user, password string, refreshInterval time.Duration) (etcdadpt.Client, error) {
+	var cfg etcdadpt.Config
+	cfg.Kind = "etcd"
+	cfg.ClusterAddresses = ep
+	cfg.DialTimeout = dialTimeout
+	cfg.RequestTimeOut = requestTimeout
+	cfg.Username = user
+	cfg.Password = password
+	cfg.TokenRefreshInterval = refreshInterval
+	cfg.Init()
+	return etcdadpt.NewInstance(cfg)
+}
+
+func TestEtcdClient_Auth(t *testing.T) {
+	ctx := context.Background()
+	ep, logs := startAuthEtcd(t)
+
+	root, err := newAuthClient(t, ep, "", "", 0)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer root.Close()
+	var am etcdadpt.AuthManager = etcdadpt.Unwrap(root).(*remote.Client)
+	assert.NoError(t, am.AddUser(ctx, "root", "root"))
+	assert.NoError(t, am.GrantRole(ctx, "root", "root"))
+	assert.NoError(t, etcdadpt.ProvisionPrefixUser(ctx, am, "app", "old", "/app/", etcdadpt.PermReadWrite))
+	// provision again should reuse the role and change the password
+	assert.NoError(t, etcdadpt.ProvisionPrefixUser(ctx, am, "app", "app", "/app/", etcdadpt.PermReadWrite))
+	assert.NoError(t, am.EnableAuth(ctx))
+
+	t.Run("connect with wrong password should return auth failed", func(t *testing.T) {
+		_, err := newAuthClient(t, ep, "app", "wrong", 0)
+		assert.Equal(t, etcdadpt.ErrAuthFailed, err)
+		_, err = newAuthClient(t, ep, "app", "old", 0)
+		assert.Equal(t, etcdadpt.ErrAuthFailed, err)
+	})
+
+	app, err := newAuthClient(t, ep, "app", "app", 500*time.Millisecond)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer app.Close()
+
+	t.Run("access the keys with the prefix should be ok", func(t *testing.T) {
+		_, err := app.Do(ctx, etcdadpt.PUT, etcdadpt.WithStrKey("/app/a"), etcdadpt.WithStrValue("a"))
+		assert.NoError(t, err)
+		resp, err := app.Do(ctx, etcdadpt.GET, etcdadpt.WithStrKey("/app/"), etcdadpt.WithPrefix())
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), resp.Count)
+	})
+
+	t.Run("access the keys without permission should return permission denied", func(t *testing.T) {
+		_, err := app.Do(ctx, etcdadpt.PUT, etcdadpt.WithStrKey("/other/a"), etcdadpt.WithStrValue("secret"))
+		assert.True(t, errors.Is(err, etcdadpt.ErrPermissionDenied), err)
+		assert.Contains(t, err.Error(), "PUT /other/a")
+		assert.NotContains(t, err.Error(), "secret")
+
+		_, err = app.TxnWithCmp(ctx, etcdadpt.Ops(etcdadpt.OpPut(etcdadpt.WithStrKey("/other/a"),
+			etcdadpt.WithStrValue("a"))), nil, nil)
+		assert.True(t, errors.Is(err, etcdadpt.ErrPermissionDenied), err)
+	})
+
+	t.Run("access after the token refreshed should be ok", func(t *testing.T) {
+		time.Sleep(2 * time.Second)
+		_, err := app.Do(ctx, etcdadpt.GET, etcdadpt.WithStrKey("/app/a"))
+		assert.NoError(t, err)
+	})
+
+	t.Run("access after the token expired should re-authenticate", func(t *testing.T) {
+		noRefresh, err := newAuthClient(t, ep, "app", "app", 0)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer noRefresh.Close()
+		_, err = noRefresh.Do(ctx, etcdadpt.GET, etcdadpt.WithStrKey("/app/a"))
+		assert.NoError(t, err)
+
+		userLogs := func(msg string) int {
+			return logs.FilterMessage(msg).FilterField(zap.String("user-name", "app")).Len()
+		}
+		authenticated, expired := userLogs("authenticated a user"), userLogs("deleted a simple token")
+		assert.Eventually(t, func() bool {
+			return userLogs("deleted a simple token") > expired
+		}, 5*time.Second, 100*time.Millisecond)
+
+		_, err = noRefresh.Do(ctx, etcdadpt.GET, etcdadpt.WithStrKey("/app/a"))
+		assert.NoError(t, err)
+		assert.Greater(t, userLogs("authenticated a user"), authenticated)
+	})
+
+	t.Run("the user without root role should not manage auth", func(t *testing.T) {
+		err := etcdadpt.Unwrap(app).(etcdadpt.AuthManager).AddUser(ctx, "other", "other")
+		assert.Error(t, err)
+	})
+
+	rootAuth, err := newAuthClient(t, ep, "root", "root", 0)
+	if assert.NoError(t, err) {
+		defer rootAuth.Close()
+		assert.NoError(t, etcdadpt.Unwrap(rootAuth).(etcdadpt.AuthManager).DisableAuth(ctx))
+	}
+}
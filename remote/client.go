@@ -19,13 +19,17 @@ package remote
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"net/url"
-	"strings"
+	"sync"
 	"time"
 
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
 	clientv3 "go.etcd.io/etcd/client/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
 
 	"github.com/go-chassis/foundation/gopool"
 	"github.com/little-cui/etcdadpt"
@@ -35,12 +39,15 @@ import (
 
 var FirstEndpoint string
 
+var ErrClientClosed = errors.New("etcd client is closed")
+
 func init() {
 	etcdadpt.Install("etcd", NewClient)
 }
 
 type Client struct {
-	Cfg    etcdadpt.Config
+	Cfg etcdadpt.Config
+	// Client the etcd client, it is replaced by ReOpen, use EtcdClient to get it concurrently
 	Client *clientv3.Client
 
 	Endpoints        []string
@@ -50,22 +57,51 @@ type Client struct {
 	err       chan error
 	ready     chan struct{}
 	goroutine *gopool.Pool
+	resolver  etcdadpt.Resolver
+
+	tlsFingerprint string
+
+	// mux guards Client and Cfg.TLSConfig replaced by ReOpen, Endpoints
+	// refreshed by RefreshEndpoints, and closed set by Close
+	mux    sync.RWMutex
+	closed bool
+	// watches the open watches of the etcd clients
+	watches map[*clientv3.Client]*sync.WaitGroup
+
+	syncMux     sync.RWMutex
+	lastSync    time.Time
+	lastSyncErr error
 }
 
 func (c *Client) WithTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
 	return context.WithTimeout(ctx, c.Cfg.RequestTimeOut)
 }
 
+func (c *Client) reporter() metrics.Reporter {
+	return metrics.Reporter{Instance: c.Cfg.MetricsInstance}
+}
+
 func (c *Client) Initialize() (err error) {
 	c.err = make(chan error, 1)
 	c.ready = make(chan struct{})
 	c.goroutine = gopool.New(gopool.Configure().WithRecoverFunc(c.logRecover))
 
 	if len(c.Endpoints) == 0 {
-		// parse the endpoints from config
-		c.parseEndpoints()
+		// resolve the endpoints from config
+		if err = c.parseEndpoints(); err != nil {
+			log.GetLogger().Error(fmt.Sprintf("resolve etcd endpoints failed, error: %s", err))
+			c.onError(err)
+			return
+		}
 	}
 
+	if c.Cfg.SslEnabled && c.hasTLSProvider() {
+		c.Cfg.TLSConfig, c.tlsFingerprint, err = c.loadTLSConfig()
+		if err != nil {
+			log.GetLogger().Error(fmt.Sprintf("load TLS config failed, error: %s", err))
+			return err
+		}
+	}
 	if c.Cfg.TLSConfig == nil && c.Cfg.SslEnabled {
 		return errors.New("required TLSConfig")
 	}
@@ -76,7 +112,7 @@ func (c *Client) Initialize() (err error) {
 		c.AutoSyncInterval = c.Cfg.AutoSyncInterval
 	}
 
-	c.Client, err = c.newClient()
+	c.Client, err = c.newClient(c.Cfg.TLSConfig)
 	if err != nil {
 		log.GetLogger().Error(fmt.Sprintf("get etcd client %v failed. error: %s", c.Endpoints, err))
 		c.onError(err)
@@ -85,6 +121,8 @@ func (c *Client) Initialize() (err error) {
 	c.onConnected()
 
 	c.HealthCheck()
+	c.RefreshToken()
+	c.ReloadTLS()
 
 	close(c.ready)
 
@@ -93,16 +131,56 @@ func (c *Client) Initialize() (err error) {
 	return
 }
 
-func (c *Client) newClient() (*clientv3.Client, error) {
+// EtcdClient returns the current etcd client
+func (c *Client) EtcdClient() *clientv3.Client {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	return c.Client
+}
+
+// watchClient returns the current etcd client and counts the watch on it,
+// done should be called when the watch finished
+func (c *Client) watchClient() (*clientv3.Client, func()) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.watches == nil {
+		c.watches = make(map[*clientv3.Client]*sync.WaitGroup)
+	}
+	wg, ok := c.watches[c.Client]
+	if !ok {
+		wg = &sync.WaitGroup{}
+		c.watches[c.Client] = wg
+	}
+	wg.Add(1)
+	return c.Client, wg.Done
+}
+
+func (c *Client) endpoints() []string {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	return c.Endpoints
+}
+
+func (c *Client) tlsConfig() *tls.Config {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	return c.Cfg.TLSConfig
+}
+
+func (c *Client) newClient(tlsCfg *tls.Config) (*clientv3.Client, error) {
+	endpoints := c.endpoints()
 	inst, err := clientv3.New(clientv3.Config{
-		Endpoints:            c.Endpoints,
+		Endpoints:            endpoints,
 		DialTimeout:          c.DialTimeout,
-		TLS:                  c.Cfg.TLSConfig,
+		TLS:                  tlsCfg,
+		Username:             c.Cfg.Username,
+		Password:             c.Cfg.Password,
 		MaxCallSendMsgSize:   maxSendMsgSize,
 		MaxCallRecvMsgSize:   maxRecvMsgSize,
 		DialKeepAliveTime:    keepAliveTime,
 		DialKeepAliveTimeout: keepAliveTimeout,
 		AutoSyncInterval:     0, // DON'T start auto sync, duplicate with c.HealthCheck()
+		DialOptions:          []grpc.DialOption{grpc.WithChainUnaryInterceptor(c.recordEndpoint)},
 	})
 	defer func() {
 		if err == nil {
@@ -114,6 +192,9 @@ func (c *Client) newClient() (*clientv3.Client, error) {
 	}()
 
 	if err != nil {
+		if err.Error() == rpctypes.ErrAuthFailed.Error() {
+			return nil, etcdadpt.ErrAuthFailed
+		}
 		return nil, err
 	}
 
@@ -124,15 +205,15 @@ func (c *Client) newClient() (*clientv3.Client, error) {
 		return nil, err
 	}
 
-	metrics.ReportBackendInstance(len(resp.Members))
+	c.reporter().ReportBackendInstance(len(resp.Members))
 
-	if len(c.Endpoints) == 1 {
-		// no need to check remote endpoints
+	if len(endpoints) == 1 || c.isDiscovery() {
+		// no need to check remote endpoints, the discovered endpoints may be domain names
 		return inst, nil
 	}
 
 epLoop:
-	for _, ep := range c.Endpoints {
+	for _, ep := range endpoints {
 		var cluster []string
 		for _, mem := range resp.Members {
 			for _, curl := range mem.ClientURLs {
@@ -155,37 +236,75 @@ epLoop:
 }
 
 func (c *Client) ReOpen() error {
-	client, cerr := c.newClient()
+	return c.reOpen(c.tlsConfig())
+}
+
+// reOpen replaces the etcd client with the new one using tlsCfg
+func (c *Client) reOpen(tlsCfg *tls.Config) error {
+	client, cerr := c.newClient(tlsCfg)
 	if cerr != nil {
 		log.GetLogger().Error(fmt.Sprintf("create a new connection to etcd %v failed, error: %s",
-			c.Endpoints, cerr))
+			c.endpoints(), cerr))
 		return cerr
 	}
-	c.Client, client = client, c.Client
-	if cerr = client.Close(); cerr != nil {
-		log.GetLogger().Error(fmt.Sprintf("failed to close the unavailable etcd client, error: %s", cerr))
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.closed {
+		client.Close()
+		return ErrClientClosed
 	}
-	client = nil
+	c.Client, client = client, c.Client
+	c.Cfg.TLSConfig = tlsCfg
+	watches := c.watches[client]
+	delete(c.watches, client)
+
+	// close the old client after the in-flight requests and the watches done,
+	// it is handed to the pool under the lock, for Close closes the pool after
+	// the closed flag is set
+	c.goroutine.Do(func(ctx context.Context) {
+		select {
+		case <-ctx.Done():
+		case <-time.After(c.Cfg.RequestTimeOut):
+		}
+		if watches != nil {
+			waitWatches(ctx, watches)
+		}
+		if err := client.Close(); err != nil {
+			log.GetLogger().Error(fmt.Sprintf("failed to close the unavailable etcd client, error: %s", err))
+		}
+	})
 	return nil
 }
 
-func (c *Client) parseEndpoints() {
-	// use the default cluster endpoints
-	addrs := etcdadpt.GetClusterURL(c.Cfg.ClusterName, c.Cfg.ClusterAddresses, "")
+// waitWatches waits for the watches done unless ctx is done
+func waitWatches(ctx context.Context, watches *sync.WaitGroup) {
+	done := make(chan struct{})
+	go func() {
+		watches.Wait()
+		close(done)
+	}()
+	select {
+	case <-ctx.Done():
+	case <-done:
+	}
+}
+
+func (c *Client) parseEndpoints() error {
+	c.resolver = c.newResolver()
 
-	endpoints := make([]string, 0, len(addrs))
-	for _, addr := range addrs {
-		if strings.Index(addr, "://") > 0 {
-			// 如果配置格式为"sr-0=http(s)://IP:Port"，则需要分离IP:Port部分
-			endpoints = append(endpoints, addr[strings.Index(addr, "://")+3:])
-		} else {
-			endpoints = append(endpoints, addr)
-		}
+	ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+	defer cancel()
+	if err := c.resolveEndpoints(ctx); err != nil {
+		return err
 	}
-	c.Endpoints = endpoints
 
+	if c.isDiscovery() {
+		log.GetLogger().Info(fmt.Sprintf("resolve endpoints: %v, ssl: %v", c.Endpoints, c.Cfg.SslEnabled))
+		return nil
+	}
 	log.GetLogger().Info(fmt.Sprintf("parse %s -> endpoints: %v, ssl: %v",
 		c.Cfg.ClusterAddresses, c.Endpoints, c.Cfg.SslEnabled))
+	return nil
 }
 
 func (c *Client) onError(err error) {
@@ -213,11 +332,34 @@ func NewClient(cfg etcdadpt.Config) etcdadpt.Client {
 		return inst
 	}
 
-	scheme := "http://"
-	if cfg.TLSConfig != nil {
-		scheme = "https://"
-	}
-	FirstEndpoint = scheme + inst.Endpoints[0]
+	FirstEndpoint = inst.Endpoint()
 
 	return inst
 }
+
+// Endpoint returns the first endpoint with scheme
+func (c *Client) Endpoint() string {
+	endpoints := c.endpoints()
+	if len(endpoints) == 0 {
+		return ""
+	}
+	return c.scheme() + endpoints[0]
+}
+
+func (c *Client) scheme() string {
+	if c.tlsConfig() != nil {
+		return "https://"
+	}
+	return "http://"
+}
+
+// recordEndpoint records the endpoint actually serving the request by etcdadpt.SetRequestEndpoint
+func (c *Client) recordEndpoint(ctx context.Context, method string, req, reply interface{},
+	cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	var p peer.Peer
+	err := invoker(ctx, method, req, reply, cc, append(opts, grpc.Peer(&p))...)
+	if p.Addr != nil {
+		etcdadpt.SetRequestEndpoint(ctx, c.scheme()+p.Addr.String())
+	}
+	return err
+}
@@ -17,7 +17,9 @@
 package remote_test
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"fmt"
 	"strings"
 	"testing"
@@ -532,3 +534,48 @@ func TestEtcdClient_paging(t *testing.T) {
 		t.Fatalf("TestEtcdClient_paging failed")
 	}
 }
+
+func TestEtcdClient_Defragment(t *testing.T) {
+	var cfg etcdadpt.Config
+	cfg.ClusterAddresses = endpoint
+	cfg.DialTimeout = dialTimeout
+	cfg.RequestTimeOut = requestTimeout
+	cfg.DefragmentThreshold = 0.01
+
+	inst := remote.NewClient(cfg)
+	defer inst.Close()
+
+	for i := 0; i < 100; i++ {
+		_, err := inst.Do(context.Background(), etcdadpt.PUT,
+			etcdadpt.WithStrKey(fmt.Sprintf("/test_defrag/%d", i)), etcdadpt.WithStrValue(strings.Repeat("a", 1024)))
+		assert.NoError(t, err)
+	}
+	_, err := inst.Do(context.Background(), etcdadpt.DEL, etcdadpt.WithStrKey("/test_defrag/"), etcdadpt.WithPrefix())
+	assert.NoError(t, err)
+
+	before, err := inst.Status(context.Background())
+	assert.NoError(t, err)
+
+	err = inst.Compact(context.Background(), 0)
+	assert.NoError(t, err)
+
+	after, err := inst.Status(context.Background())
+	assert.NoError(t, err)
+	assert.Less(t, after.DBSize, before.DBSize)
+}
+
+func TestEtcdClient_Snapshot(t *testing.T) {
+	var cfg etcdadpt.Config
+	cfg.ClusterAddresses = endpoint
+	cfg.DialTimeout = dialTimeout
+	cfg.RequestTimeOut = requestTimeout
+
+	inst := remote.NewClient(cfg)
+	defer inst.Close()
+
+	var buf bytes.Buffer
+	err := inst.(etcdadpt.Snapshotter).Snapshot(context.Background(), &buf)
+	assert.NoError(t, err)
+	// the db and sha256 hash
+	assert.Greater(t, buf.Len(), sha256.Size)
+}
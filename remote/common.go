@@ -18,6 +18,8 @@ package remote
 import (
 	"math"
 	"time"
+
+	"github.com/little-cui/etcdadpt"
 )
 
 const (
@@ -38,14 +40,25 @@ const (
 )
 
 const (
-	OperationCompact     = "COMPACT"
-	OperationTxn         = "TXN"
-	OperationLeaseGrant  = "LEASE_GRANT"
-	OperationLeaseRenew  = "LEASE_RENEW"
-	OperationLeaseRevoke = "LEASE_REVOKE"
-	OperationSyncMembers = "SYNC"
+	OperationCompact       = etcdadpt.OperationCompact
+	OperationDefragment    = etcdadpt.OperationDefragment
+	OperationSnapshot      = etcdadpt.OperationSnapshot
+	OperationTxn           = etcdadpt.OperationTxn
+	OperationLeaseGrant    = etcdadpt.OperationLeaseGrant
+	OperationLeaseRenew    = etcdadpt.OperationLeaseRenew
+	OperationLeaseRevoke   = etcdadpt.OperationLeaseRevoke
+	OperationLeaseTTL      = etcdadpt.OperationLeaseTTL
+	OperationSyncMembers   = "SYNC"
+	OperationMemberList    = etcdadpt.OperationMemberList
+	OperationMemberAdd     = etcdadpt.OperationMemberAdd
+	OperationMemberRemove  = etcdadpt.OperationMemberRemove
+	OperationMemberPromote = etcdadpt.OperationMemberPromote
+	OperationMemberUpdate  = etcdadpt.OperationMemberUpdate
 )
 
+// SpanNamePage the span name of the page requested by LargeRequestPaging
+const SpanNamePage = "etcd:page"
+
 func max(n1, n2 int64) int64 {
 	if n1 > n2 {
 		return n1
@@ -23,9 +23,9 @@ import (
 	"time"
 
 	"github.com/go-chassis/foundation/backoff"
-
+	"github.com/go-chassis/openlog"
+	"github.com/little-cui/etcdadpt"
 	"github.com/little-cui/etcdadpt/middleware/log"
-	"github.com/little-cui/etcdadpt/middleware/metrics"
 )
 
 func (c *Client) HealthCheck() {
@@ -43,6 +43,7 @@ func (c *Client) HealthCheckLoop(ctx context.Context) {
 			return
 		case <-time.After(c.AutoSyncInterval):
 			err := c.autoSync(ctx)
+			c.setSyncResult(err)
 			if err == nil && lastErr != nil {
 				c.onConnected()
 			} else if err != nil && lastErr == nil {
@@ -55,23 +56,27 @@ func (c *Client) HealthCheckLoop(ctx context.Context) {
 			continue
 		}
 
-		log.GetLogger().Error(fmt.Sprintf("etcd health check failed, error: %s", lastErr))
+		healthLogger.Error(fmt.Sprintf("etcd %v health check failed, error: %s", c.endpoints(), lastErr),
+			c.endpointTags(), openlog.WithErr(lastErr))
 		if err := c.ReOpen(); err != nil {
-			log.GetLogger().Error(fmt.Sprintf("re-connect to etcd failed, error: %s", err))
+			healthLogger.Error(fmt.Sprintf("re-connect to etcd %v failed, error: %s", c.endpoints(), err),
+				c.endpointTags(), openlog.WithErr(err))
 		}
 	}
 }
 
 func (c *Client) autoSync(ctx context.Context) (err error) {
 	for i := 0; i < healthCheckRetryTimes; i++ {
-		subCtx, cancel := context.WithTimeout(c.Client.Ctx(), healthCheckTimeout)
+		subCtx, cancel := context.WithTimeout(c.EtcdClient().Ctx(), healthCheckTimeout)
 		err = c.SyncMembers(subCtx)
 		cancel()
 		if err == nil {
 			return
 		}
 		d := backoff.GetBackoff().Delay(i)
-		log.GetLogger().Error(fmt.Sprintf("retry to sync members from etcd %s after %s, error: %s", c.Endpoints, d, err))
+		tags := openlog.Tags{log.FieldEndpoint: c.endpoints(), "delay": d.String()}
+		healthLogger.Error(fmt.Sprintf("retry to sync members from etcd %v after %s, error: %s", c.endpoints(), d, err),
+			openlog.WithTags(tags), openlog.WithErr(err))
 		select {
 		case <-ctx.Done():
 			return nil
@@ -85,10 +90,46 @@ func (c *Client) SyncMembers(ctx context.Context) error {
 	var err error
 
 	start := time.Now()
-	defer metrics.ReportBackendOperationCompleted(OperationSyncMembers, err, start)
+	defer c.reporter().ReportBackendOperationCompleted(OperationSyncMembers, err, start)
 
-	if err = c.Client.Sync(ctx); err != nil && err != c.Client.Ctx().Err() {
+	if c.isDiscovery() {
+		if rErr := c.RefreshEndpoints(ctx); rErr != nil {
+			// keep the current endpoints
+			healthLogger.Error(fmt.Sprintf("refresh etcd endpoints failed, error: %s", rErr),
+				c.endpointTags(), openlog.WithErr(rErr))
+		}
+		// the client urls of members from Sync would replace the resolved endpoints,
+		// so only list the members to probe the refreshed endpoints
+		client := c.EtcdClient()
+		if _, err = client.MemberList(ctx); err != nil && err != client.Ctx().Err() {
+			return err
+		}
+		return nil
+	}
+	client := c.EtcdClient()
+	if err = client.Sync(ctx); err != nil && err != client.Ctx().Err() {
 		return err
 	}
 	return nil
 }
+
+func (c *Client) setSyncResult(err error) {
+	c.syncMux.Lock()
+	c.lastSync, c.lastSyncErr = time.Now(), err
+	c.syncMux.Unlock()
+}
+
+// Health returns the endpoints and the result of the last members sync,
+// the client is not live if it is closed
+func (c *Client) Health() etcdadpt.Health {
+	c.syncMux.RLock()
+	health := etcdadpt.Health{LastSync: c.lastSync, LastSyncError: c.lastSyncErr}
+	c.syncMux.RUnlock()
+	client := c.EtcdClient()
+	if client == nil {
+		return health
+	}
+	health.Live = client.Ctx().Err() == nil
+	health.Endpoints = client.Endpoints()
+	return health
+}
@@ -23,8 +23,10 @@ import (
 	"time"
 
 	"github.com/go-chassis/foundation/stringutil"
+	"github.com/go-chassis/openlog"
 	"github.com/little-cui/etcdadpt"
-	"github.com/little-cui/etcdadpt/middleware/metrics"
+	"github.com/little-cui/etcdadpt/middleware/log"
+	"github.com/little-cui/etcdadpt/middleware/tracing"
 	"go.etcd.io/etcd/api/v3/mvccpb"
 	clientv3 "go.etcd.io/etcd/client/v3"
 )
@@ -35,15 +37,25 @@ func (c *Client) Do(ctx context.Context, opts ...etcdadpt.OpOption) (*etcdadpt.R
 		resp *etcdadpt.Response
 	)
 
-	start := time.Now()
 	op := etcdadpt.OptionsToOp(opts...)
-	span := TracingBegin(ctx, "etcd:do", op)
+	err = c.withRetry(ctx, op.Action.String(), []etcdadpt.OpOptions{op}, func() (rerr error) {
+		resp, rerr = c.do(ctx, op)
+		return
+	})
+	if err != nil {
+		return nil, toPermissionError(err, op)
+	}
+	return resp, nil
+}
+
+func (c *Client) do(ctx context.Context, op etcdadpt.OpOptions) (*etcdadpt.Response, error) {
+	var (
+		err  error
+		resp *etcdadpt.Response
+	)
+
 	otCtx, cancel := c.WithTimeout(ctx)
-	defer func() {
-		metrics.ReportBackendOperationCompleted(op.Action.String(), err, start)
-		TracingEnd(span, err)
-		cancel()
-	}()
+	defer cancel()
 
 	switch op.Action {
 	case etcdadpt.ActionGet:
@@ -58,7 +70,7 @@ func (c *Client) Do(ctx context.Context, opts ...etcdadpt.OpOption) (*etcdadpt.R
 		}
 
 		if etcdResp == nil {
-			etcdResp, err = c.Client.Get(otCtx, key, c.toGetRequest(op)...)
+			etcdResp, err = c.EtcdClient().Get(otCtx, key, c.toGetRequest(op)...)
 			if err != nil {
 				break
 			}
@@ -76,7 +88,7 @@ func (c *Client) Do(ctx context.Context, opts ...etcdadpt.OpOption) (*etcdadpt.R
 			value = stringutil.Bytes2str(op.Value)
 		}
 		var etcdResp *clientv3.PutResponse
-		etcdResp, err = c.Client.Put(otCtx, stringutil.Bytes2str(op.Key), value, c.toPutRequest(op)...)
+		etcdResp, err = c.EtcdClient().Put(otCtx, stringutil.Bytes2str(op.Key), value, c.toPutRequest(op)...)
 		if err != nil {
 			break
 		}
@@ -84,23 +96,25 @@ func (c *Client) Do(ctx context.Context, opts ...etcdadpt.OpOption) (*etcdadpt.R
 			Revision:  etcdResp.Header.Revision,
 			Succeeded: true,
 		}
+		if etcdResp.PrevKv != nil {
+			resp.PrevKvs = []*mvccpb.KeyValue{etcdResp.PrevKv}
+		}
 	case etcdadpt.ActionDelete:
 		var etcdResp *clientv3.DeleteResponse
-		etcdResp, err = c.Client.Delete(otCtx, stringutil.Bytes2str(op.Key), c.toDeleteRequest(op)...)
+		etcdResp, err = c.EtcdClient().Delete(otCtx, stringutil.Bytes2str(op.Key), c.toDeleteRequest(op)...)
 		if err != nil {
 			break
 		}
 		resp = &etcdadpt.Response{
 			Revision:  etcdResp.Header.Revision,
 			Succeeded: etcdResp.Deleted > 0,
+			PrevKvs:   etcdResp.PrevKvs,
 		}
 	}
 
 	if err != nil {
 		return nil, err
 	}
-
-	c.logNilOrWarn(start, fmt.Sprintf("registry client do %s", op))
 	return resp, nil
 }
 
@@ -109,7 +123,7 @@ func (c *Client) LargeRequestPaging(ctx context.Context, op etcdadpt.OpOptions)
 	key := stringutil.Bytes2str(op.Key)
 	start := time.Now()
 
-	countResp, err := c.Client.Get(ctx, key, append(c.toGetRequest(op), clientv3.WithCountOnly())...)
+	countResp, err := c.EtcdClient().Get(ctx, key, append(c.toGetRequest(op), clientv3.WithCountOnly())...)
 	if err != nil {
 		return nil, err
 	}
@@ -142,7 +156,7 @@ func (c *Client) LargeRequestPaging(ctx context.Context, op etcdadpt.OpOptions)
 			// for the performance, just get the list without values
 			ops = append(ops, clientv3.WithKeysOnly())
 		}
-		recordResp, err := c.Client.Get(ctx, nextKey, ops...)
+		recordResp, err := c.getPage(ctx, nextKey, ops...)
 		if err != nil {
 			return nil, err
 		}
@@ -171,7 +185,13 @@ func (c *Client) LargeRequestPaging(ctx context.Context, op etcdadpt.OpOptions)
 
 	if offset < 0 {
 		c.logInfoOrWarn(start, fmt.Sprintf("get too many KeyValues(%s) from etcd, now paging.(%d vs %d)",
-			key, recordCount, pageSize))
+			key, recordCount, pageSize), openlog.Tags{
+			log.FieldOperation: etcdadpt.ActionGet.String(),
+			log.FieldKey:       key,
+			log.FieldRevision:  countResp.Header.Revision,
+			"count":            recordCount,
+			"pageSize":         pageSize,
+		})
 	}
 
 	// too slow
@@ -181,6 +201,23 @@ func (c *Client) LargeRequestPaging(ctx context.Context, op etcdadpt.OpOptions)
 	return etcdResp, nil
 }
 
+// getPage gets a page of LargeRequestPaging, traced as a child span of the paging request
+func (c *Client) getPage(ctx context.Context, key string, ops ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+	c.reporter().ReportBackendPagingPage()
+	ctx, end := tracing.Start(ctx, SpanNamePage, &tracing.Request{
+		Options:   etcdadpt.OpGet(etcdadpt.WithStrKey(key)),
+		Operation: etcdadpt.ActionGet.String(),
+		OpCount:   1,
+	})
+	resp, err := c.EtcdClient().Get(ctx, key, ops...)
+	result := &tracing.Result{Action: etcdadpt.ActionGet, Succeeded: err == nil, Err: err}
+	if resp != nil {
+		result.Count, result.Revision = int64(len(resp.Kvs)), resp.Header.Revision
+	}
+	end(result)
+	return resp, err
+}
+
 func (c *Client) reverseResult(etcdResp *clientv3.GetResponse, recordCount int64, key string) {
 	t := time.Now()
 	for i, l := 0, len(etcdResp.Kvs); i < l; i++ {
@@ -190,7 +227,11 @@ func (c *Client) reverseResult(etcdResp *clientv3.GetResponse, recordCount int64
 		}
 		etcdResp.Kvs[i], etcdResp.Kvs[last] = etcdResp.Kvs[last], etcdResp.Kvs[i]
 	}
-	c.logNilOrWarn(t, fmt.Sprintf("sorted descend %d KeyValues(%s)", recordCount, key))
+	c.logNilOrWarn(t, fmt.Sprintf("sorted descend %d KeyValues(%s)", recordCount, key), openlog.Tags{
+		log.FieldOperation: etcdadpt.ActionGet.String(),
+		log.FieldKey:       key,
+		"count":            recordCount,
+	})
 }
 
 func (c *Client) toPagingOps(op etcdadpt.OpOptions, key string, rev int64) []clientv3.OpOption {
@@ -202,7 +243,10 @@ func (c *Client) toPagingOps(op etcdadpt.OpOptions, key string, rev int64) []cli
 	if len(tempOp.EndKey) == 0 {
 		tempOp.EndKey = []byte(clientv3.GetPrefixRangeEnd(key))
 	}
-	tempOp.Revision = rev
+	if tempOp.Revision <= 0 {
+		// all pages should be at the same revision
+		tempOp.Revision = rev
+	}
 	baseOps = append(baseOps, c.toGetRequest(tempOp)...)
 	return baseOps
 }
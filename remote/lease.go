@@ -19,81 +19,70 @@ package remote
 
 import (
 	"context"
-	"fmt"
-	"strconv"
-	"time"
 
-	"github.com/go-chassis/foundation/stringutil"
 	"github.com/little-cui/etcdadpt"
-	"github.com/little-cui/etcdadpt/middleware/metrics"
 	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
 	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
 func (c *Client) LeaseGrant(ctx context.Context, TTL int64) (int64, error) {
-	var err error
-
-	start := time.Now()
-	span := TracingBegin(ctx, "etcd:grant",
-		etcdadpt.OpOptions{Action: etcdadpt.ActionPut, Key: stringutil.Str2bytes(strconv.FormatInt(TTL, 10))})
 	otCtx, cancel := c.WithTimeout(ctx)
-	defer func() {
-		metrics.ReportBackendOperationCompleted(OperationLeaseGrant, err, start)
-		TracingEnd(span, err)
-		cancel()
-	}()
-	etcdResp, err := c.Client.Grant(otCtx, TTL)
+	defer cancel()
+
+	etcdResp, err := c.EtcdClient().Grant(otCtx, TTL)
 	if err != nil {
 		return 0, err
 	}
-	c.logNilOrWarn(start, fmt.Sprintf("registry client grant lease %ds", TTL))
 	return int64(etcdResp.ID), nil
 }
 
 func (c *Client) LeaseRenew(ctx context.Context, leaseID int64) (int64, error) {
-	var err error
-
-	start := time.Now()
-	span := TracingBegin(ctx, "etcd:keepalive",
-		etcdadpt.OpOptions{Action: etcdadpt.ActionPut, Key: stringutil.Str2bytes(strconv.FormatInt(leaseID, 10))})
-	otCtx, cancel := c.WithTimeout(ctx)
-	defer func() {
-		metrics.ReportBackendOperationCompleted(OperationLeaseRenew, err, start)
-		TracingEnd(span, err)
-		cancel()
-	}()
-
-	etcdResp, err := c.Client.KeepAliveOnce(otCtx, clientv3.LeaseID(leaseID))
+	var etcdResp *clientv3.LeaseKeepAliveResponse
+	err := c.withRetry(ctx, OperationLeaseRenew, nil, func() (rerr error) {
+		otCtx, cancel := c.WithTimeout(ctx)
+		defer cancel()
+		etcdResp, rerr = c.EtcdClient().KeepAliveOnce(otCtx, clientv3.LeaseID(leaseID))
+		return
+	})
 	if err != nil {
 		if err.Error() == rpctypes.ErrLeaseNotFound.Error() {
 			return 0, etcdadpt.ErrLeaseNotFound
 		}
 		return 0, err
 	}
-	c.logNilOrWarn(start, fmt.Sprintf("registry client renew lease %d", leaseID))
 	return etcdResp.TTL, nil
 }
 
 func (c *Client) LeaseRevoke(ctx context.Context, leaseID int64) error {
-	var err error
-
-	start := time.Now()
-	span := TracingBegin(ctx, "etcd:revoke",
-		etcdadpt.OpOptions{Action: etcdadpt.ActionDelete, Key: stringutil.Str2bytes(strconv.FormatInt(leaseID, 10))})
-	otCtx, cancel := c.WithTimeout(ctx)
-	defer func() {
-		metrics.ReportBackendOperationCompleted(OperationLeaseRevoke, err, start)
-		TracingEnd(span, err)
-		cancel()
-	}()
-
-	_, err = c.Client.Revoke(otCtx, clientv3.LeaseID(leaseID))
+	err := c.withRetry(ctx, OperationLeaseRevoke, nil, func() error {
+		otCtx, cancel := c.WithTimeout(ctx)
+		defer cancel()
+		_, rerr := c.EtcdClient().Revoke(otCtx, clientv3.LeaseID(leaseID))
+		return rerr
+	})
 	if err != nil {
 		if err.Error() == rpctypes.ErrLeaseNotFound.Error() {
 			return etcdadpt.ErrLeaseNotFound
 		}
 		return err
 	}
-	c.logNilOrWarn(start, fmt.Sprintf("registry client revoke lease %d", leaseID))
 	return nil
 }
+
+func (c *Client) LeaseTimeToLive(ctx context.Context, leaseID int64) (int64, error) {
+	var etcdResp *clientv3.LeaseTimeToLiveResponse
+	err := c.withRetry(ctx, OperationLeaseTTL, nil, func() (rerr error) {
+		otCtx, cancel := c.WithTimeout(ctx)
+		defer cancel()
+		etcdResp, rerr = c.EtcdClient().TimeToLive(otCtx, clientv3.LeaseID(leaseID))
+		return
+	})
+	if err != nil {
+		return 0, err
+	}
+	if etcdResp.TTL < 0 {
+		// etcd return TTL -1 if the lease expired or does not exist
+		return 0, etcdadpt.ErrLeaseNotFound
+	}
+	return etcdResp.TTL, nil
+}
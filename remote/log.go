@@ -21,26 +21,41 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/go-chassis/openlog"
 	"github.com/little-cui/etcdadpt/middleware/log"
 )
 
+var (
+	opLogger     = log.GetCategoryLogger(log.CategoryOperation)
+	healthLogger = log.GetCategoryLogger(log.CategoryHealth)
+	retryLogger  = log.GetCategoryLogger(log.CategoryRetry)
+)
+
 func (c *Client) logRecover(r interface{}) {
 	log.GetLogger().Error(fmt.Sprintf("embedded etcd recover: %v", r))
 }
 
-func (c *Client) logInfoOrWarn(start time.Time, message string) {
+// logInfoOrWarn logs the operation with the cost field at info level, or warn level if it is slow
+func (c *Client) logInfoOrWarn(start time.Time, message string, tags openlog.Tags) {
 	cost := time.Since(start)
-	if cost < time.Second {
-		log.GetLogger().Info(fmt.Sprintf("[%s]%s", cost, message))
+	tags[log.FieldCost] = cost.String()
+	if cost < log.SlowThreshold() {
+		opLogger.Info(message, openlog.WithTags(tags))
 		return
 	}
-	log.GetLogger().Warn(fmt.Sprintf("[%s]%s", cost, message))
+	opLogger.Warn(message, openlog.WithTags(tags))
 }
 
-func (c *Client) logNilOrWarn(start time.Time, message string) {
+// logNilOrWarn logs the operation with the cost field at warn level only if it is slow
+func (c *Client) logNilOrWarn(start time.Time, message string, tags openlog.Tags) {
 	cost := time.Since(start)
-	if cost < time.Second {
+	if cost < log.SlowThreshold() {
 		return
 	}
-	log.GetLogger().Warn(fmt.Sprintf("[%s]%s", cost, message))
+	tags[log.FieldCost] = cost.String()
+	opLogger.Warn(message, openlog.WithTags(tags))
+}
+
+func (c *Client) endpointTags() openlog.Option {
+	return openlog.WithTags(openlog.Tags{log.FieldEndpoint: c.endpoints()})
 }
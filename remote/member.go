@@ -0,0 +1,129 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package remote
+
+import (
+	"context"
+
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+
+	"github.com/little-cui/etcdadpt"
+)
+
+func (c *Client) ListMember(ctx context.Context) ([]*etcdadpt.Member, error) {
+	otCtx, cancel := c.WithTimeout(ctx)
+	defer cancel()
+
+	resp, err := c.EtcdClient().MemberList(otCtx)
+	if err != nil {
+		return nil, err
+	}
+	members := make([]*etcdadpt.Member, 0, len(resp.Members))
+	for _, m := range resp.Members {
+		members = append(members, toMember(m))
+	}
+	c.checkMembersHealth(otCtx, members)
+	return members, nil
+}
+
+// checkMembersHealth sets the member healthy if any of its client
+// endpoints responds the status request in time
+func (c *Client) checkMembersHealth(ctx context.Context, members []*etcdadpt.Member) {
+	etcdadpt.CheckMembersHealth(ctx, members, func(member *etcdadpt.Member) []string {
+		return member.ClientURLs
+	}, func(ctx context.Context, ep string) error {
+		_, err := c.EtcdClient().Status(ctx, ep)
+		return err
+	})
+}
+
+func (c *Client) AddMember(ctx context.Context, peerURLs []string, isLearner bool) (*etcdadpt.Member, error) {
+	otCtx, cancel := c.WithTimeout(ctx)
+	defer cancel()
+
+	var (
+		err    error
+		member *etcdserverpb.Member
+	)
+	if isLearner {
+		resp, lErr := c.EtcdClient().MemberAddAsLearner(otCtx, peerURLs)
+		if err = lErr; err == nil {
+			member = resp.Member
+		}
+	} else {
+		resp, vErr := c.EtcdClient().MemberAdd(otCtx, peerURLs)
+		if err = vErr; err == nil {
+			member = resp.Member
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return toMember(member), nil
+}
+
+func (c *Client) RemoveMember(ctx context.Context, id uint64) error {
+	otCtx, cancel := c.WithTimeout(ctx)
+	defer cancel()
+
+	_, err := c.EtcdClient().MemberRemove(otCtx, id)
+	if err != nil {
+		return toMemberError(err)
+	}
+	return nil
+}
+
+func (c *Client) PromoteMember(ctx context.Context, id uint64) error {
+	otCtx, cancel := c.WithTimeout(ctx)
+	defer cancel()
+
+	_, err := c.EtcdClient().MemberPromote(otCtx, id)
+	if err != nil {
+		return toMemberError(err)
+	}
+	return nil
+}
+
+func (c *Client) UpdateMember(ctx context.Context, id uint64, peerURLs []string) error {
+	otCtx, cancel := c.WithTimeout(ctx)
+	defer cancel()
+
+	_, err := c.EtcdClient().MemberUpdate(otCtx, id, peerURLs)
+	if err != nil {
+		return toMemberError(err)
+	}
+	return nil
+}
+
+func toMember(m *etcdserverpb.Member) *etcdadpt.Member {
+	return &etcdadpt.Member{
+		ID:         m.ID,
+		Name:       m.Name,
+		PeerURLs:   m.PeerURLs,
+		ClientURLs: m.ClientURLs,
+		IsLearner:  m.IsLearner,
+	}
+}
+
+func toMemberError(err error) error {
+	if err.Error() == rpctypes.ErrMemberNotFound.Error() {
+		return etcdadpt.ErrMemberNotFound
+	}
+	return err
+}
@@ -21,19 +21,20 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"time"
 
 	clientv3 "go.etcd.io/etcd/client/v3"
 
+	"github.com/go-chassis/openlog"
 	"github.com/little-cui/etcdadpt"
 	"github.com/little-cui/etcdadpt/middleware/log"
-	"github.com/little-cui/etcdadpt/middleware/metrics"
 )
 
 var ErrGetLeaderFailed = errors.New("get leader failed")
 
 func (c *Client) Compact(ctx context.Context, reserve int64) error {
-	eps := c.Client.Endpoints()
+	eps := c.EtcdClient().Endpoints()
 	curRev := c.getLeaderCurrentRevision(ctx)
 
 	revToCompact := max(0, curRev-reserve)
@@ -43,15 +44,107 @@ func (c *Client) Compact(ctx context.Context, reserve int64) error {
 	}
 
 	t := time.Now()
-	_, err := c.Client.Compact(ctx, revToCompact, clientv3.WithCompactPhysical())
-	metrics.ReportBackendOperationCompleted(OperationCompact, err, t)
+	err := c.withRetry(ctx, OperationCompact, nil, func() error {
+		_, cerr := c.EtcdClient().Compact(ctx, revToCompact, clientv3.WithCompactPhysical())
+		return cerr
+	})
 	if err != nil {
 		log.GetLogger().Error(fmt.Sprintf("compact %s failed, revision is %d(current: %d, reserve %d), error: %s",
 			eps, revToCompact, curRev, reserve, err))
 		return err
 	}
 	c.logInfoOrWarn(t, fmt.Sprintf("compacted %s, revision is %d(current: %d, reserve %d)",
-		eps, revToCompact, curRev, reserve))
+		eps, revToCompact, curRev, reserve), openlog.Tags{
+		log.FieldOperation: OperationCompact,
+		log.FieldEndpoint:  eps,
+		log.FieldRevision:  revToCompact,
+		"current":          curRev,
+		"reserve":          reserve,
+	})
+	return c.defragmentIfNeeded(ctx)
+}
+
+func (c *Client) defragmentIfNeeded(ctx context.Context) error {
+	threshold := c.Cfg.DefragmentThreshold
+	if threshold <= 0 {
+		return nil
+	}
+	status, err := c.Status(ctx)
+	if err != nil {
+		return err
+	}
+	if ratio := status.Fragmentation(); ratio < threshold {
+		log.GetLogger().Info(fmt.Sprintf("fragmentation is %.2f, <%.2f, no need to defragment", ratio, threshold))
+		return nil
+	}
+	return c.Defragment(ctx)
+}
+
+func (c *Client) Defragment(ctx context.Context) error {
+	before, err := c.Status(ctx)
+	if err != nil {
+		return err
+	}
+	eps := c.getDefragmentEndpoints(ctx)
+	for _, ep := range eps {
+		t := time.Now()
+		otCtx, cancel := c.WithTimeout(ctx)
+		_, err = c.EtcdClient().Defragment(otCtx, ep)
+		cancel()
+		if err != nil {
+			log.GetLogger().Error(fmt.Sprintf("defragment %s failed, error: %s", ep, err))
+			return err
+		}
+		c.logInfoOrWarn(t, fmt.Sprintf("defragmented %s", ep), openlog.Tags{
+			log.FieldOperation: OperationDefragment,
+			log.FieldEndpoint:  ep,
+		})
+	}
+	after, err := c.Status(ctx)
+	if err != nil {
+		return err
+	}
+	log.GetLogger().Info(fmt.Sprintf("defragmented %s, db size is %d(before: %d)", eps, after.DBSize, before.DBSize))
+	return nil
+}
+
+// getDefragmentEndpoints returns the endpoints and the leader is the last one,
+// because defragment blocks the member from reading and writing
+func (c *Client) getDefragmentEndpoints(ctx context.Context) []string {
+	var eps []string
+	leader := ""
+	for _, ep := range c.EtcdClient().Endpoints() {
+		resp, err := c.GetEndpointStatus(ctx, ep)
+		if err == nil && resp.Leader == resp.Header.MemberId {
+			leader = ep
+			continue
+		}
+		eps = append(eps, ep)
+	}
+	if len(leader) > 0 {
+		eps = append(eps, leader)
+	}
+	return eps
+}
+
+func (c *Client) Snapshot(ctx context.Context, w io.Writer) error {
+	start := time.Now()
+	rc, err := c.EtcdClient().Snapshot(ctx)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	n, err := io.Copy(w, rc)
+	if err != nil {
+		log.GetLogger().Error(fmt.Sprintf("snapshot %s failed, error: %s", c.EtcdClient().Endpoints(), err))
+		return err
+	}
+	c.logInfoOrWarn(start, fmt.Sprintf("snapshot %s, size is %d", c.Client.Endpoints(), n), openlog.Tags{
+		log.FieldOperation: OperationSnapshot,
+		log.FieldEndpoint:  c.EtcdClient().Endpoints(),
+		"size":             n,
+	})
 	return nil
 }
 
@@ -67,7 +160,7 @@ func (c *Client) getLeaderCurrentRevision(ctx context.Context) int64 {
 }
 
 func (c *Client) getLeaderStatus(ctx context.Context) (string, *clientv3.StatusResponse) {
-	eps := c.Client.Endpoints()
+	eps := c.EtcdClient().Endpoints()
 	for _, ep := range eps {
 		resp, err := c.GetEndpointStatus(ctx, ep)
 		if err != nil {
@@ -84,7 +177,7 @@ func (c *Client) getLeaderStatus(ctx context.Context) (string, *clientv3.StatusR
 
 func (c *Client) GetEndpointStatus(ctx context.Context, ep string) (*clientv3.StatusResponse, error) {
 	otCtx, cancel := c.WithTimeout(ctx)
-	resp, err := c.Client.Status(otCtx, ep)
+	resp, err := c.EtcdClient().Status(otCtx, ep)
 	defer cancel()
 	if err != nil {
 		return nil, err
@@ -98,13 +191,82 @@ func (c *Client) ListCluster(ctx context.Context) (etcdadpt.Clusters, error) {
 }
 
 func (c *Client) Status(ctx context.Context) (*etcdadpt.StatusResponse, error) {
-	ep, resp := c.getLeaderStatus(ctx)
-	if len(ep) == 0 || resp == nil {
+	leaderIndex := -1
+	eps := c.EtcdClient().Endpoints()
+	endpoints := make([]etcdadpt.EndpointStatus, 0, len(eps))
+	for _, ep := range eps {
+		status := etcdadpt.EndpointStatus{Endpoint: ep}
+		resp, err := c.GetEndpointStatus(ctx, ep)
+		if err != nil {
+			log.GetLogger().Error(fmt.Sprintf("get status from %s failed, error: %s", ep, err))
+			status.Error = err.Error()
+			endpoints = append(endpoints, status)
+			continue
+		}
+		setEndpointStatus(&status, resp)
+		endpoints = append(endpoints, status)
+		if leaderIndex < 0 && resp.Leader == resp.Header.MemberId {
+			leaderIndex = len(endpoints) - 1
+		}
+	}
+	if leaderIndex < 0 {
+		log.GetLogger().Error("get leader status failed, error: no leader")
 		return nil, ErrGetLeaderFailed
 	}
-	return &etcdadpt.StatusResponse{
-		DBSize: resp.DbSize,
-	}, nil
+
+	leader := endpoints[leaderIndex]
+	status := &etcdadpt.StatusResponse{
+		MemberID:         leader.MemberID,
+		Leader:           leader.Leader,
+		RaftTerm:         leader.RaftTerm,
+		RaftIndex:        leader.RaftIndex,
+		RaftAppliedIndex: leader.RaftAppliedIndex,
+		Revision:         leader.Revision,
+		DBSize:           leader.DBSize,
+		DBSizeInUse:      leader.DBSizeInUse,
+		Version:          leader.Version,
+		Endpoints:        endpoints,
+	}
+	// return the partial status if failed to list the alarms
+	alarms, err := c.listAlarms(ctx)
+	if err != nil {
+		status.AlarmsError = err.Error()
+		return status, nil
+	}
+	status.Alarms = alarms
+	return status, nil
+}
+
+func setEndpointStatus(status *etcdadpt.EndpointStatus, resp *clientv3.StatusResponse) {
+	status.MemberID = resp.Header.MemberId
+	status.Leader = resp.Leader
+	status.RaftTerm = resp.RaftTerm
+	status.RaftIndex = resp.RaftIndex
+	status.RaftAppliedIndex = resp.RaftAppliedIndex
+	status.Revision = resp.Header.Revision
+	status.DBSize = resp.DbSize
+	status.DBSizeInUse = resp.DbSizeInUse
+	status.Version = resp.Version
+	status.IsLearner = resp.IsLearner
+	status.Errors = resp.Errors
+}
+
+func (c *Client) listAlarms(ctx context.Context) ([]etcdadpt.Alarm, error) {
+	otCtx, cancel := c.WithTimeout(ctx)
+	defer cancel()
+	resp, err := c.EtcdClient().AlarmList(otCtx)
+	if err != nil {
+		log.GetLogger().Error(fmt.Sprintf("list alarms failed, error: %s", err))
+		return nil, err
+	}
+	alarms := make([]etcdadpt.Alarm, 0, len(resp.Alarms))
+	for _, alarm := range resp.Alarms {
+		alarms = append(alarms, etcdadpt.Alarm{
+			MemberID: alarm.MemberID,
+			Type:     alarm.Alarm.String(),
+		})
+	}
+	return alarms, nil
 }
 
 func (c *Client) Err() <-chan error {
@@ -116,10 +278,13 @@ func (c *Client) Ready() <-chan struct{} {
 }
 
 func (c *Client) Close() {
+	c.mux.Lock()
+	c.closed = true
+	c.mux.Unlock()
 	c.goroutine.Close(true)
 
-	if c.Client != nil {
-		c.Client.Close()
+	if client := c.EtcdClient(); client != nil {
+		client.Close()
 	}
 	log.GetLogger().Debug("etcd client stopped")
 }
@@ -0,0 +1,238 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/little-cui/etcdadpt"
+	"github.com/little-cui/etcdadpt/middleware/log"
+)
+
+// ErrNoEndpoints the resolver found no endpoints
+var ErrNoEndpoints = errors.New("no endpoints resolved")
+
+// staticResolver returns the endpoints parsed from ClusterAddresses
+type staticResolver struct {
+	endpoints []string
+}
+
+func (r *staticResolver) Resolve(_ context.Context) ([]string, error) {
+	return r.endpoints, nil
+}
+
+// SRVResolver resolves the endpoints from the DNS SRV records
+type SRVResolver struct {
+	// Domain the domain to query the SRV records
+	Domain string
+	// Name optional, the suffix of the service name
+	Name string
+	// Secure query '_etcd-client-ssl._tcp' records too
+	Secure bool
+	// Resolver optional, by default use net.DefaultResolver
+	Resolver *net.Resolver
+}
+
+func (r *SRVResolver) Resolve(ctx context.Context) ([]string, error) {
+	resolver := r.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	services := []string{"etcd-client"}
+	if r.Secure {
+		services = append([]string{"etcd-client-ssl"}, services...)
+	}
+
+	var (
+		endpoints []string
+		exists    = make(map[string]struct{})
+		lastErr   error
+	)
+	for _, service := range services {
+		if len(r.Name) > 0 {
+			service += "-" + r.Name
+		}
+		_, addrs, err := resolver.LookupSRV(ctx, service, "tcp", r.Domain)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		for _, srv := range addrs {
+			ep := net.JoinHostPort(strings.TrimSuffix(srv.Target, "."), strconv.Itoa(int(srv.Port)))
+			if _, ok := exists[ep]; ok {
+				continue
+			}
+			exists[ep] = struct{}{}
+			endpoints = append(endpoints, ep)
+		}
+	}
+	if len(endpoints) == 0 {
+		if lastErr == nil {
+			lastErr = ErrNoEndpoints
+		}
+		return nil, fmt.Errorf("resolve the SRV records of %s failed: %w", r.Domain, lastErr)
+	}
+	return endpoints, nil
+}
+
+// FileResolver resolves the endpoints from a file, the file is re-read when it changed
+type FileResolver struct {
+	// Path the file lists the endpoints in the same format as ClusterAddresses
+	Path string
+	// ClusterName optional, the cluster to select if the file lists multiple clusters
+	ClusterName string
+
+	mux       sync.Mutex
+	modTime   time.Time
+	size      int64
+	endpoints []string
+}
+
+func (r *FileResolver) Resolve(_ context.Context) ([]string, error) {
+	fi, err := os.Stat(r.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if r.endpoints != nil && fi.ModTime().Equal(r.modTime) && fi.Size() == r.size {
+		return r.endpoints, nil
+	}
+
+	data, err := os.ReadFile(r.Path)
+	if err != nil {
+		return nil, err
+	}
+	addrs := strings.Join(strings.Fields(strings.ReplaceAll(string(data), ",", " ")), ",")
+	endpoints := toEndpoints(etcdadpt.GetClusterURL(r.clusterName(), addrs, ""))
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("resolve the file %s failed: %w", r.Path, ErrNoEndpoints)
+	}
+	r.modTime, r.size, r.endpoints = fi.ModTime(), fi.Size(), endpoints
+	return endpoints, nil
+}
+
+func (r *FileResolver) clusterName() string {
+	if len(r.ClusterName) == 0 {
+		return etcdadpt.DefaultClusterName
+	}
+	return r.ClusterName
+}
+
+// toEndpoints strips the scheme of the addresses
+func toEndpoints(addrs []string) []string {
+	endpoints := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		if len(addr) == 0 {
+			continue
+		}
+		if strings.Index(addr, "://") > 0 {
+			// 如果配置格式为"sr-0=http(s)://IP:Port"，则需要分离IP:Port部分
+			endpoints = append(endpoints, addr[strings.Index(addr, "://")+3:])
+		} else {
+			endpoints = append(endpoints, addr)
+		}
+	}
+	return endpoints
+}
+
+func (c *Client) newResolver() etcdadpt.Resolver {
+	switch {
+	case c.Cfg.Resolver != nil:
+		return c.Cfg.Resolver
+	case len(c.Cfg.DiscoverySRV) > 0:
+		return &SRVResolver{Domain: c.Cfg.DiscoverySRV, Name: c.Cfg.DiscoverySRVName, Secure: c.Cfg.SslEnabled}
+	case len(c.Cfg.EndpointsFile) > 0:
+		return &FileResolver{Path: c.Cfg.EndpointsFile, ClusterName: c.Cfg.ClusterName}
+	default:
+		// use the default cluster endpoints
+		addrs := etcdadpt.GetClusterURL(c.Cfg.ClusterName, c.Cfg.ClusterAddresses, "")
+		return &staticResolver{endpoints: toEndpoints(addrs)}
+	}
+}
+
+// isDiscovery returns true if the endpoints are resolved dynamically
+func (c *Client) isDiscovery() bool {
+	if c.resolver == nil {
+		return false
+	}
+	_, ok := c.resolver.(*staticResolver)
+	return !ok
+}
+
+func (c *Client) resolveEndpoints(ctx context.Context) error {
+	endpoints, err := c.resolver.Resolve(ctx)
+	if err != nil {
+		return err
+	}
+	if len(endpoints) == 0 {
+		return ErrNoEndpoints
+	}
+	c.Endpoints = endpoints
+	return nil
+}
+
+// RefreshEndpoints updates the endpoints of client if the resolution changed,
+// the static endpoints parsed from ClusterAddresses are never refreshed
+func (c *Client) RefreshEndpoints(ctx context.Context) error {
+	if !c.isDiscovery() {
+		return nil
+	}
+	endpoints, err := c.resolver.Resolve(ctx)
+	if err != nil {
+		return err
+	}
+	if len(endpoints) == 0 {
+		return nil
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if equalEndpoints(endpoints, c.Endpoints) {
+		return nil
+	}
+	log.GetLogger().Warn(fmt.Sprintf("the resolved endpoints changed, %v -> %v", c.Endpoints, endpoints))
+	c.Endpoints = endpoints
+	c.Client.SetEndpoints(endpoints...)
+	return nil
+}
+
+func equalEndpoints(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	exists := make(map[string]struct{}, len(a))
+	for _, ep := range a {
+		exists[ep] = struct{}{}
+	}
+	for _, ep := range b {
+		if _, ok := exists[ep]; !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,244 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package remote_test
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/little-cui/etcdadpt"
+	"github.com/little-cui/etcdadpt/remote"
+	"github.com/stretchr/testify/assert"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// fakeDNS serves the SRV records only
+type fakeDNS struct {
+	conn    net.PacketConn
+	mux     sync.Mutex
+	records map[string][]net.SRV
+}
+
+func startFakeDNS(t *testing.T) *fakeDNS {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	s := &fakeDNS{conn: conn, records: make(map[string][]net.SRV)}
+	t.Cleanup(func() { conn.Close() })
+	go s.serve()
+	return s
+}
+
+func (s *fakeDNS) set(name string, records ...net.SRV) {
+	s.mux.Lock()
+	s.records[name] = records
+	s.mux.Unlock()
+}
+
+func (s *fakeDNS) serve() {
+	buf := make([]byte, 512)
+	for {
+		n, addr, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		var msg dnsmessage.Message
+		if err := msg.Unpack(buf[:n]); err != nil || len(msg.Questions) == 0 {
+			continue
+		}
+		q := msg.Questions[0]
+		msg.Header.Response = true
+		msg.Header.Authoritative = true
+		msg.Answers = nil
+
+		s.mux.Lock()
+		records, ok := s.records[q.Name.String()]
+		s.mux.Unlock()
+		switch {
+		case !ok:
+			msg.Header.RCode = dnsmessage.RCodeNameError
+		case q.Type == dnsmessage.TypeSRV:
+			for _, srv := range records {
+				msg.Answers = append(msg.Answers, dnsmessage.Resource{
+					Header: dnsmessage.ResourceHeader{Name: q.Name, Type: q.Type, Class: q.Class, TTL: 1},
+					Body: &dnsmessage.SRVResource{
+						Priority: srv.Priority,
+						Weight:   srv.Weight,
+						Port:     srv.Port,
+						Target:   dnsmessage.MustNewName(srv.Target),
+					},
+				})
+			}
+		}
+		resp, err := msg.Pack()
+		if err != nil {
+			continue
+		}
+		_, _ = s.conn.WriteTo(resp, addr)
+	}
+}
+
+func (s *fakeDNS) resolver() *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "udp", s.conn.LocalAddr().String())
+		},
+	}
+}
+
+func TestSRVResolver_Resolve(t *testing.T) {
+	dns := startFakeDNS(t)
+	dns.set("_etcd-client._tcp.example.local.", net.SRV{Target: "localhost.", Port: 2379})
+	dns.set("_etcd-client-ssl._tcp.example.local.", net.SRV{Target: "etcd-0.example.local.", Port: 2379},
+		net.SRV{Target: "localhost.", Port: 2379})
+	dns.set("_etcd-client-x._tcp.example.local.", net.SRV{Target: "localhost.", Port: 3379})
+	ctx := context.Background()
+
+	t.Run("resolve the SRV records, should return the endpoints", func(t *testing.T) {
+		r := &remote.SRVResolver{Domain: "example.local", Resolver: dns.resolver()}
+		endpoints, err := r.Resolve(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"localhost:2379"}, endpoints)
+
+		r.Name = "x"
+		endpoints, err = r.Resolve(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"localhost:3379"}, endpoints)
+	})
+
+	t.Run("resolve the secure SRV records, should merge the records", func(t *testing.T) {
+		r := &remote.SRVResolver{Domain: "example.local", Secure: true, Resolver: dns.resolver()}
+		endpoints, err := r.Resolve(ctx)
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []string{"etcd-0.example.local:2379", "localhost:2379"}, endpoints)
+	})
+
+	t.Run("resolve an unknown domain, should return error", func(t *testing.T) {
+		r := &remote.SRVResolver{Domain: "unknown.local", Resolver: dns.resolver()}
+		_, err := r.Resolve(ctx)
+		assert.Error(t, err)
+	})
+}
+
+func TestEtcdClient_Resolver(t *testing.T) {
+	ctx := context.Background()
+	local := strings.TrimPrefix(endpoint, "http://")
+
+	t.Run("resolve by SRV, should connect to etcd", func(t *testing.T) {
+		dns := startFakeDNS(t)
+		dns.set("_etcd-client._tcp.example.local.", net.SRV{Target: "localhost.", Port: 2379})
+
+		var cfg etcdadpt.Config
+		cfg.Kind = "etcd"
+		cfg.DialTimeout = dialTimeout
+		cfg.RequestTimeOut = requestTimeout
+		cfg.Resolver = &remote.SRVResolver{Domain: "example.local", Resolver: dns.resolver()}
+		cfg.Init()
+		inst, err := etcdadpt.NewInstance(cfg)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer inst.Close()
+		assert.Equal(t, []string{"localhost:2379"}, etcdadpt.Unwrap(inst).(*remote.Client).Endpoints)
+
+		_, err = inst.Do(ctx, etcdadpt.PUT, etcdadpt.WithStrKey("/test_resolver"), etcdadpt.WithStrValue("a"))
+		assert.NoError(t, err)
+	})
+
+	t.Run("resolve by file, should refresh the endpoints on change", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "endpoints")
+		assert.NoError(t, os.WriteFile(file, []byte(local+"\n"), 0600))
+
+		var cfg etcdadpt.Config
+		cfg.Kind = "etcd"
+		cfg.DialTimeout = dialTimeout
+		cfg.RequestTimeOut = requestTimeout
+		cfg.EndpointsFile = file
+		cfg.Init()
+		inst, err := etcdadpt.NewInstance(cfg)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer inst.Close()
+		c := etcdadpt.Unwrap(inst).(*remote.Client)
+		assert.Equal(t, []string{local}, c.Endpoints)
+
+		// make sure the modification time changed
+		time.Sleep(10 * time.Millisecond)
+		assert.NoError(t, os.WriteFile(file, []byte("default=http://localhost:2379\n"), 0600))
+		err = c.SyncMembers(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"localhost:2379"}, c.Endpoints)
+		// the member urls should not replace the resolved endpoints
+		assert.Equal(t, []string{"localhost:2379"}, c.EtcdClient().Endpoints())
+
+		_, err = inst.Do(ctx, etcdadpt.GET, etcdadpt.WithStrKey("/test_resolver"))
+		assert.NoError(t, err)
+	})
+
+	t.Run("resolve by file and the member is unavailable, should return error", func(t *testing.T) {
+		s := startFlakyEtcd(t)
+		file := filepath.Join(t.TempDir(), "endpoints")
+		assert.NoError(t, os.WriteFile(file, []byte(s.addr+"\n"), 0600))
+
+		var cfg etcdadpt.Config
+		cfg.Kind = "etcd"
+		cfg.DialTimeout = dialTimeout
+		cfg.RequestTimeOut = requestTimeout
+		cfg.EndpointsFile = file
+		cfg.Init()
+		inst, err := etcdadpt.NewInstance(cfg)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer inst.Close()
+		c := etcdadpt.Unwrap(inst).(*remote.Client)
+		assert.NoError(t, c.SyncMembers(ctx))
+
+		// the file still resolves, but the member does not respond
+		s.failMembers(rpctypes.ErrGRPCNoLeader)
+		subCtx, cancel := context.WithTimeout(ctx, requestTimeout)
+		defer cancel()
+		assert.Error(t, c.SyncMembers(subCtx))
+		assert.Equal(t, []string{s.addr}, c.EtcdClient().Endpoints())
+	})
+
+	t.Run("resolve an empty file, should return error", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "endpoints")
+		assert.NoError(t, os.WriteFile(file, []byte("\n"), 0600))
+
+		var cfg etcdadpt.Config
+		cfg.Kind = "etcd"
+		cfg.DialTimeout = dialTimeout
+		cfg.RequestTimeOut = requestTimeout
+		cfg.EndpointsFile = file
+		cfg.Init()
+		_, err := etcdadpt.NewInstance(cfg)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,130 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package remote
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/go-chassis/openlog"
+	"github.com/little-cui/etcdadpt"
+	"github.com/little-cui/etcdadpt/middleware/log"
+)
+
+// withRetry calls f until it succeeds, the error is not retryable or the retries exhausted,
+// the operations without ops, like the lease ones and compact, are retried as idempotent
+func (c *Client) withRetry(ctx context.Context, operation string, ops []etcdadpt.OpOptions, f func() error) error {
+	mode, maxRetries := c.retryPolicy(ops)
+	idempotent := isIdempotent(ops)
+	b := c.Cfg.RetryPolicy.Backoff
+	if b == nil {
+		b = etcdadpt.DefaultRetryBackoff
+	}
+
+	for i := 0; ; i++ {
+		err := f()
+		if err == nil || i >= maxRetries || !isRetryable(err, mode, idempotent) {
+			return err
+		}
+		d := b.Delay(i)
+		retryLogger.Warn(fmt.Sprintf("retry to %s %s after %s, retries: %d, error: %s",
+			operation, retryKey(ops), d, i+1, err), openlog.WithTags(openlog.Tags{
+			log.FieldOperation: operation,
+			log.FieldKey:       retryKey(ops),
+			log.FieldEndpoint:  c.Endpoint(),
+			"delay":            d.String(),
+			"retries":          i + 1,
+		}), openlog.WithErr(err))
+		c.reporter().ReportBackendOperationRetried(operation)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(d):
+		}
+	}
+}
+
+func retryKey(ops []etcdadpt.OpOptions) string {
+	if len(ops) == 0 {
+		return ""
+	}
+	return string(ops[0].Key)
+}
+
+// retryPolicy returns the retry mode and max retries overridden by the ops
+func (c *Client) retryPolicy(ops []etcdadpt.OpOptions) (etcdadpt.RetryMode, int) {
+	mode, maxRetries := c.Cfg.RetryPolicy.Mode, c.Cfg.RetryPolicy.MaxRetries
+	for _, op := range ops {
+		if op.RetrySet {
+			mode = op.Retry
+		}
+		if op.MaxRetries > 0 {
+			maxRetries = op.MaxRetries
+		}
+	}
+	return mode, maxRetries
+}
+
+// isIdempotent returns true if all the ops are read only
+func isIdempotent(ops []etcdadpt.OpOptions) bool {
+	for _, op := range ops {
+		if op.Action != etcdadpt.ActionGet {
+			return false
+		}
+	}
+	return true
+}
+
+func isRetryable(err error, mode etcdadpt.RetryMode, idempotent bool) bool {
+	switch {
+	case mode == etcdadpt.RetryNever:
+		return false
+	case mode == etcdadpt.RetryAlways || idempotent:
+		return isTransientError(err)
+	default:
+		return isSafeRetryError(err)
+	}
+}
+
+func isTransientError(err error) bool {
+	if err.Error() == rpctypes.ErrTooManyRequests.Error() {
+		return true
+	}
+	if ev, ok := err.(rpctypes.EtcdError); ok {
+		return ev.Code() == codes.Unavailable
+	}
+	return status.Code(err) == codes.Unavailable
+}
+
+// isSafeRetryError returns true if the request was rejected before being applied,
+// see go.etcd.io/etcd/client/v3/retry_interceptor.go
+func isSafeRetryError(err error) bool {
+	if err.Error() == rpctypes.ErrTooManyRequests.Error() {
+		return true
+	}
+	if status.Code(err) != codes.Unavailable {
+		return false
+	}
+	desc := rpctypes.ErrorDesc(err)
+	return desc == "there is no address available" || desc == "there is no connection available"
+}
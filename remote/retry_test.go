@@ -0,0 +1,272 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package remote_test
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chassis/foundation/backoff"
+	"github.com/little-cui/etcdadpt"
+	"github.com/stretchr/testify/assert"
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	"google.golang.org/grpc"
+)
+
+// flakyEtcd fails the kv and lease requests for the given times
+type flakyEtcd struct {
+	etcdserverpb.UnimplementedKVServer
+	etcdserverpb.UnimplementedClusterServer
+	etcdserverpb.UnimplementedLeaseServer
+
+	addr     string
+	mux      sync.Mutex
+	failures int
+	err      error
+	calls    int
+	// memberErr fails the member listing
+	memberErr error
+}
+
+func startFlakyEtcd(t *testing.T) *flakyEtcd {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	s := &flakyEtcd{addr: l.Addr().String()}
+	srv := grpc.NewServer()
+	etcdserverpb.RegisterKVServer(srv, s)
+	etcdserverpb.RegisterClusterServer(srv, s)
+	etcdserverpb.RegisterLeaseServer(srv, s)
+	go func() { _ = srv.Serve(l) }()
+	t.Cleanup(srv.Stop)
+	return s
+}
+
+func (s *flakyEtcd) fail(n int, err error) {
+	s.mux.Lock()
+	s.failures, s.err, s.calls = n, err, 0
+	s.mux.Unlock()
+}
+
+func (s *flakyEtcd) failMembers(err error) {
+	s.mux.Lock()
+	s.memberErr = err
+	s.mux.Unlock()
+}
+
+func (s *flakyEtcd) Calls() int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.calls
+}
+
+func (s *flakyEtcd) call() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.calls++
+	if s.failures > 0 {
+		s.failures--
+		return s.err
+	}
+	return nil
+}
+
+func (s *flakyEtcd) header() *etcdserverpb.ResponseHeader {
+	return &etcdserverpb.ResponseHeader{Revision: 1}
+}
+
+func (s *flakyEtcd) Range(context.Context, *etcdserverpb.RangeRequest) (*etcdserverpb.RangeResponse, error) {
+	if err := s.call(); err != nil {
+		return nil, err
+	}
+	return &etcdserverpb.RangeResponse{Header: s.header()}, nil
+}
+
+func (s *flakyEtcd) Put(context.Context, *etcdserverpb.PutRequest) (*etcdserverpb.PutResponse, error) {
+	if err := s.call(); err != nil {
+		return nil, err
+	}
+	return &etcdserverpb.PutResponse{Header: s.header()}, nil
+}
+
+func (s *flakyEtcd) Txn(context.Context, *etcdserverpb.TxnRequest) (*etcdserverpb.TxnResponse, error) {
+	if err := s.call(); err != nil {
+		return nil, err
+	}
+	return &etcdserverpb.TxnResponse{Header: s.header(), Succeeded: true}, nil
+}
+
+func (s *flakyEtcd) LeaseRevoke(context.Context, *etcdserverpb.LeaseRevokeRequest) (*etcdserverpb.LeaseRevokeResponse, error) {
+	if err := s.call(); err != nil {
+		return nil, err
+	}
+	return &etcdserverpb.LeaseRevokeResponse{Header: s.header()}, nil
+}
+
+func (s *flakyEtcd) LeaseTimeToLive(_ context.Context, req *etcdserverpb.LeaseTimeToLiveRequest) (*etcdserverpb.LeaseTimeToLiveResponse, error) {
+	if err := s.call(); err != nil {
+		return nil, err
+	}
+	return &etcdserverpb.LeaseTimeToLiveResponse{Header: s.header(), ID: req.ID, TTL: 10}, nil
+}
+
+func (s *flakyEtcd) MemberList(context.Context, *etcdserverpb.MemberListRequest) (*etcdserverpb.MemberListResponse, error) {
+	s.mux.Lock()
+	err := s.memberErr
+	s.mux.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return &etcdserverpb.MemberListResponse{
+		Header:  s.header(),
+		Members: []*etcdserverpb.Member{{ID: 1, Name: "flaky", ClientURLs: []string{"http://" + s.addr}}},
+	}, nil
+}
+
+func TestEtcdClient_Retry(t *testing.T) {
+	s := startFlakyEtcd(t)
+
+	var cfg etcdadpt.Config
+	cfg.Kind = "etcd"
+	cfg.ClusterAddresses = s.addr
+	cfg.DialTimeout = dialTimeout
+	cfg.RequestTimeOut = requestTimeout
+	cfg.RetryPolicy = etcdadpt.RetryPolicy{
+		MaxRetries: 3,
+		Backoff:    &backoff.PowerBackoff{InitDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond, Factor: 2},
+	}
+	cfg.Init()
+	inst, err := etcdadpt.NewInstance(cfg)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer inst.Close()
+	ctx := context.Background()
+
+	t.Run("get failed with transient errors, should be retried", func(t *testing.T) {
+		s.fail(2, rpctypes.ErrGRPCRequestTooManyRequests)
+		_, err := inst.Do(ctx, etcdadpt.GET, etcdadpt.WithStrKey("/test_retry"))
+		assert.NoError(t, err)
+		assert.Equal(t, 3, s.Calls())
+	})
+
+	t.Run("get with no retry, should return error", func(t *testing.T) {
+		s.fail(1, rpctypes.ErrGRPCRequestTooManyRequests)
+		_, err := inst.Do(ctx, etcdadpt.GET, etcdadpt.WithStrKey("/test_retry"), etcdadpt.WithRetry(etcdadpt.RetryNever))
+		assert.Error(t, err)
+		assert.Equal(t, 1, s.Calls())
+	})
+
+	t.Run("retries exhausted, should return error", func(t *testing.T) {
+		s.fail(5, rpctypes.ErrGRPCRequestTooManyRequests)
+		_, err := inst.Do(ctx, etcdadpt.GET, etcdadpt.WithStrKey("/test_retry"))
+		assert.Error(t, err)
+		assert.Equal(t, 4, s.Calls())
+
+		s.fail(5, rpctypes.ErrGRPCRequestTooManyRequests)
+		_, err = inst.Do(ctx, etcdadpt.GET, etcdadpt.WithStrKey("/test_retry"), etcdadpt.WithMaxRetries(5))
+		assert.NoError(t, err)
+		assert.Equal(t, 6, s.Calls())
+	})
+
+	t.Run("put failed with unsafe errors, should be retried only if allowed", func(t *testing.T) {
+		s.fail(2, rpctypes.ErrGRPCLeaderChanged)
+		_, err := inst.Do(ctx, etcdadpt.PUT, etcdadpt.WithStrKey("/test_retry"), etcdadpt.WithStrValue("a"))
+		assert.Error(t, err)
+		assert.Equal(t, 1, s.Calls())
+
+		s.fail(2, rpctypes.ErrGRPCLeaderChanged)
+		_, err = inst.Do(ctx, etcdadpt.PUT, etcdadpt.WithStrKey("/test_retry"), etcdadpt.WithStrValue("a"),
+			etcdadpt.WithRetry(etcdadpt.RetryAlways))
+		assert.NoError(t, err)
+		assert.Equal(t, 3, s.Calls())
+	})
+
+	t.Run("put rejected before applied, should be retried", func(t *testing.T) {
+		s.fail(2, rpctypes.ErrGRPCRequestTooManyRequests)
+		_, err := inst.Do(ctx, etcdadpt.PUT, etcdadpt.WithStrKey("/test_retry"), etcdadpt.WithStrValue("a"))
+		assert.NoError(t, err)
+		assert.Equal(t, 3, s.Calls())
+	})
+
+	t.Run("txn, should be retried only if read only", func(t *testing.T) {
+		s.fail(2, rpctypes.ErrGRPCLeaderChanged)
+		_, err := inst.Txn(ctx, []etcdadpt.OpOptions{etcdadpt.OpGet(etcdadpt.WithStrKey("/test_retry"))})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, s.Calls())
+
+		s.fail(2, rpctypes.ErrGRPCLeaderChanged)
+		_, err = inst.Txn(ctx, []etcdadpt.OpOptions{etcdadpt.OpPut(etcdadpt.WithStrKey("/test_retry"))})
+		assert.Error(t, err)
+		assert.Equal(t, 1, s.Calls())
+	})
+
+	t.Run("lease operations failed with unsafe errors, should be retried", func(t *testing.T) {
+		s.fail(2, rpctypes.ErrGRPCLeaderChanged)
+		ttl, err := inst.(etcdadpt.LeaseInspector).LeaseTimeToLive(ctx, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(10), ttl)
+		assert.Equal(t, 3, s.Calls())
+
+		s.fail(2, rpctypes.ErrGRPCLeaderChanged)
+		assert.NoError(t, inst.LeaseRevoke(ctx, 1))
+		assert.Equal(t, 3, s.Calls())
+	})
+}
+
+func TestEtcdClient_RetryOverride(t *testing.T) {
+	s := startFlakyEtcd(t)
+
+	var cfg etcdadpt.Config
+	cfg.Kind = "etcd"
+	cfg.ClusterAddresses = s.addr
+	cfg.DialTimeout = dialTimeout
+	cfg.RequestTimeOut = requestTimeout
+	cfg.RetryPolicy = etcdadpt.RetryPolicy{
+		MaxRetries: 3,
+		Mode:       etcdadpt.RetryNever,
+		Backoff:    &backoff.PowerBackoff{InitDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond, Factor: 2},
+	}
+	cfg.Init()
+	inst, err := etcdadpt.NewInstance(cfg)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer inst.Close()
+	ctx := context.Background()
+
+	t.Run("get with the mode of config, should not be retried", func(t *testing.T) {
+		s.fail(1, rpctypes.ErrGRPCRequestTooManyRequests)
+		_, err := inst.Do(ctx, etcdadpt.GET, etcdadpt.WithStrKey("/test_retry"))
+		assert.Error(t, err)
+		assert.Equal(t, 1, s.Calls())
+	})
+
+	t.Run("get with the default mode, should override the mode of config", func(t *testing.T) {
+		s.fail(2, rpctypes.ErrGRPCRequestTooManyRequests)
+		_, err := inst.Do(ctx, etcdadpt.GET, etcdadpt.WithStrKey("/test_retry"),
+			etcdadpt.WithRetry(etcdadpt.RetryDefault))
+		assert.NoError(t, err)
+		assert.Equal(t, 3, s.Calls())
+	})
+}
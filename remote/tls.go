@@ -0,0 +1,123 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package remote
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"time"
+
+	"go.etcd.io/etcd/client/pkg/v3/transport"
+
+	"github.com/little-cui/etcdadpt/middleware/log"
+)
+
+func (c *Client) hasTLSProvider() bool {
+	return c.Cfg.TLSConfigFunc != nil || len(c.Cfg.CertFile) > 0 || len(c.Cfg.CAFile) > 0
+}
+
+// loadTLSConfig returns the TLS config from TLSConfigFunc or the PEM files,
+// and the fingerprint of the certificates and the root CAs to check the changes
+func (c *Client) loadTLSConfig() (*tls.Config, string, error) {
+	h := sha256.New()
+	if c.Cfg.TLSConfigFunc != nil {
+		tlsCfg, err := c.Cfg.TLSConfigFunc()
+		if err != nil {
+			return nil, "", err
+		}
+		if tlsCfg == nil {
+			return nil, "", fmt.Errorf("TLSConfigFunc returns nil")
+		}
+		for _, cert := range tlsCfg.Certificates {
+			for _, der := range cert.Certificate {
+				h.Write(der)
+			}
+		}
+		if tlsCfg.RootCAs != nil {
+			// the pool does not export the certificates
+			for _, subject := range tlsCfg.RootCAs.Subjects() {
+				h.Write(subject)
+			}
+		}
+		return tlsCfg, hex.EncodeToString(h.Sum(nil)), nil
+	}
+
+	for _, file := range []string{c.Cfg.CertFile, c.Cfg.KeyFile, c.Cfg.CAFile} {
+		if len(file) == 0 {
+			continue
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, "", err
+		}
+		h.Write(data)
+	}
+	info := transport.TLSInfo{
+		CertFile:      c.Cfg.CertFile,
+		KeyFile:       c.Cfg.KeyFile,
+		TrustedCAFile: c.Cfg.CAFile,
+	}
+	tlsCfg, err := info.ClientConfig()
+	if err != nil {
+		return nil, "", err
+	}
+	return tlsCfg, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ReloadTLS starts to check the changes of the certificates in background
+func (c *Client) ReloadTLS() {
+	if c.Cfg.SslEnabled && c.Cfg.CertReloadInterval > 0 && c.hasTLSProvider() {
+		c.goroutine.Do(c.ReloadTLSLoop)
+	}
+}
+
+func (c *Client) ReloadTLSLoop(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(c.Cfg.CertReloadInterval):
+			if err := c.ReloadTLSIfChanged(); err != nil {
+				log.GetLogger().Error(fmt.Sprintf("reload TLS config failed, error: %s", err))
+			}
+		}
+	}
+}
+
+// ReloadTLSIfChanged reconnects etcd through ReOpen if the certificates changed,
+// it will be retried next time if failed, for example, the files are being written
+func (c *Client) ReloadTLSIfChanged() error {
+	tlsCfg, fingerprint, err := c.loadTLSConfig()
+	if err != nil {
+		return err
+	}
+	if fingerprint == c.tlsFingerprint {
+		return nil
+	}
+
+	if err := c.reOpen(tlsCfg); err != nil {
+		return err
+	}
+	c.tlsFingerprint = fingerprint
+	log.GetLogger().Warn(fmt.Sprintf("reconnected to etcd %v with the new certificates", c.endpoints()))
+	return nil
+}
@@ -0,0 +1,292 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package remote_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/little-cui/etcdadpt"
+	"github.com/little-cui/etcdadpt/remote"
+	"github.com/stretchr/testify/assert"
+	"go.etcd.io/etcd/client/pkg/v3/transport"
+	"go.etcd.io/etcd/server/v3/embed"
+)
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+func newTestCert(t *testing.T, cn string, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	signer, signerKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	assert.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.NoError(t, err)
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func (c *testCert) writeFiles(t *testing.T, certFile, keyFile string) {
+	assert.NoError(t, os.WriteFile(certFile, c.certPEM, 0600))
+	assert.NoError(t, os.WriteFile(keyFile, c.keyPEM, 0600))
+}
+
+func startTLSEtcd(t *testing.T, dir string, ca *testCert) string {
+	server := newTestCert(t, "server", ca)
+	caFile := filepath.Join(dir, "ca.crt")
+	certFile, keyFile := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+	assert.NoError(t, os.WriteFile(caFile, ca.certPEM, 0600))
+	server.writeFiles(t, certFile, keyFile)
+
+	clientURL, _ := url.Parse("https://127.0.0.1:37379")
+	peerURL, _ := url.Parse("http://127.0.0.1:37380")
+	cfg := embed.NewConfig()
+	cfg.Dir = t.TempDir()
+	cfg.LCUrls, cfg.ACUrls = []url.URL{*clientURL}, []url.URL{*clientURL}
+	cfg.LPUrls, cfg.APUrls = []url.URL{*peerURL}, []url.URL{*peerURL}
+	cfg.InitialCluster = cfg.InitialClusterFromName(cfg.Name)
+	cfg.LogLevel = "error"
+	cfg.ClientTLSInfo = transport.TLSInfo{
+		CertFile:       certFile,
+		KeyFile:        keyFile,
+		TrustedCAFile:  caFile,
+		ClientCertAuth: true,
+	}
+
+	e, err := embed.StartEtcd(cfg)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(e.Close)
+	select {
+	case <-e.Server.ReadyNotify():
+	case <-time.After(10 * time.Second):
+		t.Fatal("start etcd timed out")
+	}
+	return clientURL.String()
+}
+
+func TestEtcdClient_ReloadTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "ca", nil)
+	ep := startTLSEtcd(t, dir, ca)
+
+	certFile, keyFile := filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+	newTestCert(t, "client", ca).writeFiles(t, certFile, keyFile)
+
+	var cfg etcdadpt.Config
+	cfg.Kind = "etcd"
+	cfg.ClusterAddresses = ep
+	cfg.DialTimeout = dialTimeout
+	cfg.RequestTimeOut = requestTimeout
+	cfg.SslEnabled = true
+	cfg.CertFile = certFile
+	cfg.KeyFile = keyFile
+	cfg.CAFile = filepath.Join(dir, "ca.crt")
+	cfg.CertReloadInterval = 100 * time.Millisecond
+	cfg.Init()
+	inst, err := etcdadpt.NewInstance(cfg)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer inst.Close()
+	c := etcdadpt.Unwrap(inst).(*remote.Client)
+
+	ctx := context.Background()
+	_, err = inst.Do(ctx, etcdadpt.PUT, etcdadpt.WithStrKey("/test_reload_tls"), etcdadpt.WithStrValue("a"))
+	assert.NoError(t, err)
+
+	t.Run("rotate the client certificate, in-flight requests should not fail", func(t *testing.T) {
+		stop := make(chan struct{})
+		var (
+			wg   sync.WaitGroup
+			errs []error
+		)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if _, err := inst.Do(ctx, etcdadpt.GET, etcdadpt.WithStrKey("/test_reload_tls")); err != nil {
+					errs = append(errs, err)
+				}
+			}
+		}()
+
+		old := c.EtcdClient()
+		newTestCert(t, "client-new", ca).writeFiles(t, certFile, keyFile)
+		assert.Eventually(t, func() bool {
+			return c.EtcdClient() != old
+		}, 5*time.Second, 50*time.Millisecond)
+		// keep requesting through the graceful close of the old client
+		time.Sleep(time.Second)
+		close(stop)
+		wg.Wait()
+		assert.Empty(t, errs)
+	})
+
+	t.Run("rotate the client certificate, the open watch should not be closed", func(t *testing.T) {
+		wCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		events := make(chan *etcdadpt.Response, 10)
+		watchErr := make(chan error, 1)
+		go func() {
+			watchErr <- inst.Watch(wCtx, etcdadpt.WithStrKey("/test_reload_tls_watch"),
+				etcdadpt.WithWatchCallback(func(message string, evt *etcdadpt.Response) error {
+					events <- evt
+					return nil
+				}))
+		}()
+		// wait for the watch created
+		time.Sleep(100 * time.Millisecond)
+
+		old := c.EtcdClient()
+		newTestCert(t, "client-watch", ca).writeFiles(t, certFile, keyFile)
+		assert.Eventually(t, func() bool {
+			return c.EtcdClient() != old
+		}, 5*time.Second, 50*time.Millisecond)
+		// wait longer than the graceful close of the old client
+		time.Sleep(requestTimeout + 500*time.Millisecond)
+
+		_, err := inst.Do(ctx, etcdadpt.PUT, etcdadpt.WithStrKey("/test_reload_tls_watch"), etcdadpt.WithStrValue("a"))
+		assert.NoError(t, err)
+		select {
+		case evt := <-events:
+			assert.Equal(t, etcdadpt.ActionPut, evt.Action)
+		case err := <-watchErr:
+			t.Fatalf("watch stopped, error: %v", err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("watch event timed out")
+		}
+		cancel()
+		assert.NoError(t, <-watchErr)
+	})
+
+	t.Run("broken certificate files should be retried and the client kept", func(t *testing.T) {
+		old := c.EtcdClient()
+		assert.NoError(t, os.WriteFile(certFile, []byte("broken"), 0600))
+		assert.Error(t, c.ReloadTLSIfChanged())
+		assert.True(t, c.EtcdClient() == old)
+
+		_, err := inst.Do(ctx, etcdadpt.GET, etcdadpt.WithStrKey("/test_reload_tls"))
+		assert.NoError(t, err)
+
+		newTestCert(t, "client-fixed", ca).writeFiles(t, certFile, keyFile)
+		assert.Eventually(t, func() bool {
+			return c.EtcdClient() != old
+		}, 5*time.Second, 50*time.Millisecond)
+	})
+}
+
+func TestEtcdClient_ReloadTLSConfigFunc(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "ca", nil)
+	ep := startTLSEtcd(t, dir, ca)
+
+	client := newTestCert(t, "client", ca)
+	keyPair, err := tls.X509KeyPair(client.certPEM, client.keyPEM)
+	assert.NoError(t, err)
+	var (
+		mux     sync.Mutex
+		rootCAs = x509.NewCertPool()
+	)
+	rootCAs.AddCert(ca.cert)
+
+	var cfg etcdadpt.Config
+	cfg.Kind = "etcd"
+	cfg.ClusterAddresses = ep
+	cfg.DialTimeout = dialTimeout
+	cfg.RequestTimeOut = requestTimeout
+	cfg.SslEnabled = true
+	cfg.TLSConfigFunc = func() (*tls.Config, error) {
+		mux.Lock()
+		defer mux.Unlock()
+		return &tls.Config{Certificates: []tls.Certificate{keyPair}, RootCAs: rootCAs}, nil
+	}
+	cfg.Init()
+	inst, err := etcdadpt.NewInstance(cfg)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer inst.Close()
+	c := etcdadpt.Unwrap(inst).(*remote.Client)
+
+	t.Run("rotate the root CAs should reconnect", func(t *testing.T) {
+		assert.NoError(t, c.ReloadTLSIfChanged())
+		old := c.EtcdClient()
+		assert.True(t, c.EtcdClient() == old)
+
+		// trust the new CA as well as the old one
+		mux.Lock()
+		rootCAs = x509.NewCertPool()
+		rootCAs.AddCert(ca.cert)
+		rootCAs.AddCert(newTestCert(t, "ca-new", nil).cert)
+		mux.Unlock()
+		assert.NoError(t, c.ReloadTLSIfChanged())
+		assert.True(t, c.EtcdClient() != old)
+
+		_, err := inst.Do(context.Background(), etcdadpt.GET, etcdadpt.WithStrKey("/test_reload_tls"))
+		assert.NoError(t, err)
+	})
+}
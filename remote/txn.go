@@ -20,12 +20,11 @@ package remote
 import (
 	"context"
 	"fmt"
-	"time"
 
 	"go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/api/v3/mvccpb"
 
 	"github.com/little-cui/etcdadpt"
-	"github.com/little-cui/etcdadpt/middleware/metrics"
 	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
 	clientv3 "go.etcd.io/etcd/client/v3"
 )
@@ -38,59 +37,67 @@ func (c *Client) Txn(ctx context.Context, opts []etcdadpt.OpOptions) (*etcdadpt.
 	return &etcdadpt.Response{
 		Succeeded: resp.Succeeded,
 		Revision:  resp.Revision,
+		PrevKvs:   resp.PrevKvs,
 	}, nil
 }
 
 func (c *Client) TxnWithCmp(ctx context.Context, success []etcdadpt.OpOptions, cmps []etcdadpt.CmpOptions, fail []etcdadpt.OpOptions) (*etcdadpt.Response, error) {
 	var err error
 
-	start := time.Now()
 	etcdCmps := c.toCompares(cmps)
 	etcdSuccessOps := c.toTxnRequest(success)
 	etcdFailOps := c.toTxnRequest(fail)
 
-	var traceOps []etcdadpt.OpOptions
-	traceOps = append(traceOps, success...)
-	traceOps = append(traceOps, fail...)
-	if len(traceOps) == 0 {
+	var ops []etcdadpt.OpOptions
+	ops = append(ops, success...)
+	ops = append(ops, fail...)
+	if len(ops) == 0 {
 		return nil, fmt.Errorf("requested success or fail OpOptions list")
 	}
 
-	span := TracingBegin(ctx, "etcd:txn", traceOps[0])
-	otCtx, cancel := c.WithTimeout(ctx)
-	defer func() {
-		metrics.ReportBackendOperationCompleted(OperationTxn, err, start)
-		TracingEnd(span, err)
-		cancel()
-	}()
+	var resp *clientv3.TxnResponse
+	err = c.withRetry(ctx, OperationTxn, ops, func() error {
+		otCtx, cancel := c.WithTimeout(ctx)
+		defer cancel()
 
-	kvc := clientv3.NewKV(c.Client)
-	txn := kvc.Txn(otCtx)
-	if len(etcdCmps) > 0 {
-		txn.If(etcdCmps...)
-	}
-	txn.Then(etcdSuccessOps...)
-	if len(etcdFailOps) > 0 {
-		txn.Else(etcdFailOps...)
-	}
-	resp, err := txn.Commit()
+		kvc := clientv3.NewKV(c.EtcdClient())
+		txn := kvc.Txn(otCtx)
+		if len(etcdCmps) > 0 {
+			txn.If(etcdCmps...)
+		}
+		txn.Then(etcdSuccessOps...)
+		if len(etcdFailOps) > 0 {
+			txn.Else(etcdFailOps...)
+		}
+		var terr error
+		resp, terr = txn.Commit()
+		return terr
+	})
 	if err != nil {
 		if err.Error() == rpctypes.ErrKeyNotFound.Error() {
 			// etcd return ErrKeyNotFound if key does not exist and
 			// the PUT options contain WithIgnoreLease
 			return &etcdadpt.Response{Succeeded: false}, nil
 		}
-		return nil, err
+		return nil, toPermissionError(err, ops...)
 	}
-	c.logNilOrWarn(start, fmt.Sprintf("registry client txn {if(%v): %s, then: %d, else: %d}, rev: %d",
-		resp.Succeeded, cmps, len(success), len(fail), resp.Header.Revision))
 
-	var rangeResponse etcdserverpb.RangeResponse
+	var (
+		rangeResponse etcdserverpb.RangeResponse
+		prevKvs       []*mvccpb.KeyValue
+	)
 	for _, itf := range resp.Responses {
-		if rr, ok := itf.Response.(*etcdserverpb.ResponseOp_ResponseRange); ok {
+		switch r := itf.Response.(type) {
+		case *etcdserverpb.ResponseOp_ResponseRange:
 			// plz request the same type range kv in txn success/fail options
-			rangeResponse.Kvs = append(rangeResponse.Kvs, rr.ResponseRange.Kvs...)
-			rangeResponse.Count += rr.ResponseRange.Count
+			rangeResponse.Kvs = append(rangeResponse.Kvs, r.ResponseRange.Kvs...)
+			rangeResponse.Count += r.ResponseRange.Count
+		case *etcdserverpb.ResponseOp_ResponsePut:
+			if r.ResponsePut.PrevKv != nil {
+				prevKvs = append(prevKvs, r.ResponsePut.PrevKv)
+			}
+		case *etcdserverpb.ResponseOp_ResponseDeleteRange:
+			prevKvs = append(prevKvs, r.ResponseDeleteRange.PrevKvs...)
 		}
 	}
 
@@ -99,5 +106,6 @@ func (c *Client) TxnWithCmp(ctx context.Context, success []etcdadpt.OpOptions, c
 		Revision:  resp.Header.Revision,
 		Kvs:       rangeResponse.Kvs,
 		Count:     rangeResponse.Count,
+		PrevKvs:   prevKvs,
 	}, nil
 }
@@ -33,7 +33,10 @@ func (c *Client) Watch(ctx context.Context, opts ...etcdadpt.OpOption) (err erro
 
 	n := len(op.Key)
 	if n > 0 {
-		client := clientv3.NewWatcher(c.Client)
+		// the replaced etcd client is not closed until the watch done
+		etcdClient, done := c.watchClient()
+		defer done()
+		client := clientv3.NewWatcher(etcdClient)
 		defer client.Close()
 
 		key := stringutil.Bytes2str(op.Key)
@@ -61,6 +64,7 @@ func (c *Client) Watch(ctx context.Context, opts ...etcdadpt.OpOption) (err erro
 					return
 				}
 
+				c.reporter().ReportBackendWatchEvents(len(resp.Events))
 				err = dispatch(resp.Events, op.WatchCallback)
 				if err != nil {
 					return
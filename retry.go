@@ -0,0 +1,69 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package etcdadpt
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/go-chassis/foundation/backoff"
+)
+
+const (
+	// RetryDefault retries the idempotent operations on the transient errors,
+	// and the others only if the request was rejected before being applied
+	RetryDefault RetryMode = iota
+	// RetryNever never retries
+	RetryNever
+	// RetryAlways retries all the operations on the transient errors,
+	// make sure the puts and txns are safe to apply more than once
+	RetryAlways
+)
+
+// DefaultRetryBackoff the delay between the retries of a request
+var DefaultRetryBackoff backoff.Backoff = &backoff.PowerBackoff{
+	MaxDelay:  2 * time.Second,
+	InitDelay: 100 * time.Millisecond,
+	Factor:    2,
+}
+
+type RetryMode int
+
+func (m RetryMode) String() string {
+	switch m {
+	case RetryDefault:
+		return "DEFAULT"
+	case RetryNever:
+		return "NEVER"
+	case RetryAlways:
+		return "ALWAYS"
+	default:
+		return "RETRY" + strconv.Itoa(int(m))
+	}
+}
+
+// RetryPolicy the policy to retry the requests failed with the transient errors,
+// can be overridden per call by WithRetry and WithMaxRetries
+type RetryPolicy struct {
+	// MaxRetries optional, the max retry times of a request, 0 means never
+	MaxRetries int `json:"maxRetries,omitempty"`
+	// Mode optional, by default retries the idempotent operations only
+	Mode RetryMode `json:"mode,omitempty"`
+	// Backoff optional, the delay between the retries, by default use DefaultRetryBackoff
+	Backoff backoff.Backoff `json:"-"`
+}
@@ -161,6 +161,8 @@ type Response struct {
 	Count     int64
 	Revision  int64
 	Succeeded bool
+	// PrevKvs the previous key-values of the PUT and DELETE operations with WithPrevKv
+	PrevKvs []*mvccpb.KeyValue
 }
 
 func (pr *Response) MaxModRevision() (max int64) {
@@ -179,6 +181,70 @@ func (pr *Response) String() string {
 
 type Clusters map[string][]string
 
+type Member struct {
+	ID uint64
+	// Name the member name, empty if the member is added but not started
+	Name       string
+	PeerURLs   []string
+	ClientURLs []string
+	IsLearner  bool
+	// Healthy the member responds the status request in time
+	Healthy bool
+}
+
+// StatusResponse is the status of the cluster, the fields except Alarms and Endpoints
+// are reported by one member, it is the leader for remote etcd, and the local member
+// for embedded etcd
 type StatusResponse struct {
-	DBSize int64
+	// MemberID the ID of the member which reports the status
+	MemberID         uint64
+	Leader           uint64
+	RaftTerm         uint64
+	RaftIndex        uint64
+	RaftAppliedIndex uint64
+	// Revision the current revision of the kv store
+	Revision int64
+	// DBSize the allocated size of db, DBSizeInUse the actual size in use
+	DBSize      int64
+	DBSizeInUse int64
+	// Version the server version
+	Version string
+	// Alarms the activated alarms of all the members
+	Alarms []Alarm
+	// AlarmsError the error to list the alarms, the other fields are still valid
+	AlarmsError string
+	// Endpoints the status of every endpoint
+	Endpoints []EndpointStatus
+}
+
+type EndpointStatus struct {
+	Endpoint         string
+	MemberID         uint64
+	Leader           uint64
+	RaftTerm         uint64
+	RaftIndex        uint64
+	RaftAppliedIndex uint64
+	Revision         int64
+	DBSize           int64
+	DBSizeInUse      int64
+	Version          string
+	IsLearner        bool
+	// Errors the errors reported by the member, like no leader or alarms
+	Errors []string
+	// Error the error to get the status from the endpoint
+	Error string
+}
+
+type Alarm struct {
+	MemberID uint64
+	// Type the alarm type, like NOSPACE or CORRUPT
+	Type string
+}
+
+// Fragmentation returns the ratio of free space in the allocated db size
+func (sr *StatusResponse) Fragmentation() float64 {
+	if sr.DBSize <= 0 || sr.DBSizeInUse <= 0 {
+		return 0
+	}
+	return float64(sr.DBSize-sr.DBSizeInUse) / float64(sr.DBSize)
 }